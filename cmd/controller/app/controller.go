@@ -17,6 +17,8 @@ limitations under the License.
 package app
 
 import (
+	"net"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -25,14 +27,21 @@ import (
 	"github.com/nuclio/nuclio/pkg/platform/kube/apigatewayres"
 	nuclioioclient "github.com/nuclio/nuclio/pkg/platform/kube/client/clientset/versioned"
 	"github.com/nuclio/nuclio/pkg/platform/kube/controller"
+	// load all service discovery registrars
+	_ "github.com/nuclio/nuclio/pkg/platform/kube/discovery/consul"
 	"github.com/nuclio/nuclio/pkg/platform/kube/functionres"
 	"github.com/nuclio/nuclio/pkg/platform/kube/ingress"
+	// load all snapshot sinks
+	_ "github.com/nuclio/nuclio/pkg/platform/kube/snapshot/webhook"
 	"github.com/nuclio/nuclio/pkg/platformconfig"
 	// load all sinks
 	_ "github.com/nuclio/nuclio/pkg/sinks"
 
 	"github.com/nuclio/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 func Run(kubeconfigPath string,
@@ -46,7 +55,19 @@ func Run(kubeconfigPath string,
 	cronJobStaleResourcesCleanupIntervalStr string,
 	functionEventOperatorNumWorkersStr string,
 	projectOperatorNumWorkersStr string,
-	apiGatewayOperatorNumWorkersStr string) error {
+	apiGatewayOperatorNumWorkersStr string,
+	metricsListenAddress string) error {
+
+	// bind the metrics listener up front, so that an address that's taken fails the controller rather than go unnoticed
+	var metricsListener net.Listener
+	if metricsListenAddress != "" {
+		var err error
+
+		metricsListener, err = net.Listen("tcp", metricsListenAddress)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to listen on metrics address %s", metricsListenAddress)
+		}
+	}
 
 	newController, err := createController(kubeconfigPath,
 		namespace,
@@ -70,6 +91,14 @@ func Run(kubeconfigPath string,
 	}
 
 	// TODO: stop
+
+	// serve controller metrics (e.g. function failures by category)
+	if metricsListener != nil {
+		if err := http.Serve(metricsListener, promhttp.Handler()); err != nil {
+			return errors.Wrap(err, "Failed to serve metrics")
+		}
+	}
+
 	select {}
 }
 
@@ -148,8 +177,18 @@ func createController(kubeconfigPath string,
 		return nil, errors.Wrap(err, "Failed to create nuclio client set")
 	}
 
+	metricsClientSet, err := metricsclient.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create metrics client set")
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create dynamic client")
+	}
+
 	// create a client for function deployments
-	functionresClient, err := functionres.NewLazyClient(rootLogger, kubeClientSet, nuclioClientSet)
+	functionresClient, err := functionres.NewLazyClient(rootLogger, kubeClientSet, nuclioClientSet, dynamicClient)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to create function deployment client")
 	}
@@ -171,6 +210,8 @@ func createController(kubeconfigPath string,
 		imagePullSecrets,
 		kubeClientSet,
 		nuclioClientSet,
+		metricsClientSet,
+		dynamicClient,
 		functionresClient,
 		apigatewayresClient,
 		functionOperatorResyncInterval,
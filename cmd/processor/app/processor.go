@@ -19,10 +19,10 @@ package app
 import (
 	"encoding/json"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/nuclio/nuclio/pkg/common"
-	commonhealthcheck "github.com/nuclio/nuclio/pkg/common/healthcheck"
 	"github.com/nuclio/nuclio/pkg/common/status"
 	"github.com/nuclio/nuclio/pkg/functionconfig"
 	"github.com/nuclio/nuclio/pkg/loggersink"
@@ -74,14 +74,20 @@ type Processor struct {
 	functionLogger        logger.Logger
 	triggers              []trigger.Trigger
 	webAdminServer        *webadmin.Server
-	healthCheckServer     commonhealthcheck.Server
+	healthCheckServer     *healthcheck.ProcessorServer
 	metricSinks           []metricsink.MetricSink
 	namedWorkerAllocators map[string]worker.Allocator
 	eventTimeoutWatcher   *timeout.EventTimeoutWatcher
 	startComplete         bool
 	stop                  chan bool
+
+	// names of triggers that start one after the other, each once the triggers started before it are ready
+	orderedTriggerNames map[string]bool
 }
 
+// how long to wait for triggers to become ready before starting the next trigger in the start order
+const triggerReadinessTimeout = 2 * time.Minute
+
 // NewProcessor returns a new Processor
 func NewProcessor(configurationPath string, platformConfigurationPath string) (*Processor, error) {
 	var err error
@@ -140,6 +146,11 @@ func NewProcessor(configurationPath string, platformConfigurationPath string) (*
 		return nil, errors.Wrap(err, "Failed to create triggers")
 	}
 
+	newProcessor.sortTriggers(&processorConfiguration.Spec)
+
+	// report the readiness of each trigger, so that the triggers that are active can be told
+	newProcessor.healthCheckServer.AddTriggerReadinessChecks(newProcessor.triggers)
+
 	if len(processorConfiguration.Spec.EventTimeout) > 0 {
 
 		// This is checked by the configuration reader, but just in case
@@ -175,7 +186,15 @@ func (p *Processor) Start() error {
 	p.logger.DebugWith("Starting triggers", "triggers", p.triggers)
 
 	// iterate over all triggers and start them
-	for _, trigger := range p.triggers {
+	for triggerIndex, trigger := range p.triggers {
+
+		// ordered triggers start only once the triggers before them are ready
+		if p.orderedTriggerNames[trigger.GetID()] {
+			if err := p.waitTriggersReady(p.triggers[:triggerIndex]); err != nil {
+				return errors.Wrapf(err, "Failed to wait for triggers to be ready before starting %s", trigger.GetID())
+			}
+		}
+
 		if err := trigger.Start(nil); err != nil {
 			p.logger.ErrorWith("Failed to start trigger",
 				"kind", trigger.GetKind(),
@@ -328,6 +347,47 @@ func (p *Processor) createTriggers(processorConfiguration *processor.Configurati
 	return triggers, nil
 }
 
+// sorts the triggers by the order they should start in, per the function's trigger start order
+func (p *Processor) sortTriggers(functionSpec *functionconfig.Spec) {
+	p.orderedTriggerNames = map[string]bool{}
+	for _, triggerName := range functionSpec.TriggerStartOrder {
+		p.orderedTriggerNames[triggerName] = true
+	}
+
+	triggerStartIndexes := map[string]int{}
+	for triggerStartIndex, triggerName := range functionSpec.GetTriggerStartOrder() {
+		triggerStartIndexes[triggerName] = triggerStartIndex
+	}
+
+	sort.SliceStable(p.triggers, func(i, j int) bool {
+		return triggerStartIndexes[p.triggers[i].GetID()] < triggerStartIndexes[p.triggers[j].GetID()]
+	})
+}
+
+// waits until all given triggers report they're ready
+func (p *Processor) waitTriggersReady(triggers []trigger.Trigger) error {
+	deadline := time.Now().Add(triggerReadinessTimeout)
+
+	for {
+		ready := true
+		for _, triggerInstance := range triggers {
+			if !triggerInstance.IsReady() {
+				ready = false
+			}
+		}
+
+		if ready {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return errors.Errorf("Triggers were not ready within %s", triggerReadinessTimeout)
+		}
+
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
 func (p *Processor) hasHTTPTrigger(triggers []trigger.Trigger) bool {
 	for _, existingTrigger := range triggers {
 		if existingTrigger.GetKind() == "http" {
@@ -377,7 +437,7 @@ func (p *Processor) createWebAdminServer(platformConfiguration *platformconfig.C
 }
 
 func (p *Processor) createAndStartHealthCheckServer(platformConfiguration *platformconfig.Config) (
-	commonhealthcheck.Server, error) {
+	*healthcheck.ProcessorServer, error) {
 
 	// if enabled not passed, default to true
 	if platformConfiguration.HealthCheck.Enabled == nil {
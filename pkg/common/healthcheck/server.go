@@ -27,6 +27,15 @@ import (
 	"github.com/nuclio/logger"
 )
 
+// the prefix of the names of the processor's per trigger readiness checks
+const triggerReadinessCheckNamePrefix = "trigger_"
+
+// GetTriggerReadinessCheckName returns the name of the processor's readiness check of the trigger, which passes
+// once the trigger is ready to handle events
+func GetTriggerReadinessCheckName(triggerID string) string {
+	return triggerReadinessCheckNamePrefix + triggerID
+}
+
 type Server interface {
 
 	// Start the server
@@ -17,10 +17,16 @@ limitations under the License.
 package functionconfig
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/nuclio/errors"
 	"github.com/v3io/scaler-types"
 	"k8s.io/api/core/v1"
 )
@@ -251,6 +257,7 @@ type Spec struct {
 	DealerURI               string                  `json:"dealerURI,omitempty"`
 	Platform                Platform                `json:"platform,omitempty"`
 	ReadinessTimeoutSeconds int                     `json:"readinessTimeoutSeconds,omitempty"`
+	ProgressDeadlineSeconds int                     `json:"progressDeadlineSeconds,omitempty"`
 	Avatar                  string                  `json:"avatar,omitempty"`
 	ServiceType             v1.ServiceType          `json:"serviceType,omitempty"`
 	ImagePullPolicy         v1.PullPolicy           `json:"imagePullPolicy,omitempty"`
@@ -258,6 +265,128 @@ type Spec struct {
 	ServiceAccount          string                  `json:"serviceAccount,omitempty"`
 	ScaleToZero             *ScaleToZeroSpec        `json:"scaleToZero,omitempty"`
 
+	// Maximum size of a request body (e.g. "10Mi"). Enforced by the ingress controller and by the function's
+	// HTTP trigger. If empty, the platform default (if any) is used
+	MaxRequestBodySize string `json:"maxRequestBodySize,omitempty"`
+
+	// Regions from which the function is served by the global load balancer. If empty, the function is
+	// served from all regions
+	Regions []string `json:"regions,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// a stable name through which the function can be reached from within the namespace, regardless of the
+	// name of its service
+	ServiceAlias string `json:"serviceAlias,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// number of times a pod failing to pull the function's image is deleted (forcing a fresh pull) before
+	// declaring the function unhealthy, and how long a pod may fail pulling before it is deleted.
+	// Default: 0 retries (pods failing to pull are left as is until the readiness timeout), 120 seconds
+	ImagePullRetries        int `json:"imagePullRetries,omitempty"`
+	ImagePullTimeoutSeconds int `json:"imagePullTimeoutSeconds,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// images (e.g. mirrors of the image in other registries) to fail over to, in order, when the function's pods
+	// can't pull the current image. if FallbackImageRollback is set, rolling back to the primary image is
+	// attempted on resyncs, once in a while. Default: empty (no failover)
+	FallbackImages        []string `json:"fallbackImages,omitempty"`
+	FallbackImageRollback bool     `json:"fallbackImageRollback,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// number of times a scheduled pod stuck starting (e.g. in ContainerCreating, on a wedged volume mount) is
+	// deleted (forcing a reschedule) before declaring the function unhealthy, and how long a pod may take to start
+	// before it is deleted. Default: 0 (stuck pods are left as is until the readiness timeout)
+	PodStartupRetries        int `json:"podStartupRetries,omitempty"`
+	PodStartupTimeoutSeconds int `json:"podStartupTimeoutSeconds,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// the runtime class the function's pods run under (e.g. a sandboxed runtime such as gVisor). The readiness and
+	// pod startup timeouts are extended by the platform's runtime class startup timeout factor.
+	// Default: empty (the cluster's default runtime)
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// start the function (when deployed or scaled from zero) at the replica count it has historically run at, as
+	// observed while ready, rather than at MinReplicas. Without history, the function starts at MinReplicas
+	ReplicasFromHistory bool `json:"replicasFromHistory,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// the fraction (e.g. 0.5) of the target replicas that must be ready for scaling up a ready function to
+	// succeed. the rest are left to come up in the background, rather than failing the function if they don't
+	// within the readiness timeout. Default: 0 (all replicas must be ready)
+	ScaleUpMinReadyFraction float64 `json:"scaleUpMinReadyFraction,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// when scaling from zero with the image and configuration it was last ready with, wait only this long for a
+	// replica running the same image to be available before falling back to full readiness verification.
+	// Default: 0 (always verify fully)
+	KnownGoodReadinessTimeoutSeconds int `json:"knownGoodReadinessTimeoutSeconds,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// what to do when the function's ingresses claim paths that overlap those of another function in the namespace,
+	// on the same host - warn (recording the conflict in the status) or reject the function.
+	// Default: empty (not checked)
+	IngressConflictPolicy IngressConflictPolicy `json:"ingressConflictPolicy,omitempty"`
+
+	// Currently relevant only for k8s platform, for functions with NodePort services
+	// allocate the function a node port from the platform's stable node port range, recorded in the status and
+	// kept for as long as the function exists (e.g. across recreations of its service). Can't be set along with
+	// an explicit HTTP trigger port. Default: false (Kubernetes assigns the node port)
+	StableNodePort bool `json:"stableNodePort,omitempty"`
+
+	// names of triggers that start one after the other, in this order, once the triggers not listed have started.
+	// each starts only after the previous one is ready (e.g. a stream consumer after the HTTP endpoint is
+	// serving). Default: empty (all triggers start together)
+	TriggerStartOrder []string `json:"triggerStartOrder,omitempty"`
+
+	// Currently relevant only for k8s platform, for cron triggers that run as k8s cron jobs
+	// how long finished jobs (and their pods) are kept before being deleted, and how many of the most recent
+	// finished jobs are kept. Default: 0 (finished jobs are kept, one successful and one failed)
+	JobTTLSecondsAfterFinished int `json:"jobTTLSecondsAfterFinished,omitempty"`
+	JobRetentionCount          int `json:"jobRetentionCount,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// name of the node pool (as configured in the platform configuration) the function is deployed to. the
+	// function's pods are given tolerations for the pool's taints
+	TargetNodePool string `json:"targetNodePool,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// name of the node capacity tier (as configured in the platform configuration) the function is assigned to.
+	// the function's pods are given the tier's node selector, and the function is rejected if its resource
+	// requests exceed what the tier allows
+	CapacityTier string `json:"capacityTier,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// where TLS is terminated for requests arriving through the function's ingresses. passthrough and reencrypt
+	// have the function serve TLS with the certificate in TLSSecret (a kubernetes.io/tls secret).
+	// Default: terminate (at the ingress)
+	TLSMode   TLSMode `json:"tlsMode,omitempty"`
+	TLSSecret string  `json:"tlsSecret,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// restart the function before its pods are OOMKilled, when their memory usage stays close to the memory
+	// limit. If nil, the function isn't restarted proactively
+	MemoryPressureRestart *MemoryPressureRestartSpec `json:"memoryPressureRestart,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// which replicas the function's service prefers routing in-cluster traffic to. Default: no preference
+	ServiceTopology ServiceTopology `json:"serviceTopology,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// secrets held in an external store (e.g. Vault), synced into kubernetes secrets through the external secrets
+	// operator and mounted into the function's pods
+	ExternalSecrets []ExternalSecretReference `json:"externalSecrets,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// requests sent to the function (in order) when it scales from zero, before it's marked ready, to prime its
+	// caches. if any of them fails, the function keeps warming up and they're retried. Default: empty (the
+	// function is ready as soon as it's available)
+	WarmupRequests []WarmupRequest `json:"warmupRequests,omitempty"`
+
+	// Currently relevant only for k8s platform
+	// authentication required by the function's ingresses. If nil, requests are not authenticated
+	Authentication *Authentication `json:"authentication,omitempty"`
+
 	// Currently relevant only for k8s platform
 	// if true - wait the whole ReadinessTimeoutSeconds before marking this function as unhealthy
 	// otherwise, fail the function instantly when there is indication of deployment failure (e.g. pod stuck on crash
@@ -270,10 +399,223 @@ type Spec struct {
 	EventTimeout string `json:"eventTimeout"`
 }
 
+// ExternalSecretReference references a secret in the platform's external secret store
+type ExternalSecretReference struct {
+
+	// identifies the secret among the function's external secrets
+	Name string `json:"name,omitempty"`
+
+	// the key of the secret in the store. each of its properties is mounted as a file named after the property
+	RemoteKey string `json:"remoteKey,omitempty"`
+
+	// where the secret is mounted in the function's pods
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// WarmupRequest is a request sent to the function to warm it up
+type WarmupRequest struct {
+
+	// defaults to GET
+	Method string `json:"method,omitempty"`
+
+	// the path of the request, relative to the function's root (e.g. /prime?key=value)
+	Path    string            `json:"path,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+
+	// the status code expected in response. defaults to 200
+	ExpectedStatusCode int `json:"expectedStatusCode,omitempty"`
+}
+
+// GetMethod returns the method of the request
+func (wr *WarmupRequest) GetMethod() string {
+	if wr.Method == "" {
+		return http.MethodGet
+	}
+
+	return strings.ToUpper(wr.Method)
+}
+
+// GetExpectedStatusCode returns the status code expected in response to the request
+func (wr *WarmupRequest) GetExpectedStatusCode() int {
+	if wr.ExpectedStatusCode == 0 {
+		return http.StatusOK
+	}
+
+	return wr.ExpectedStatusCode
+}
+
+// ServiceTopology determines which replicas a function's service prefers routing to
+type ServiceTopology string
+
+const (
+
+	// prefer replicas in the same zone as the caller, spreading traffic across zones in proportion to their capacity
+	ServiceTopologyPreferSameZone ServiceTopology = "preferSameZone"
+)
+
+// TLSMode determines where TLS is terminated
+type TLSMode string
+
+const (
+
+	// TLS is terminated at the ingress, which passes requests to the function over plain HTTP
+	TLSModeTerminate TLSMode = "terminate"
+
+	// the ingress passes TLS connections as is, to be terminated by the function
+	TLSModePassthrough TLSMode = "passthrough"
+
+	// TLS is terminated at the ingress, which passes requests to the function over a new TLS connection
+	TLSModeReencrypt TLSMode = "reencrypt"
+)
+
+// IngressConflictPolicy determines what's done when a function's ingresses conflict with another function's
+type IngressConflictPolicy string
+
+const (
+
+	// the conflict is recorded in the function's status, and the function deploys nonetheless
+	IngressConflictPolicyWarn IngressConflictPolicy = "warn"
+
+	// the function fails to deploy until the conflict is resolved
+	IngressConflictPolicyReject IngressConflictPolicy = "reject"
+)
+
+// Authentication configures how requests to the function are authenticated. Only one mode may be set
+type Authentication struct {
+	OIDC *OIDCAuthentication `json:"oidc,omitempty"`
+}
+
+// OIDCAuthentication requires requests to carry a JWT issued by an OIDC provider
+type OIDCAuthentication struct {
+
+	// URL of the issuer, which must match the token's "iss" claim
+	IssuerURL string `json:"issuerURL,omitempty"`
+
+	// the token's "aud" claim must hold at least one of these
+	Audiences []string `json:"audiences,omitempty"`
+
+	// claims the token must carry, with the given values
+	RequiredClaims map[string]string `json:"requiredClaims,omitempty"`
+}
+
+// Possible authentication modes
+const (
+	AuthenticationModeOIDC = "oidc"
+)
+
+// GetAuthenticationMode returns the authentication mode that is set, or an empty string if none is
+func (a *Authentication) GetAuthenticationMode() string {
+	if a == nil {
+		return ""
+	}
+
+	if a.OIDC != nil {
+		return AuthenticationModeOIDC
+	}
+
+	return ""
+}
+
 type ScaleToZeroSpec struct {
 	ScaleResources []ScaleResource `json:"scaleResources,omitempty"`
+
+	// how long (e.g. "10m") scaling to zero is held off after the function is scaled up from zero. empty or "0"
+	// scales down as soon as the scale resources' windows allow
+	ScaleDownStabilizationWindow string `json:"scaleDownStabilizationWindow,omitempty"`
+
+	// how long (e.g. "30s") after a scale event is processed that identical scale events (e.g. a burst of
+	// scale from zero requests) are ignored. Default: 10s. "0" processes all scale events
+	ScaleEventDeduplicationWindow string `json:"scaleEventDeduplicationWindow,omitempty"`
 }
 
+const DefaultScaleEventDeduplicationWindow = 10 * time.Second
+
+// GetScaleDownStabilizationWindow returns the parsed scale down stabilization window, or 0 if none is set
+func (s *ScaleToZeroSpec) GetScaleDownStabilizationWindow() (time.Duration, error) {
+	if s == nil || s.ScaleDownStabilizationWindow == "" {
+		return 0, nil
+	}
+
+	scaleDownStabilizationWindow, err := time.ParseDuration(s.ScaleDownStabilizationWindow)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to parse scale down stabilization window")
+	}
+
+	if scaleDownStabilizationWindow < 0 {
+		return 0, errors.Errorf("Scale down stabilization window must not be negative, got: %s",
+			s.ScaleDownStabilizationWindow)
+	}
+
+	return scaleDownStabilizationWindow, nil
+}
+
+// GetScaleEventDeduplicationWindow returns the parsed scale event deduplication window, or the default if none is set
+func (s *ScaleToZeroSpec) GetScaleEventDeduplicationWindow() (time.Duration, error) {
+	if s == nil || s.ScaleEventDeduplicationWindow == "" {
+		return DefaultScaleEventDeduplicationWindow, nil
+	}
+
+	scaleEventDeduplicationWindow, err := time.ParseDuration(s.ScaleEventDeduplicationWindow)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to parse scale event deduplication window")
+	}
+
+	if scaleEventDeduplicationWindow < 0 {
+		return 0, errors.Errorf("Scale event deduplication window must not be negative, got: %s",
+			s.ScaleEventDeduplicationWindow)
+	}
+
+	return scaleEventDeduplicationWindow, nil
+}
+
+// MemoryPressureRestartSpec configures restarting a function whose memory usage stays close to its limit
+type MemoryPressureRestartSpec struct {
+
+	// the fraction of the memory limit (e.g. 0.9) that a pod's memory usage must exceed. Default: 0.9
+	UsageThreshold float64 `json:"usageThreshold,omitempty"`
+
+	// how long (e.g. "10m") the usage must stay above the threshold before the function is restarted.
+	// Default: 5m
+	SustainedPeriod string `json:"sustainedPeriod,omitempty"`
+}
+
+// GetUsageThreshold returns the usage threshold, or the default if none is set
+func (s *MemoryPressureRestartSpec) GetUsageThreshold() (float64, error) {
+	if s.UsageThreshold == 0 {
+		return DefaultMemoryPressureRestartUsageThreshold, nil
+	}
+
+	if s.UsageThreshold < 0 || s.UsageThreshold > 1 {
+		return 0, errors.Errorf("Usage threshold must be between 0 and 1, got: %v", s.UsageThreshold)
+	}
+
+	return s.UsageThreshold, nil
+}
+
+// GetSustainedPeriod returns the parsed sustained period, or the default if none is set
+func (s *MemoryPressureRestartSpec) GetSustainedPeriod() (time.Duration, error) {
+	if s.SustainedPeriod == "" {
+		return DefaultMemoryPressureRestartSustainedPeriod, nil
+	}
+
+	sustainedPeriod, err := time.ParseDuration(s.SustainedPeriod)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to parse sustained period")
+	}
+
+	if sustainedPeriod < 0 {
+		return 0, errors.Errorf("Sustained period must not be negative, got: %s", s.SustainedPeriod)
+	}
+
+	return sustainedPeriod, nil
+}
+
+const (
+	DefaultMemoryPressureRestartUsageThreshold  = 0.9
+	DefaultMemoryPressureRestartSustainedPeriod = 5 * time.Minute
+)
+
 type ScaleResource struct {
 	MetricName string `json:"metricName,omitempty"`
 	WindowSize string `json:"windowSize,omitempty"`
@@ -340,6 +682,81 @@ func (s *Spec) GetEventTimeout() (time.Duration, error) {
 	return timeout, err
 }
 
+// GetTriggerStartOrder returns the names of the function's triggers in the order they start - those not listed in
+// the trigger start order first (by name), then those listed, in order
+func (s *Spec) GetTriggerStartOrder() []string {
+	orderedTriggerNames := map[string]bool{}
+	for _, triggerName := range s.TriggerStartOrder {
+		orderedTriggerNames[triggerName] = true
+	}
+
+	var triggerNames []string
+	for triggerName := range s.Triggers {
+		if !orderedTriggerNames[triggerName] {
+			triggerNames = append(triggerNames, triggerName)
+		}
+	}
+
+	sort.Strings(triggerNames)
+
+	for _, triggerName := range s.TriggerStartOrder {
+		if _, found := s.Triggers[triggerName]; found {
+			triggerNames = append(triggerNames, triggerName)
+		}
+	}
+
+	return triggerNames
+}
+
+// GetScaleUpMinReadyReplicas returns the number of the target replicas that must be ready for a scale up to succeed
+func (s *Spec) GetScaleUpMinReadyReplicas(targetReplicas int) (int, error) {
+	if s.ScaleUpMinReadyFraction < 0 || s.ScaleUpMinReadyFraction > 1 {
+		return 0, errors.Errorf("Scale up minimum ready fraction must be between 0 and 1, got: %v",
+			s.ScaleUpMinReadyFraction)
+	}
+
+	if s.ScaleUpMinReadyFraction == 0 {
+		return targetReplicas, nil
+	}
+
+	// at least one replica must be ready for the function to serve
+	minReadyReplicas := int(math.Ceil(s.ScaleUpMinReadyFraction * float64(targetReplicas)))
+	if minReadyReplicas < 1 {
+		minReadyReplicas = 1
+	}
+
+	return minReadyReplicas, nil
+}
+
+// GetDerivedHTTPWorkers returns the number of workers for HTTP triggers that don't specify one, derived from the
+// function's CPU request (a worker per requested CPU, rounded up). 0 if the function doesn't request CPU
+func (s *Spec) GetDerivedHTTPWorkers() int {
+	cpuRequest, found := s.Resources.Requests[v1.ResourceCPU]
+	if !found || cpuRequest.IsZero() {
+		return 0
+	}
+
+	return int((cpuRequest.MilliValue() + 999) / 1000)
+}
+
+// GetHTTPWorkers returns the number of workers of the function's HTTP trigger - as specified, or derived from the
+// function's CPU request. 0 if the function has no HTTP trigger, or it specifies none and none can be derived
+func (s *Spec) GetHTTPWorkers() int {
+	for _, trigger := range s.Triggers {
+		if trigger.Kind != "http" {
+			continue
+		}
+
+		if trigger.MaxWorkers != 0 {
+			return trigger.MaxWorkers
+		}
+
+		return s.GetDerivedHTTPWorkers()
+	}
+
+	return 0
+}
+
 // PositiveGPUResourceLimit returns whether gpu is assigned
 func (s *Spec) PositiveGPUResourceLimit() bool {
 	if gpuResourceLimit, found := s.Resources.Limits[NvidiaGPUResourceName]; found {
@@ -514,11 +931,340 @@ type Status struct {
 	HTTPPort    int                      `json:"httpPort,omitempty"`
 	ScaleToZero *ScaleToZeroStatus       `json:"scaleToZero,omitempty"`
 	APIGateways []string                 `json:"apiGateways,omitempty"`
+
+	// the type of the function's service, which determines whether HTTPPort (its node port) is set
+	ServiceType v1.ServiceType `json:"serviceType,omitempty"`
+
+	// time spent on each phase of the last deployment (scheduling, image pull, etc)
+	ProvisioningPhases []ProvisioningPhase `json:"provisioningPhases,omitempty"`
+
+	// number of failed image pulls observed during the current rollout, counting towards its image pull retries,
+	// and the hash of the rollout's pod template
+	ImagePullAttempts        int    `json:"imagePullAttempts,omitempty"`
+	ImagePullPodTemplateHash string `json:"imagePullPodTemplateHash,omitempty"`
+
+	// number of pods stuck starting that were deleted during the last deployment, forcing a reschedule
+	ForcedReschedules int `json:"forcedReschedules,omitempty"`
+
+	// set while scaling up is blocked by the namespace's resource quota, holding the reason
+	ScaleUpBlockedMessage string `json:"scaleUpBlockedMessage,omitempty"`
+
+	// the authentication mode enforced by the function's ingresses, if any
+	AuthenticationMode string `json:"authenticationMode,omitempty"`
+
+	// while unhealthy, the category of the failure that made the function unhealthy
+	UnhealthyCategory UnhealthyCategory `json:"unhealthyCategory,omitempty"`
+
+	// the last time the function was restarted proactively, and why
+	LastRestart *RestartStatus `json:"lastRestart,omitempty"`
+
+	// set while the function is in error because its pods request more than any node can provide, so that it's
+	// re-checked on resyncs (e.g. once larger nodes are added)
+	SchedulingInfeasible bool `json:"schedulingInfeasible,omitempty"`
+
+	// whether each of the function's external secrets was synced from the store
+	ExternalSecrets []ExternalSecretStatus `json:"externalSecrets,omitempty"`
+
+	// set while reconciles of the function are paused, holding why
+	ReconcilePausedReason string `json:"reconcilePausedReason,omitempty"`
+
+	// the replica counts observed while the function was ready, oldest first. recorded for functions that start
+	// at their historical replica count
+	ReplicaHistory []ReplicaObservation `json:"replicaHistory,omitempty"`
+
+	// the names of the triggers that are active, in the order they were started. recorded for functions that
+	// order their triggers' start
+	ActiveTriggers []string `json:"activeTriggers,omitempty"`
+
+	// the outcomes of the most recent runs of the function's cron jobs, most recent first
+	JobRuns []JobRunStatus `json:"jobRuns,omitempty"`
+
+	// the image the function runs, and since when - recorded for functions with fallback images
+	ActiveImage      string     `json:"activeImage,omitempty"`
+	ActiveImageSince *time.Time `json:"activeImageSince,omitempty"`
+
+	// the number of workers of the function's HTTP trigger, as specified or derived from its CPU request
+	HTTPWorkers int `json:"httpWorkers,omitempty"`
+
+	// set while a scale up of the function succeeded with only some of its target replicas ready
+	PartialReadiness *PartialReadinessStatus `json:"partialReadiness,omitempty"`
+
+	// the image and configuration the function was last verified ready with. recorded for functions that
+	// short-circuit readiness when scaling from zero with them
+	KnownGood *KnownGoodStatus `json:"knownGood,omitempty"`
+
+	// set while the function's ingresses conflict with those of other functions, naming them
+	IngressConflict string `json:"ingressConflict,omitempty"`
+
+	// the node port allocated to the function, for functions requesting a stable node port
+	StableNodePort int `json:"stableNodePort,omitempty"`
+
+	// set while a debug sidecar is attached to the function's pods, naming it
+	DebugSidecar string `json:"debugSidecar,omitempty"`
+
+	// the function's state transitions, oldest first
+	Transitions []StateTransition `json:"transitions,omitempty"`
+
+	// the number of history entries (transitions, logs, replica observations) dropped when the status was
+	// compacted, by their kind
+	OmittedHistory map[string]int `json:"omittedHistory,omitempty"`
+}
+
+// StateTransition is a change of the function's state
+type StateTransition struct {
+	From FunctionState `json:"from"`
+	To   FunctionState `json:"to"`
+	Time time.Time     `json:"time,omitempty"`
+}
+
+// KnownGoodStatus identifies a deployment of the function that was verified ready
+type KnownGoodStatus struct {
+
+	// checksum of the function's spec (which includes its image)
+	SpecChecksum string `json:"specChecksum"`
+
+	// the ID (including the digest) of the image the function's pods ran
+	ImageID string `json:"imageID"`
+}
+
+// PartialReadinessStatus holds how many of the function's target replicas are ready
+type PartialReadinessStatus struct {
+	ReadyReplicas  int `json:"readyReplicas"`
+	TargetReplicas int `json:"targetReplicas"`
+}
+
+// JobRunStatus holds the outcome of a finished run of one of the function's cron jobs
+type JobRunStatus struct {
+	Name           string     `json:"name"`
+	Succeeded      bool       `json:"succeeded"`
+	CompletionTime *time.Time `json:"completionTime,omitempty"`
+	Message        string     `json:"message,omitempty"`
+}
+
+// ReplicaObservation is the number of replicas a function was observed running at
+type ReplicaObservation struct {
+	Replicas int       `json:"replicas"`
+	Time     time.Time `json:"time,omitempty"`
+}
+
+const (
+
+	// observations are spaced out so that the history covers a meaningful period, and so that recording them
+	// (which updates the function) doesn't trigger reconciles back to back
+	ReplicaObservationInterval = 10 * time.Minute
+	MaxReplicaObservations     = 36
+)
+
+// AddReplicaObservation records the number of replicas the function runs at, if enough time has passed since the
+// last observation. The oldest observations are dropped beyond MaxReplicaObservations. Returns whether recorded
+func (s *Status) AddReplicaObservation(replicas int, now time.Time) bool {
+	if replicas <= 0 {
+		return false
+	}
+
+	if len(s.ReplicaHistory) > 0 &&
+		now.Sub(s.ReplicaHistory[len(s.ReplicaHistory)-1].Time) < ReplicaObservationInterval {
+		return false
+	}
+
+	s.ReplicaHistory = append(s.ReplicaHistory, ReplicaObservation{
+		Replicas: replicas,
+		Time:     now,
+	})
+
+	if len(s.ReplicaHistory) > MaxReplicaObservations {
+		s.ReplicaHistory = s.ReplicaHistory[len(s.ReplicaHistory)-MaxReplicaObservations:]
+	}
+
+	return true
+}
+
+// GetSteadyStateReplicas returns the replica count the function historically runs at (the median of its
+// replica history), or 0 if it has no history
+func (s *Status) GetSteadyStateReplicas() int {
+	if len(s.ReplicaHistory) == 0 {
+		return 0
+	}
+
+	var replicas []int
+	for _, replicaObservation := range s.ReplicaHistory {
+		replicas = append(replicas, replicaObservation.Replicas)
+	}
+
+	sort.Ints(replicas)
+
+	return replicas[len(replicas)/2]
+}
+
+// the oldest transitions are dropped beyond this, regardless of the platform's status compaction
+const MaxStateTransitions = 20
+
+// RecordStateTransition carries over the transitions and omitted history of the function's previous status (which
+// this status replaces), and records the transition from the previous status' state, if it changed
+func (s *Status) RecordStateTransition(previous *Status, now time.Time) {
+	var omittedHistory map[string]int
+	for kind, count := range previous.OmittedHistory {
+		if omittedHistory == nil {
+			omittedHistory = map[string]int{}
+		}
+
+		omittedHistory[kind] = count
+	}
+
+	s.Transitions = append([]StateTransition(nil), previous.Transitions...)
+	s.OmittedHistory = omittedHistory
+
+	if previous.State != "" && previous.State != s.State {
+		s.Transitions = append(s.Transitions, StateTransition{
+			From: previous.State,
+			To:   s.State,
+			Time: now,
+		})
+	}
+
+	s.omitTransitions(len(s.Transitions) - MaxStateTransitions)
+}
+
+// Compact drops the oldest entries of the status' history (transitions, logs and replica observations), summarizing
+// them in the omitted history, so that each history list has at most maxHistoryLength entries and the status (as
+// JSON) is at most maxSize bytes. If dropping all history isn't enough, the message is truncated. Zero disables
+// either limit
+func (s *Status) Compact(maxHistoryLength int, maxSize int) {
+	if maxHistoryLength > 0 {
+		s.omitTransitions(len(s.Transitions) - maxHistoryLength)
+		s.omitLogs(len(s.Logs) - maxHistoryLength)
+		s.omitReplicaObservations(len(s.ReplicaHistory) - maxHistoryLength)
+	}
+
+	if maxSize <= 0 {
+		return
+	}
+
+	for {
+		marshalledStatus, err := json.Marshal(s)
+		if err != nil || len(marshalledStatus) <= maxSize {
+			return
+		}
+
+		switch {
+		case len(s.Logs) > 0:
+			s.omitLogs(1)
+		case len(s.Transitions) > 0:
+			s.omitTransitions(1)
+		case len(s.ReplicaHistory) > 0:
+			s.omitReplicaObservations(1)
+		case s.Message != "":
+			excessSize := len(marshalledStatus) - maxSize
+			if excessSize >= len(s.Message) {
+				s.Message = ""
+			} else {
+				s.Message = s.Message[:len(s.Message)-excessSize]
+			}
+		default:
+
+			// nothing left to drop
+			return
+		}
+	}
+}
+
+// GetOmittedHistorySummary describes the history dropped when the status was compacted
+// (e.g. "12 earlier error→ready transitions omitted")
+func (s *Status) GetOmittedHistorySummary() []string {
+	var summary []string
+	for kind, count := range s.OmittedHistory {
+		summary = append(summary, fmt.Sprintf("%d earlier %s omitted", count, kind))
+	}
+
+	sort.Strings(summary)
+
+	return summary
+}
+
+func (s *Status) omitTransitions(count int) {
+	if count <= 0 {
+		return
+	}
+
+	for _, transition := range s.Transitions[:count] {
+		s.addOmittedHistory(fmt.Sprintf("%s→%s transitions", transition.From, transition.To), 1)
+	}
+
+	s.Transitions = s.Transitions[count:]
+}
+
+func (s *Status) omitLogs(count int) {
+	if count <= 0 {
+		return
+	}
+
+	s.addOmittedHistory("logs", count)
+	s.Logs = s.Logs[count:]
+}
+
+func (s *Status) omitReplicaObservations(count int) {
+	if count <= 0 {
+		return
+	}
+
+	s.addOmittedHistory("replica observations", count)
+	s.ReplicaHistory = s.ReplicaHistory[count:]
+}
+
+func (s *Status) addOmittedHistory(kind string, count int) {
+	if s.OmittedHistory == nil {
+		s.OmittedHistory = map[string]int{}
+	}
+
+	s.OmittedHistory[kind] += count
+}
+
+// ExternalSecretStatus holds the sync status of one of the function's external secrets
+type ExternalSecretStatus struct {
+	Name    string `json:"name,omitempty"`
+	Synced  bool   `json:"synced"`
+	Message string `json:"message,omitempty"`
+}
+
+// RestartStatus describes a proactive restart of a function
+type RestartStatus struct {
+	Time   time.Time `json:"time,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// UnhealthyCategory classifies why a function became unhealthy
+type UnhealthyCategory string
+
+const (
+	UnhealthyCategoryImage      UnhealthyCategory = "image"
+	UnhealthyCategoryScheduling UnhealthyCategory = "scheduling"
+	UnhealthyCategoryCrash      UnhealthyCategory = "crash"
+	UnhealthyCategoryProbe      UnhealthyCategory = "probe"
+	UnhealthyCategoryQuota      UnhealthyCategory = "quota"
+	UnhealthyCategoryUnknown    UnhealthyCategory = "unknown"
+)
+
+// Possible provisioning phases, in the order in which they occur
+const (
+	ProvisioningPhaseScheduling     = "scheduling"
+	ProvisioningPhaseImagePull      = "imagePull"
+	ProvisioningPhaseContainerStart = "containerStart"
+	ProvisioningPhaseReadiness      = "readiness"
+)
+
+// ProvisioningPhase holds the time a function's pod spent on a single phase of becoming available
+type ProvisioningPhase struct {
+	Name      string `json:"name,omitempty"`
+	Duration  string `json:"duration,omitempty"`
+	Completed bool   `json:"completed,omitempty"`
 }
 
 type ScaleToZeroStatus struct {
 	LastScaleEvent     scaler_types.ScaleEvent `json:"lastScaleEvent,omitempty"`
 	LastScaleEventTime *time.Time              `json:"lastScaleEventTime,omitempty"`
+
+	// the function won't be scaled to zero before this time, per its scale down stabilization window
+	ScaleDownStabilizedUntil *time.Time `json:"scaleDownStabilizedUntil,omitempty"`
 }
 
 // DeepCopyInto copies to appease k8s
@@ -255,6 +255,10 @@ func (ap *Platform) enrichDefaultHTTPTrigger(functionConfig *functionconfig.Conf
 	}
 
 	defaultHTTPTrigger := functionconfig.GetDefaultHTTPTrigger()
+
+	// leave the workers to be derived from the function's CPU request, if any
+	defaultHTTPTrigger.MaxWorkers = 0
+
 	functionConfig.Spec.Triggers[defaultHTTPTrigger.Name] = defaultHTTPTrigger
 }
 
@@ -1172,8 +1176,12 @@ func (ap *Platform) enrichTriggers(functionConfig *functionconfig.Config) error
 			triggerInstance.Name = triggerName
 		}
 
-		// ensure having max workers
+		// ensure having max workers. http triggers get a worker per CPU the function requests
 		if common.StringInSlice(triggerInstance.Kind, []string{"http", "v3ioStream"}) {
+			if triggerInstance.MaxWorkers == 0 && triggerInstance.Kind == "http" {
+				triggerInstance.MaxWorkers = functionConfig.Spec.GetDerivedHTTPWorkers()
+			}
+
 			if triggerInstance.MaxWorkers == 0 {
 				triggerInstance.MaxWorkers = 1
 			}
@@ -41,6 +41,8 @@ import (
 	"github.com/rs/xid"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
@@ -721,6 +723,7 @@ func (suite *AbstractPlatformTestSuite) TestMinMaxReplicas() {
 func (suite *AbstractPlatformTestSuite) TestEnrichAndValidateFunctionTriggers() {
 	for idx, testCase := range []struct {
 		triggers                 map[string]functionconfig.Trigger
+		resources                v1.ResourceRequirements
 		expectedEnrichedTriggers map[string]functionconfig.Trigger
 		shouldFailValidation     bool
 	}{
@@ -753,6 +756,66 @@ func (suite *AbstractPlatformTestSuite) TestEnrichAndValidateFunctionTriggers()
 			}(),
 		},
 
+		// derive http workers from the CPU request (a worker per CPU, rounded up)
+		{
+			triggers: map[string]functionconfig.Trigger{
+				"some-trigger": {
+					Kind: "http",
+				},
+			},
+			resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("1500m"),
+				},
+			},
+			expectedEnrichedTriggers: map[string]functionconfig.Trigger{
+				"some-trigger": {
+					Kind:       "http",
+					MaxWorkers: 2,
+					Name:       "some-trigger",
+				},
+			},
+		},
+
+		// including the default http trigger's
+		{
+			triggers: nil,
+			resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("3"),
+				},
+			},
+			expectedEnrichedTriggers: func() map[string]functionconfig.Trigger {
+				defaultHTTPTrigger := functionconfig.GetDefaultHTTPTrigger()
+				defaultHTTPTrigger.MaxWorkers = 3
+				return map[string]functionconfig.Trigger{
+					defaultHTTPTrigger.Name: defaultHTTPTrigger,
+				}
+			}(),
+		},
+
+		// but not over those specified
+		{
+			triggers: map[string]functionconfig.Trigger{
+				"some-trigger": {
+					Kind:       "http",
+					MaxWorkers: 8,
+				},
+			},
+			resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("2"),
+				},
+			},
+			expectedEnrichedTriggers: map[string]functionconfig.Trigger{
+				"some-trigger": {
+					Kind:       "http",
+					MaxWorkers: 8,
+					Name:       "some-trigger",
+				},
+			},
+		},
+
 		// do not allow more than 1 http trigger
 		{
 			triggers: map[string]functionconfig.Trigger{
@@ -810,6 +873,7 @@ func (suite *AbstractPlatformTestSuite) TestEnrichAndValidateFunctionTriggers()
 			"nuclio.io/project-name": platform.DefaultProjectName,
 		}
 		createFunctionOptions.FunctionConfig.Spec.Triggers = testCase.triggers
+		createFunctionOptions.FunctionConfig.Spec.Resources = testCase.resources
 		suite.Logger.DebugWith("Checking function ", "functionName", functionName)
 
 		err := suite.Platform.EnrichFunctionConfig(&createFunctionOptions.FunctionConfig)
@@ -44,6 +44,18 @@ func (nf *NuclioFunction) GetComputedReplicas() *int32 {
 		nf.Status.State == functionconfig.FunctionStateWaitingForScaleResourcesFromZero {
 		minReplicas := nf.GetComputedMinReplicas()
 
+		// start busy functions at the replica count they historically run at, within bounds
+		if nf.Spec.ReplicasFromHistory {
+			steadyStateReplicas := int32(nf.Status.GetSteadyStateReplicas())
+			if maxReplicas := nf.GetComputedMaxReplicas(); steadyStateReplicas > maxReplicas {
+				steadyStateReplicas = maxReplicas
+			}
+
+			if steadyStateReplicas > minReplicas {
+				return &steadyStateReplicas
+			}
+		}
+
 		if minReplicas > 0 {
 			return &minReplicas
 		}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/nuclio/nuclio/pkg/platform/kube/apigatewayres"
 	nuclioioclient "github.com/nuclio/nuclio/pkg/platform/kube/client/clientset/versioned"
+	"github.com/nuclio/nuclio/pkg/platform/kube/discovery"
 	"github.com/nuclio/nuclio/pkg/platform/kube/functionres"
 	"github.com/nuclio/nuclio/pkg/platform/kube/monitoring"
 	"github.com/nuclio/nuclio/pkg/platformconfig"
@@ -28,7 +29,11 @@ import (
 	"github.com/nuclio/errors"
 	"github.com/nuclio/logger"
 	"github.com/v3io/version-go"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 type Controller struct {
@@ -51,8 +56,22 @@ type Controller struct {
 
 	// monitors
 	cronJobMonitoring          *CronJobMonitoring
+	orphanedResourcesCleanup   *OrphanedResourcesCleanup
 	functionMonitoring         *monitoring.FunctionMonitor
 	functionMonitoringInterval time.Duration
+	memoryPressureMonitoring   *monitoring.MemoryPressureMonitor
+
+	// notified on function state transitions
+	functionStateTransitionHooks []functionStateTransitionHook
+
+	// report whether the operators' caches have synced
+	cachesSynced []cache.InformerSynced
+
+	// archives functions' state before they're deleted
+	functionSnapshotter *functionSnapshotter
+
+	// pauses function reconciles while the function CRD is migrated, if enabled
+	crdMigration *CRDMigration
 }
 
 func NewController(parentLogger logger.Logger,
@@ -60,6 +79,8 @@ func NewController(parentLogger logger.Logger,
 	imagePullSecrets string,
 	kubeClientSet kubernetes.Interface,
 	nuclioClientSet nuclioioclient.Interface,
+	metricsClientSet metricsclient.Interface,
+	dynamicClient dynamic.Interface,
 	functionresClient functionres.Client,
 	apigatewayresClient apigatewayres.Client,
 	resyncInterval time.Duration,
@@ -100,6 +121,25 @@ func NewController(parentLogger logger.Logger,
 	// stuff when creating stuff)
 	functionresClient.SetPlatformConfigurationProvider(newController)
 
+	// lookups across functions (e.g. of the hosts their ingresses use) are limited to the functions we watch
+	functionresClient.SetWatchedNamespace(namespace)
+
+	newController.functionStateTransitionHooks, err = newFunctionStateTransitionHooks(parentLogger,
+		platformConfiguration)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create function state transition hooks")
+	}
+
+	// snapshot functions before deleting them, if a sink is configured
+	if platformConfiguration.FunctionSnapshot.Kind != "" {
+		newController.functionSnapshotter, err = newFunctionSnapshotter(parentLogger,
+			kubeClientSet,
+			&platformConfiguration.FunctionSnapshot)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create function snapshotter")
+		}
+	}
+
 	// create a function operator
 	newController.functionOperator, err = newFunctionOperator(parentLogger,
 		newController,
@@ -140,6 +180,14 @@ func NewController(parentLogger logger.Logger,
 		return nil, errors.Wrap(err, "Failed to create api gateway operator")
 	}
 
+	// function resources relate to the other resources (e.g. api gateways), so reconcile only once all have synced
+	newController.cachesSynced = []cache.InformerSynced{
+		newController.functionOperator.operator.HasSynced,
+		newController.functionEventOperator.operator.HasSynced,
+		newController.projectOperator.operator.HasSynced,
+		newController.apiGatewayOperator.operator.HasSynced,
+	}
+
 	newController.functionMonitoring, err = monitoring.NewFunctionMonitor(parentLogger,
 		namespace,
 		kubeClientSet,
@@ -149,6 +197,19 @@ func NewController(parentLogger logger.Logger,
 		return nil, errors.Wrap(err, "Failed to create function monitor")
 	}
 
+	// restarting functions under memory pressure requires the metrics API
+	if metricsClientSet != nil {
+		newController.memoryPressureMonitoring, err = monitoring.NewMemoryPressureMonitor(parentLogger,
+			namespace,
+			nuclioClientSet,
+			metricsClientSet,
+			functionresClient,
+			functionMonitoringInterval)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create memory pressure monitor")
+		}
+	}
+
 	// create cron job monitoring
 	if platformConfiguration.CronTriggerCreationMode == platformconfig.KubeCronTriggerCreationMode {
 		newController.cronJobMonitoring = NewCronJobMonitoring(parentLogger,
@@ -156,12 +217,66 @@ func NewController(parentLogger logger.Logger,
 			&cronJobStaleResourcesCleanupInterval)
 	}
 
+	// create CRD migration
+	if platformConfiguration.Kube.CRDMigration.Enabled {
+		newController.crdMigration, err = NewCRDMigration(parentLogger, newController, dynamicClient)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create CRD migration")
+		}
+	}
+
+	// create orphaned resources cleanup
+	if platformConfiguration.Kube.OrphanedResourcesCleanup.Enabled {
+		newController.orphanedResourcesCleanup, err = NewOrphanedResourcesCleanup(parentLogger, newController)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create orphaned resources cleanup")
+		}
+	}
+
 	return newController, nil
 }
 
+// newFunctionStateTransitionHooks creates the hooks the platform configuration enables, if any
+func newFunctionStateTransitionHooks(parentLogger logger.Logger,
+	platformConfiguration *platformconfig.Config) ([]functionStateTransitionHook, error) {
+	var functionStateTransitionHooks []functionStateTransitionHook
+
+	// register ready functions in the external service discovery registry, if one is configured
+	if platformConfiguration.ServiceDiscovery.Kind != "" {
+		registrar, err := discovery.RegistrySingleton.NewRegistrar(parentLogger,
+			&platformConfiguration.ServiceDiscovery)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create service discovery registrar")
+		}
+
+		functionStateTransitionHooks = append(functionStateTransitionHooks, newDiscoveryHook(parentLogger, registrar))
+	}
+
+	// post function state transitions to a webhook, if one is configured
+	if platformConfiguration.FunctionStateWebhook.URL != "" {
+		webhookHook, err := newWebhookHook(parentLogger, &platformConfiguration.FunctionStateWebhook)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create function state webhook")
+		}
+
+		functionStateTransitionHooks = append(functionStateTransitionHooks, webhookHook)
+	}
+
+	return functionStateTransitionHooks, nil
+}
+
 func (c *Controller) Start() error {
 	c.logger.InfoWith("Starting", "namespace", c.namespace)
 
+	// start the function resources' caches, which live as long as the controller does
+	c.functionresClient.StartCaches(wait.NeverStop)
+
+	if c.crdMigration != nil {
+
+		// start CRD migration, before functions are reconciled
+		c.crdMigration.start()
+	}
+
 	// start the function operator
 	if err := c.functionOperator.start(); err != nil {
 		return errors.Wrap(err, "Failed to start function operator")
@@ -187,12 +302,26 @@ func (c *Controller) Start() error {
 		return errors.Wrap(err, "Failed to start function monitor")
 	}
 
+	if c.memoryPressureMonitoring != nil {
+
+		// start memory pressure monitor
+		if err := c.memoryPressureMonitoring.Start(); err != nil {
+			return errors.Wrap(err, "Failed to start memory pressure monitor")
+		}
+	}
+
 	if c.cronJobMonitoring != nil {
 
 		// start cron job monitoring
 		c.cronJobMonitoring.start()
 	}
 
+	if c.orphanedResourcesCleanup != nil {
+
+		// start orphaned resources cleanup
+		c.orphanedResourcesCleanup.start()
+	}
+
 	return nil
 }
 
@@ -204,11 +333,37 @@ func (c *Controller) Stop() error {
 		c.cronJobMonitoring.stop()
 	}
 
+	// stop orphaned resources cleanup
+	if c.orphanedResourcesCleanup != nil {
+		c.orphanedResourcesCleanup.stop()
+	}
+
+	// stop CRD migration
+	if c.crdMigration != nil {
+		c.crdMigration.stop()
+	}
+
+	// stop memory pressure monitor
+	if c.memoryPressureMonitoring != nil {
+		c.memoryPressureMonitoring.Stop()
+	}
+
 	// stop function monitor
 	c.functionMonitoring.Stop()
 	return nil
 }
 
+// hasSynced returns whether the caches of all operators have synced
+func (c *Controller) hasSynced() bool {
+	for _, cacheSynced := range c.cachesSynced {
+		if !cacheSynced() {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (c *Controller) GetPlatformConfiguration() *platformconfig.Config {
 	return c.platformConfiguration
 }
@@ -0,0 +1,283 @@
+package controller
+
+import (
+	"context"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	defaultCRDMigrationCheckInterval = 30 * time.Second
+	nuclioFunctionCRDName            = "nucliofunctions.nuclio.io"
+
+	// recorded in the status of functions whose reconciles are paused during the migration
+	crdMigrationPausedReason = "Paused for CRD migration"
+)
+
+// the CRD API versions the function CRD is read through, most preferred first. clusters older than 1.16 only serve
+// apiextensions.k8s.io/v1beta1
+var customResourceDefinitionResources = []schema.GroupVersionResource{
+	{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	},
+	{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1beta1",
+		Resource: "customresourcedefinitions",
+	},
+}
+
+// CRDMigration pauses function reconciles while the NuclioFunction CRD is migrated to a new version, as reconciling
+// against the old schema mid-migration may corrupt functions. the migration is considered in progress until the
+// CRD serves and stores the target version, and no objects remain stored in other versions. once complete,
+// paused functions are resumed
+type CRDMigration struct {
+	logger        logger.Logger
+	controller    *Controller
+	dynamicClient dynamic.Interface
+	targetVersion string
+	interval      time.Duration
+	stopChan      chan struct{}
+
+	// 1 while the migration is in progress. starts as not in progress, as failing to tell whether it is (e.g. the
+	// CRD can't be read) mustn't pause reconciles indefinitely
+	inProgress int32
+}
+
+func NewCRDMigration(parentLogger logger.Logger,
+	controller *Controller,
+	dynamicClient dynamic.Interface) (*CRDMigration, error) {
+	configuration := controller.platformConfiguration.Kube.CRDMigration
+
+	if configuration.TargetVersion == "" {
+		return nil, errors.New("CRD migration requires a target version")
+	}
+
+	if dynamicClient == nil {
+		return nil, errors.New("CRD migration requires a dynamic client")
+	}
+
+	newCRDMigration := &CRDMigration{
+		logger:        parentLogger.GetChild("crd_migration"),
+		controller:    controller,
+		dynamicClient: dynamicClient,
+		targetVersion: configuration.TargetVersion,
+		interval:      defaultCRDMigrationCheckInterval,
+	}
+
+	if configuration.CheckInterval != "" {
+		interval, err := time.ParseDuration(configuration.CheckInterval)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to parse CRD migration check interval")
+		}
+
+		newCRDMigration.interval = interval
+	}
+
+	parentLogger.DebugWith("Successfully created CRD migration instance",
+		"targetVersion", newCRDMigration.targetVersion,
+		"interval", newCRDMigration.interval)
+
+	return newCRDMigration, nil
+}
+
+// isInProgress returns whether function reconciles should be paused
+func (cm *CRDMigration) isInProgress() bool {
+	return atomic.LoadInt32(&cm.inProgress) == 1
+}
+
+func (cm *CRDMigration) start() {
+
+	// check before the operators start reconciling, so that functions aren't reconciled mid migration
+	if err := cm.check(context.Background()); err != nil {
+		cm.logger.WarnWith("Failed to check CRD migration, not pausing reconciles",
+			"err", errors.Cause(err))
+	}
+
+	// create stop channel
+	cm.stopChan = make(chan struct{}, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				callStack := debug.Stack()
+				cm.logger.ErrorWith("Panic caught while checking CRD migration",
+					"err", err,
+					"stack", string(callStack))
+			}
+		}()
+
+		cm.logger.InfoWith("Starting CRD migration check loop", "interval", cm.interval)
+		for {
+			select {
+			case <-time.After(cm.interval):
+				if err := cm.check(context.Background()); err != nil {
+					cm.logger.WarnWith("Failed to check CRD migration, not pausing reconciles",
+						"err", errors.Cause(err))
+				}
+
+			case <-cm.stopChan:
+				cm.logger.Debug("Stopped CRD migration check")
+				return
+			}
+		}
+	}()
+}
+
+func (cm *CRDMigration) stop() {
+	cm.logger.Info("Stopping CRD migration check")
+
+	// post to channel
+	if cm.stopChan != nil {
+		cm.stopChan <- struct{}{}
+	}
+}
+
+// check updates whether the migration is in progress, resuming paused functions once it completes. if the CRD can't
+// be read, reconciles aren't paused
+func (cm *CRDMigration) check(ctx context.Context) error {
+	migrated, err := cm.isFunctionCRDMigrated()
+	if err != nil {
+
+		// fail open, rather than keep functions paused until the CRD can be read
+		if atomic.SwapInt32(&cm.inProgress, 0) == 1 {
+			cm.logger.WarnWith("Failed to read function CRD, resuming function reconciles",
+				"err", errors.Cause(err))
+
+			if err := cm.resumePausedFunctions(); err != nil {
+				cm.logger.WarnWith("Failed to resume paused functions", "err", errors.Cause(err))
+			}
+		}
+
+		return err
+	}
+
+	if !migrated {
+		if atomic.SwapInt32(&cm.inProgress, 1) == 0 {
+			cm.logger.InfoWith("CRD migration in progress, pausing function reconciles",
+				"targetVersion", cm.targetVersion)
+		}
+
+		return nil
+	}
+
+	if atomic.SwapInt32(&cm.inProgress, 0) == 0 {
+		return nil
+	}
+
+	cm.logger.InfoWith("CRD migration completed, resuming function reconciles",
+		"targetVersion", cm.targetVersion)
+
+	return cm.resumePausedFunctions()
+}
+
+// isFunctionCRDMigrated reads the function CRD, through the first CRD API version the cluster serves, and returns
+// whether it's migrated
+func (cm *CRDMigration) isFunctionCRDMigrated() (bool, error) {
+	var crd *unstructured.Unstructured
+	var err error
+
+	for _, customResourceDefinitionResource := range customResourceDefinitionResources {
+		crd, err = cm.dynamicClient.Resource(customResourceDefinitionResource).
+			Get(nuclioFunctionCRDName, metav1.GetOptions{})
+		if err == nil || !apierrors.IsNotFound(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to get function CRD")
+	}
+
+	migrated, err := cm.isMigrated(crd)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to read function CRD versions")
+	}
+
+	return migrated, nil
+}
+
+// isMigrated returns whether the CRD serves and stores the target version, and all objects are stored in it
+func (cm *CRDMigration) isMigrated(crd *unstructured.Unstructured) (bool, error) {
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to get versions")
+	}
+
+	// apiextensions.k8s.io/v1beta1 CRDs may only declare a single, served and stored, version
+	if len(versions) == 0 {
+		version, _, err := unstructured.NestedString(crd.Object, "spec", "version")
+		if err != nil {
+			return false, errors.Wrap(err, "Failed to get version")
+		}
+
+		versions = []interface{}{
+			map[string]interface{}{"name": version, "served": true, "storage": true},
+		}
+	}
+
+	targetVersionServedAndStored := false
+	for _, version := range versions {
+		versionFields, isMap := version.(map[string]interface{})
+		if !isMap || versionFields["name"] != cm.targetVersion {
+			continue
+		}
+
+		targetVersionServedAndStored = versionFields["served"] == true && versionFields["storage"] == true
+	}
+
+	storedVersions, _, err := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to get stored versions")
+	}
+
+	// objects stored in previous versions are only gone once the migration removed them from the stored versions
+	onlyTargetVersionStored := len(storedVersions) == 1 && storedVersions[0] == cm.targetVersion
+
+	return targetVersionServedAndStored && onlyTargetVersionStored, nil
+}
+
+func (cm *CRDMigration) resumePausedFunctions() error {
+	functions, err := cm.controller.nuclioClientSet.NuclioV1beta1().
+		NuclioFunctions(cm.controller.namespace).
+		List(metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list functions")
+	}
+
+	for _, function := range functions.Items {
+		function := function
+		if function.Status.ReconcilePausedReason != crdMigrationPausedReason {
+			continue
+		}
+
+		// the update is picked up as any other, reconciling the function
+		function.Status.ReconcilePausedReason = ""
+		if _, err := cm.controller.nuclioClientSet.NuclioV1beta1().
+			NuclioFunctions(function.Namespace).
+			Update(&function); err != nil {
+			cm.logger.WarnWith("Failed to resume function",
+				"namespace", function.Namespace,
+				"name", function.Name,
+				"err", err)
+			continue
+		}
+
+		cm.logger.DebugWith("Resumed function",
+			"namespace", function.Namespace,
+			"name", function.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+	"github.com/nuclio/nuclio/pkg/platform/kube/discovery"
+
+	"github.com/nuclio/logger"
+)
+
+const (
+	discoveryRetryDuration  = 5 * time.Minute
+	discoveryRetryInterval  = 10 * time.Second
+	discoveryRequestTimeout = 30 * time.Second
+)
+
+// discoveryHook registers ready functions in an external service discovery registry, and deregisters them
+// once they become unhealthy or are deleted. registry failures are retried in the background and never affect
+// the function's state. operations are serialized per function, and only its latest one is carried out - an
+// operation still being retried when a newer one arrives is cancelled
+type discoveryHook struct {
+	logger        logger.Logger
+	registrar     discovery.Registrar
+	retryInterval time.Duration
+
+	// per function (namespace/name), its operation queue. removed once drained
+	queues     map[string]*discoveryQueue
+	queuesLock sync.Mutex
+}
+
+// discoveryQueue holds a function's latest pending operation. guarded by the hook's queues lock
+type discoveryQueue struct {
+	pending *discoveryOperation
+
+	// cancels the operation being carried out
+	cancel context.CancelFunc
+}
+
+type discoveryOperation struct {
+	name         string
+	registration *discovery.Registration
+	handler      func(context.Context, *discovery.Registration) error
+}
+
+func newDiscoveryHook(parentLogger logger.Logger, registrar discovery.Registrar) *discoveryHook {
+	return &discoveryHook{
+		logger:        parentLogger.GetChild("discovery"),
+		registrar:     registrar,
+		retryInterval: discoveryRetryInterval,
+		queues:        map[string]*discoveryQueue{},
+	}
+}
+
+func (dh *discoveryHook) OnFunctionStateTransition(function *nuclioio.NuclioFunction,
+	previousState functionconfig.FunctionState) {
+
+	switch function.Status.State {
+	case functionconfig.FunctionStateReady:
+		registration := dh.getRegistration(function.Namespace, function.Name)
+		registration.Healthy = true
+
+		dh.enqueue(&discoveryOperation{
+			name:         "register",
+			registration: registration,
+			handler:      dh.registrar.Register,
+		})
+
+	case functionconfig.FunctionStateUnhealthy:
+
+		// nothing to deregister if the function never got to be ready
+		if previousState != functionconfig.FunctionStateReady {
+			return
+		}
+
+		dh.enqueue(&discoveryOperation{
+			name:         "deregister",
+			registration: dh.getRegistration(function.Namespace, function.Name),
+			handler:      dh.registrar.Deregister,
+		})
+	}
+}
+
+func (dh *discoveryHook) OnFunctionDeleted(namespace string, name string) {
+	dh.enqueue(&discoveryOperation{
+		name:         "deregister",
+		registration: dh.getRegistration(namespace, name),
+		handler:      dh.registrar.Deregister,
+	})
+}
+
+func (dh *discoveryHook) getRegistration(namespace string, name string) *discovery.Registration {
+	host, port := kube.GetDomainNameInvokeURL(kube.ServiceNameFromFunctionName(name), namespace)
+
+	return &discovery.Registration{
+		Namespace: namespace,
+		Name:      name,
+		Host:      host,
+		Port:      port,
+	}
+}
+
+// enqueue replaces the function's pending operation, cancelling the one being carried out. the function's queue is
+// drained in the background
+func (dh *discoveryHook) enqueue(operation *discoveryOperation) {
+	key := fmt.Sprintf("%s/%s", operation.registration.Namespace, operation.registration.Name)
+
+	dh.queuesLock.Lock()
+	defer dh.queuesLock.Unlock()
+
+	queue, queueFound := dh.queues[key]
+	if !queueFound {
+		queue = &discoveryQueue{}
+		dh.queues[key] = queue
+
+		go dh.drain(key, queue)
+	}
+
+	queue.pending = operation
+	if queue.cancel != nil {
+		queue.cancel()
+	}
+}
+
+func (dh *discoveryHook) drain(key string, queue *discoveryQueue) {
+	for {
+		dh.queuesLock.Lock()
+		operation := queue.pending
+		if operation == nil {
+			delete(dh.queues, key)
+			dh.queuesLock.Unlock()
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		queue.pending = nil
+		queue.cancel = cancel
+		dh.queuesLock.Unlock()
+
+		dh.retry(ctx, operation)
+		cancel()
+	}
+}
+
+// retry carries out the operation until it succeeds, gives up or is cancelled
+func (dh *discoveryHook) retry(ctx context.Context, operation *discoveryOperation) {
+	deadline := time.Now().Add(discoveryRetryDuration)
+
+	for {
+		requestCtx, cancelRequest := context.WithTimeout(ctx, discoveryRequestTimeout)
+		err := operation.handler(requestCtx, operation.registration)
+		cancelRequest()
+
+		if err == nil {
+			return
+		}
+
+		if ctx.Err() != nil {
+			dh.logger.DebugWith("Service discovery operation superseded",
+				"operation", operation.name,
+				"namespace", operation.registration.Namespace,
+				"name", operation.registration.Name)
+			return
+		}
+
+		if time.Now().After(deadline) {
+			dh.logger.WarnWith("Giving up on service discovery operation",
+				"operation", operation.name,
+				"namespace", operation.registration.Namespace,
+				"name", operation.registration.Name,
+				"err", err)
+			return
+		}
+
+		dh.logger.WarnWith("Service discovery operation failed, retrying",
+			"operation", operation.name,
+			"namespace", operation.registration.Namespace,
+			"name", operation.registration.Name,
+			"err", err)
+
+		select {
+		case <-time.After(dh.retryInterval):
+		case <-ctx.Done():
+			dh.logger.DebugWith("Service discovery operation superseded",
+				"operation", operation.name,
+				"namespace", operation.registration.Namespace,
+				"name", operation.registration.Name)
+			return
+		}
+	}
+}
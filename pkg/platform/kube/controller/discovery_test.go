@@ -0,0 +1,293 @@
+// +build test_unit
+
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+	"github.com/nuclio/nuclio/pkg/platform/kube/discovery"
+	_ "github.com/nuclio/nuclio/pkg/platform/kube/discovery/consul"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+	nucliozap "github.com/nuclio/zap"
+	"github.com/stretchr/testify/suite"
+)
+
+type DiscoveryHookTestSuite struct {
+	suite.Suite
+	logger    logger.Logger
+	registrar *recordingRegistrar
+	hook      *discoveryHook
+}
+
+func (suite *DiscoveryHookTestSuite) SetupTest() {
+	var err error
+
+	suite.logger, err = nucliozap.NewNuclioZapTest("test")
+	suite.Require().NoError(err)
+
+	suite.registrar = newRecordingRegistrar()
+	suite.hook = newDiscoveryHook(suite.logger, suite.registrar)
+	suite.hook.retryInterval = 10 * time.Millisecond
+}
+
+func (suite *DiscoveryHookTestSuite) TestRegisterOnReady() {
+	functionInstance := suite.createFunction(functionconfig.FunctionStateReady)
+	suite.hook.OnFunctionStateTransition(functionInstance,
+		functionconfig.FunctionStateWaitingForResourceConfiguration)
+
+	registration := suite.waitForRegistration(suite.registrar.registered)
+	expectedHost, expectedPort := kube.GetDomainNameInvokeURL(
+		kube.ServiceNameFromFunctionName(functionInstance.Name),
+		functionInstance.Namespace)
+
+	suite.Require().Equal(functionInstance.Namespace, registration.Namespace)
+	suite.Require().Equal(functionInstance.Name, registration.Name)
+	suite.Require().Equal(expectedHost, registration.Host)
+	suite.Require().Equal(expectedPort, registration.Port)
+	suite.Require().True(registration.Healthy)
+	suite.requireNoRegistration(suite.registrar.deregistered)
+}
+
+func (suite *DiscoveryHookTestSuite) TestDeregisterOnUnhealthy() {
+
+	// functions that never got to be ready were never registered
+	functionInstance := suite.createFunction(functionconfig.FunctionStateUnhealthy)
+	suite.hook.OnFunctionStateTransition(functionInstance,
+		functionconfig.FunctionStateWaitingForResourceConfiguration)
+	suite.requireNoRegistration(suite.registrar.deregistered)
+
+	// ready functions turning unhealthy are deregistered
+	suite.hook.OnFunctionStateTransition(functionInstance, functionconfig.FunctionStateReady)
+
+	registration := suite.waitForRegistration(suite.registrar.deregistered)
+	suite.Require().Equal(functionInstance.Name, registration.Name)
+	suite.requireNoRegistration(suite.registrar.registered)
+}
+
+func (suite *DiscoveryHookTestSuite) TestDeregisterOnDelete() {
+	suite.hook.OnFunctionDeleted("func-namespace", "func-name")
+
+	registration := suite.waitForRegistration(suite.registrar.deregistered)
+	suite.Require().Equal("func-namespace", registration.Namespace)
+	suite.Require().Equal("func-name", registration.Name)
+	suite.Require().Equal("func-namespace-func-name", registration.ID())
+}
+
+func (suite *DiscoveryHookTestSuite) TestRetryWithoutBlocking() {
+	suite.registrar.failures = 2
+
+	// the registry being down doesn't hold up the transition
+	functionInstance := suite.createFunction(functionconfig.FunctionStateReady)
+	transitionStartTime := time.Now()
+	suite.hook.OnFunctionStateTransition(functionInstance,
+		functionconfig.FunctionStateWaitingForResourceConfiguration)
+	suite.Require().True(time.Since(transitionStartTime) < suite.hook.retryInterval)
+
+	// and registering is retried until it succeeds
+	suite.waitForRegistration(suite.registrar.registered)
+	suite.Require().Equal(3, suite.registrar.getCalls())
+}
+
+func (suite *DiscoveryHookTestSuite) TestFlappingFunction() {
+	release := make(chan struct{})
+	suite.registrar.release = release
+	suite.registrar.blocked = make(chan struct{}, 1)
+
+	// the function becomes ready, and registering it hangs
+	functionInstance := suite.createFunction(functionconfig.FunctionStateReady)
+	suite.hook.OnFunctionStateTransition(functionInstance,
+		functionconfig.FunctionStateWaitingForResourceConfiguration)
+	<-suite.registrar.blocked
+
+	// it flaps to unhealthy and back while the registration is in flight
+	functionInstance.Status.State = functionconfig.FunctionStateUnhealthy
+	suite.hook.OnFunctionStateTransition(functionInstance, functionconfig.FunctionStateReady)
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+	suite.hook.OnFunctionStateTransition(functionInstance, functionconfig.FunctionStateUnhealthy)
+	close(release)
+
+	// the hanging registration is cancelled, the superseded deregistration is dropped and the function is
+	// registered once more, one operation at a time
+	registration := suite.waitForRegistration(suite.registrar.registered)
+	suite.Require().True(registration.Healthy)
+	suite.requireNoRegistration(suite.registrar.deregistered)
+
+	operations, maxInFlight := suite.registrar.getOperations()
+	suite.Require().Equal([]string{"register", "register"}, operations)
+	suite.Require().Equal(1, maxInFlight)
+
+	// and the function's queue is drained
+	suite.hook.queuesLock.Lock()
+	defer suite.hook.queuesLock.Unlock()
+	suite.Require().Empty(suite.hook.queues)
+}
+
+func (suite *DiscoveryHookTestSuite) TestNoHookWithoutServiceDiscovery() {
+	functionStateTransitionHooks, err := newFunctionStateTransitionHooks(suite.logger, &platformconfig.Config{})
+	suite.Require().NoError(err)
+	suite.Require().Empty(functionStateTransitionHooks)
+
+	functionStateTransitionHooks, err = newFunctionStateTransitionHooks(suite.logger, &platformconfig.Config{
+		ServiceDiscovery: platformconfig.ServiceDiscovery{
+			Kind: "consul",
+		},
+	})
+	suite.Require().NoError(err)
+	suite.Require().Len(functionStateTransitionHooks, 1)
+	suite.Require().IsType(&discoveryHook{}, functionStateTransitionHooks[0])
+
+	// unknown registries are rejected
+	_, err = newFunctionStateTransitionHooks(suite.logger, &platformconfig.Config{
+		ServiceDiscovery: platformconfig.ServiceDiscovery{
+			Kind: "unknown",
+		},
+	})
+	suite.Require().Error(err)
+}
+
+func (suite *DiscoveryHookTestSuite) createFunction(state functionconfig.FunctionState) *nuclioio.NuclioFunction {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = "func-namespace"
+	functionInstance.Status.State = state
+
+	return functionInstance
+}
+
+func (suite *DiscoveryHookTestSuite) waitForRegistration(
+	registrations chan *discovery.Registration) *discovery.Registration {
+
+	select {
+	case registration := <-registrations:
+		return registration
+	case <-time.After(5 * time.Second):
+		suite.FailNow("Timed out waiting for service discovery operation")
+	}
+
+	return nil
+}
+
+func (suite *DiscoveryHookTestSuite) requireNoRegistration(registrations chan *discovery.Registration) {
+	select {
+	case registration := <-registrations:
+		suite.FailNowf("Unexpected service discovery operation", "%s", registration.ID())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDiscoveryHookTestSuite(t *testing.T) {
+	suite.Run(t, new(DiscoveryHookTestSuite))
+}
+
+type recordingRegistrar struct {
+	registered   chan *discovery.Registration
+	deregistered chan *discovery.Registration
+
+	// number of calls to fail before succeeding
+	failures  int
+	calls     int
+	callsLock sync.Mutex
+
+	// if set, the next call signals blocked and fails once cancelled and released
+	release chan struct{}
+	blocked chan struct{}
+
+	// the operations called, in order, and how many ran at most at once
+	operations  []string
+	inFlight    int
+	maxInFlight int
+}
+
+func newRecordingRegistrar() *recordingRegistrar {
+	return &recordingRegistrar{
+		registered:   make(chan *discovery.Registration, 10),
+		deregistered: make(chan *discovery.Registration, 10),
+	}
+}
+
+func (r *recordingRegistrar) Register(ctx context.Context, registration *discovery.Registration) error {
+	return r.record(ctx, "register", r.registered, registration)
+}
+
+func (r *recordingRegistrar) Deregister(ctx context.Context, registration *discovery.Registration) error {
+	return r.record(ctx, "deregister", r.deregistered, registration)
+}
+
+func (r *recordingRegistrar) record(ctx context.Context,
+	operation string,
+	registrations chan *discovery.Registration,
+	registration *discovery.Registration) error {
+	r.callsLock.Lock()
+	r.calls++
+	r.operations = append(r.operations, operation)
+	r.inFlight++
+	if r.inFlight > r.maxInFlight {
+		r.maxInFlight = r.inFlight
+	}
+
+	release := r.release
+	r.release = nil
+	r.callsLock.Unlock()
+
+	defer func() {
+		r.callsLock.Lock()
+		r.inFlight--
+		r.callsLock.Unlock()
+	}()
+
+	if release != nil {
+		r.blocked <- struct{}{}
+		<-ctx.Done()
+		<-release
+		return ctx.Err()
+	}
+
+	r.callsLock.Lock()
+	defer r.callsLock.Unlock()
+
+	if r.calls <= r.failures {
+		return errors.New("Registry unavailable")
+	}
+
+	registrations <- registration
+	return nil
+}
+
+func (r *recordingRegistrar) getOperations() ([]string, int) {
+	r.callsLock.Lock()
+	defer r.callsLock.Unlock()
+
+	return append([]string{}, r.operations...), r.maxInFlight
+}
+
+func (r *recordingRegistrar) getCalls() int {
+	r.callsLock.Lock()
+	defer r.callsLock.Unlock()
+
+	return r.calls
+}
@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	"reflect"
 	"strings"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	"github.com/nuclio/errors"
 	"github.com/nuclio/logger"
 	"github.com/v3io/scaler-types"
+	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation"
@@ -44,6 +46,7 @@ type functionOperator struct {
 	operator          operator.Operator
 	imagePullSecrets  string
 	functionresClient functionres.Client
+	functionWarmer    *functionWarmer
 }
 
 func newFunctionOperator(parentLogger logger.Logger,
@@ -61,6 +64,7 @@ func newFunctionOperator(parentLogger logger.Logger,
 		controller:        controller,
 		imagePullSecrets:  imagePullSecrets,
 		functionresClient: functionresClient,
+		functionWarmer:    newFunctionWarmer(loggerInstance),
 	}
 
 	// create a function operator
@@ -112,6 +116,29 @@ func (fo *functionOperator) CreateOrUpdate(ctx context.Context, object runtime.O
 		return errors.New("Function name doesn't conform to k8s naming convention. Errors: " + joinedErrorMessage)
 	}
 
+	// functions being deleted are archived and have their resources deleted before they're let go
+	if function.DeletionTimestamp != nil {
+		return fo.finalizeFunction(ctx, function)
+	}
+
+	// hold functions from being deleted until they're archived. the update is picked up as any other, reconciling
+	// the function
+	if fo.controller.functionSnapshotter != nil &&
+		!common.StringInSlice(functionSnapshotFinalizer, function.Finalizers) {
+		function.Finalizers = append(function.Finalizers, functionSnapshotFinalizer)
+		return fo.updateFunction(function)
+	}
+
+	// functions whose pods couldn't fit on any node are re-checked on resyncs, as nodes may have changed since
+	if function.Status.State == functionconfig.FunctionStateError && function.Status.SchedulingInfeasible {
+		return fo.recheckSchedulingFeasibility(ctx, function)
+	}
+
+	// similarly, functions rejected for conflicting ingresses are re-checked, as the conflict may have been resolved
+	if function.Status.State == functionconfig.FunctionStateError && function.Status.IngressConflict != "" {
+		return fo.recheckIngressConflicts(ctx, function)
+	}
+
 	// ready functions as part of controller resyncs, where we verify that a given function CRD has its resources
 	// properly configured
 	statesToRespond := []functionconfig.FunctionState{
@@ -147,33 +174,114 @@ func (fo *functionOperator) CreateOrUpdate(ctx context.Context, object runtime.O
 		})
 	}
 
+	// reconciling mid CRD migration may corrupt the function. pause until the migration completes, which resumes it
+	if fo.controller.crdMigration != nil && fo.controller.crdMigration.isInProgress() {
+		return fo.pauseForCRDMigration(function)
+	}
+
+	// reconciling against stale caches may recreate or delete resources spuriously. wait for them to sync
+	if !fo.controller.hasSynced() {
+		fo.logger.DebugWith("Caches not synced yet, deferring create/update",
+			"name", function.Name,
+			"namespace", function.Namespace)
+		return operator.ErrCacheNotSynced
+	}
+
+	// the function is deployed and waited on with its namespace's defaults merged in. only its status is written
+	// back, so that changes to the defaults propagate to it on resync
+	functionWithDefaults, err := fo.functionresClient.MergeNamespaceFunctionDefaults(ctx, function)
+	if err != nil {
+		return fo.setFunctionError(function,
+			functionconfig.FunctionStateError,
+			errors.Wrap(err, "Failed to merge namespace function defaults"))
+	}
+
 	fo.logger.DebugWith("Ensuring function resources",
 		"functionMeta", function.GetObjectMeta())
 
 	// ensure function resources (deployment, ingress, configmap, etc ...)
-	resources, err := fo.functionresClient.CreateOrUpdate(ctx, function, fo.imagePullSecrets)
+	resources, err := fo.functionresClient.CreateOrUpdate(ctx, functionWithDefaults, fo.imagePullSecrets)
 	if err != nil {
+		if errors.RootCause(err) == functionres.ErrSchedulingInfeasible {
+			return fo.setFunctionErrorWithStatus(function,
+				&functionconfig.Status{
+					State:                functionconfig.FunctionStateError,
+					SchedulingInfeasible: true,
+				},
+				errors.Wrap(err, "Failed to create/update function"))
+		}
+
+		if errors.RootCause(err) == functionres.ErrIngressConflict {
+			return fo.setFunctionErrorWithStatus(function,
+				&functionconfig.Status{
+					State:           functionconfig.FunctionStateError,
+					IngressConflict: getIngressConflictMessage(err),
+				},
+				errors.Wrap(err, "Failed to create/update function"))
+		}
+
 		return fo.setFunctionError(function,
 			functionconfig.FunctionStateError,
 			errors.Wrap(err, "Failed to create/update function"))
 	}
 
+	// functions that warn on conflicting ingresses deploy regardless, with the conflict recorded. let whoever is
+	// looking at the function's events know about it, once
+	ingressConflict := fo.getIngressConflict(ctx, functionWithDefaults)
+	if ingressConflict != "" && ingressConflict != function.Status.IngressConflict {
+		fo.recordFunctionEvent(function, v1.EventTypeWarning, "IngressConflict", ingressConflict)
+	}
+
 	// wait for up to the default readiness timeout or whatever was set in the spec
-	readinessTimeout := function.Spec.ReadinessTimeoutSeconds
+	readinessTimeout := functionWithDefaults.Spec.ReadinessTimeoutSeconds
 	if readinessTimeout == 0 {
 		readinessTimeout = abstract.DefaultReadinessTimeoutSeconds
 	}
 
+	// sandboxed runtimes start pods slower
+	if functionWithDefaults.Spec.RuntimeClassName != "" {
+		runtimeClasses := fo.controller.GetPlatformConfiguration().Kube.RuntimeClasses
+		readinessTimeout = int(float64(readinessTimeout) * runtimeClasses.GetStartupTimeoutFactor())
+	}
+
 	waitContext, cancel := context.WithDeadline(ctx, time.Now().Add(time.Duration(readinessTimeout)*time.Second))
 	defer cancel()
 
 	// wait until the function resources are ready
-	if err = fo.functionresClient.WaitAvailable(waitContext, function.Namespace, function.Name); err != nil {
-		return fo.setFunctionError(function,
-			functionconfig.FunctionStateUnhealthy,
+	waitAvailableResult, err := fo.functionresClient.WaitAvailable(waitContext, functionWithDefaults)
+
+	// nothing may have been observed (e.g. the wait failed before it started)
+	if waitAvailableResult == nil {
+		waitAvailableResult = &functionres.WaitAvailableResult{}
+	}
+
+	// let whoever is looking at the function's events know why it isn't scaling up, once
+	if waitAvailableResult.ScaleUpBlockedMessage != "" &&
+		waitAvailableResult.ScaleUpBlockedMessage != function.Status.ScaleUpBlockedMessage {
+		fo.recordFunctionEvent(function,
+			v1.EventTypeWarning,
+			"ScaleUpBlockedByQuota",
+			waitAvailableResult.ScaleUpBlockedMessage)
+	}
+
+	if err != nil {
+
+		// the function's http port isn't known, so it's left as last observed
+		functionStatus := fo.getDeployedFunctionStatus(functionWithDefaults, waitAvailableResult, ingressConflict)
+		functionStatus.State = functionconfig.FunctionStateUnhealthy
+		functionStatus.UnhealthyCategory = waitAvailableResult.UnhealthyCategory
+
+		return fo.setFunctionErrorWithStatus(function,
+			functionStatus,
 			errors.Wrap(err, "Failed to wait for function resources to be available"))
 	}
 
+	// get function http port. derived on every reconcile, as the service's type may have changed since
+	httpPort, serviceType, err := fo.getFunctionHTTPPort(resources)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get function http port")
+	}
+
 	waitingStates := []functionconfig.FunctionState{
 		functionconfig.FunctionStateWaitingForResourceConfiguration,
 		functionconfig.FunctionStateWaitingForScaleResourcesFromZero,
@@ -196,26 +304,68 @@ func (fo *functionOperator) CreateOrUpdate(ctx context.Context, object runtime.O
 			finalState = functionconfig.FunctionStateReady
 		}
 
-		// get function http port
-		httpPort, err := fo.getFunctionHTTPPort(resources)
-		if err != nil {
-			return errors.Wrap(err, "Failed to get function http port")
+		// prime the caches of functions scaling from zero before they're marked ready. on failure, the function
+		// remains in its state and the warm-up is retried
+		if function.Status.State == functionconfig.FunctionStateWaitingForScaleResourcesFromZero &&
+			len(functionWithDefaults.Spec.WarmupRequests) > 0 {
+			if err := fo.functionWarmer.warmUp(ctx, functionWithDefaults); err != nil {
+				return errors.Wrap(err, "Failed to warm up function")
+			}
 		}
 
-		// NOTE: this reconstructs function status and hence omits all other function status fields
-		// ... such as message and logs.
-		functionStatus := &functionconfig.Status{
-			State:    finalState,
-			HTTPPort: httpPort,
+		functionStatus := fo.getDeployedFunctionStatus(functionWithDefaults, waitAvailableResult, ingressConflict)
+		functionStatus.State = finalState
+		functionStatus.HTTPPort = httpPort
+		functionStatus.ServiceType = serviceType
+		functionStatus.UnhealthyCategory = ""
+		functionStatus.AuthenticationMode = functionWithDefaults.Spec.Authentication.GetAuthenticationMode()
+		functionStatus.HTTPWorkers = functionWithDefaults.Spec.GetHTTPWorkers()
+
+		// the service's node port is the one allocated to functions requesting a stable node port
+		functionStatus.StableNodePort = 0
+		if functionWithDefaults.Spec.StableNodePort {
+			functionStatus.StableNodePort = httpPort
 		}
 
-		if err := fo.setFunctionScaleToZeroStatus(ctx, functionStatus, scaleEvent); err != nil {
+		// scaling to zero leaves no pods to verify, so keep what the function was last ready with
+		if finalState != functionconfig.FunctionStateScaledToZero {
+			functionStatus.KnownGood = waitAvailableResult.KnownGood
+		}
+
+		// as reported by the processor
+		functionStatus.ActiveTriggers = nil
+		if finalState == functionconfig.FunctionStateReady {
+			functionStatus.ActiveTriggers = waitAvailableResult.ActiveTriggers
+		}
+
+		if err := fo.setFunctionScaleToZeroStatus(ctx, functionWithDefaults, functionStatus, scaleEvent); err != nil {
 			return errors.Wrap(err, "Failed setting function scale to zero status")
 		}
 
 		return fo.setFunctionStatus(function, functionStatus)
 	}
 
+	// scaling up a ready function (e.g. by the HPA) may get blocked by quota, and unblocked once the quota allows.
+	// similarly, its external secrets may fail to sync from the store, its cron jobs run, its image fail over,
+	// its scale up succeed with only some of its replicas ready, a debug sidecar be attached to it and its service
+	// change type (leaving its node port, if any, stale).
+	// keep the status in line with what resyncs observe
+	functionStatus := function.Status
+	fo.setObservedFunctionStatus(&functionStatus, functionWithDefaults, waitAvailableResult, ingressConflict)
+	functionStatus.HTTPPort = httpPort
+	functionStatus.ServiceType = serviceType
+	functionStatus.ReconcilePausedReason = ""
+
+	// learn the replica count the function runs at, to start it at that count next time
+	replicaObservationRecorded := functionWithDefaults.Spec.ReplicasFromHistory &&
+		functionStatus.AddReplicaObservation(waitAvailableResult.AvailableReplicas, time.Now())
+
+	if replicaObservationRecorded ||
+		waitAvailableResult.ImageFailedOver ||
+		!reflect.DeepEqual(functionStatus, function.Status) {
+		return fo.setFunctionStatus(function, &functionStatus)
+	}
+
 	return nil
 }
 
@@ -225,23 +375,264 @@ func (fo *functionOperator) Delete(ctx context.Context, namespace string, name s
 		"name", name,
 		"namespace", namespace)
 
-	return fo.functionresClient.Delete(ctx, namespace, name)
+	// a function missing from a cache that hasn't synced yet may well exist
+	if !fo.controller.hasSynced() {
+		fo.logger.DebugWith("Caches not synced yet, deferring delete",
+			"name", name,
+			"namespace", namespace)
+		return operator.ErrCacheNotSynced
+	}
+
+	// functions holding the snapshot finalizer had their resources deleted already, in which case this is a no-op
+	if err := fo.functionresClient.Delete(ctx, namespace, name); err != nil {
+		return err
+	}
+
+	fo.notifyFunctionDeleted(namespace, name)
+
+	return nil
+}
+
+// finalizeFunction archives the state of a function being deleted and deletes its resources, then lets it go. if
+// archiving it fails and the failure policy blocks the delete, the function is held (along with its resources) and
+// retried on resyncs
+func (fo *functionOperator) finalizeFunction(ctx context.Context, function *nuclioio.NuclioFunction) error {
+	if !common.StringInSlice(functionSnapshotFinalizer, function.Finalizers) {
+		return nil
+	}
+
+	// the sink may have been unconfigured since the function was finalized. nothing to archive to, then
+	if fo.controller.functionSnapshotter != nil {
+		if err := fo.controller.functionSnapshotter.snapshotBeforeDelete(ctx, function); err != nil {
+			fo.recordFunctionEvent(function, v1.EventTypeWarning, "SnapshotFailed", errors.Cause(err).Error())
+			return err
+		}
+	}
+
+	if err := fo.functionresClient.Delete(ctx, function.Namespace, function.Name); err != nil {
+		return errors.Wrap(err, "Failed to delete function resources")
+	}
+
+	var finalizers []string
+	for _, finalizer := range function.Finalizers {
+		if finalizer != functionSnapshotFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+
+	function.Finalizers = finalizers
+	return fo.updateFunction(function)
+}
+
+func (fo *functionOperator) updateFunction(function *nuclioio.NuclioFunction) error {
+	if _, err := fo.controller.nuclioClientSet.NuclioV1beta1().NuclioFunctions(function.Namespace).Update(function); err != nil {
+		return errors.Wrap(err, "Failed to update function")
+	}
+
+	return nil
+}
+
+// pauseForCRDMigration records that the function's reconciles are paused, leaving its resources as they are
+func (fo *functionOperator) pauseForCRDMigration(function *nuclioio.NuclioFunction) error {
+	if function.Status.ReconcilePausedReason == crdMigrationPausedReason {
+		return nil
+	}
+
+	fo.logger.InfoWith("Pausing function reconciles for CRD migration",
+		"name", function.Name,
+		"namespace", function.Namespace)
+
+	functionStatus := function.Status
+	functionStatus.ReconcilePausedReason = crdMigrationPausedReason
+
+	return fo.setFunctionStatus(function, &functionStatus)
+}
+
+// recheckSchedulingFeasibility redeploys a function that failed because its pods couldn't fit on any node,
+// once they can
+func (fo *functionOperator) recheckSchedulingFeasibility(ctx context.Context,
+	function *nuclioio.NuclioFunction) error {
+
+	functionWithDefaults, err := fo.functionresClient.MergeNamespaceFunctionDefaults(ctx, function)
+	if err != nil {
+		fo.logger.WarnWith("Failed to merge namespace function defaults",
+			"name", function.Name,
+			"namespace", function.Namespace,
+			"err", errors.Cause(err))
+		return nil
+	}
+
+	err = fo.functionresClient.CheckSchedulingFeasibility(ctx, functionWithDefaults)
+	if err != nil {
+		if errors.RootCause(err) != functionres.ErrSchedulingInfeasible {
+			fo.logger.WarnWith("Failed to recheck function scheduling feasibility",
+				"name", function.Name,
+				"namespace", function.Namespace,
+				"err", errors.Cause(err))
+		}
+
+		return nil
+	}
+
+	fo.logger.InfoWith("Function can now be scheduled, redeploying",
+		"name", function.Name,
+		"namespace", function.Namespace)
+
+	// the update is picked up as any other, deploying the function
+	return fo.setFunctionStatus(function, &functionconfig.Status{
+		State: functionconfig.FunctionStateWaitingForResourceConfiguration,
+	})
+}
+
+func (fo *functionOperator) recheckIngressConflicts(ctx context.Context,
+	function *nuclioio.NuclioFunction) error {
+
+	functionWithDefaults, err := fo.functionresClient.MergeNamespaceFunctionDefaults(ctx, function)
+	if err != nil {
+		fo.logger.WarnWith("Failed to merge namespace function defaults",
+			"name", function.Name,
+			"namespace", function.Namespace,
+			"err", errors.Cause(err))
+		return nil
+	}
+
+	err = fo.functionresClient.CheckIngressConflicts(ctx, functionWithDefaults)
+	if err != nil {
+		if errors.RootCause(err) != functionres.ErrIngressConflict {
+			fo.logger.WarnWith("Failed to recheck function ingress conflicts",
+				"name", function.Name,
+				"namespace", function.Namespace,
+				"err", errors.Cause(err))
+		}
+
+		return nil
+	}
+
+	fo.logger.InfoWith("Function ingresses no longer conflict, redeploying",
+		"name", function.Name,
+		"namespace", function.Namespace)
+
+	// the update is picked up as any other, deploying the function
+	return fo.setFunctionStatus(function, &functionconfig.Status{
+		State: functionconfig.FunctionStateWaitingForResourceConfiguration,
+	})
+}
+
+// returns the conflicts of the function's ingresses with other functions', for functions that warn on them.
+// if they can't be checked, the conflicts last recorded are returned
+func (fo *functionOperator) getIngressConflict(ctx context.Context, function *nuclioio.NuclioFunction) string {
+	if function.Spec.IngressConflictPolicy != functionconfig.IngressConflictPolicyWarn {
+		return ""
+	}
+
+	err := fo.functionresClient.CheckIngressConflicts(ctx, function)
+	if err == nil {
+		return ""
+	}
+
+	if errors.RootCause(err) != functionres.ErrIngressConflict {
+		fo.logger.WarnWith("Failed to check function ingress conflicts",
+			"name", function.Name,
+			"namespace", function.Namespace,
+			"err", errors.Cause(err))
+		return function.Status.IngressConflict
+	}
+
+	return getIngressConflictMessage(err)
+}
+
+// returns the message of the error wrapping ErrIngressConflict, which lists the conflicts
+func getIngressConflictMessage(err error) string {
+	for currentErr := err; currentErr != nil; currentErr = errors.Cause(currentErr) {
+		if errors.Cause(currentErr) == functionres.ErrIngressConflict {
+			return currentErr.Error()
+		}
+
+		if errors.Cause(currentErr) == currentErr {
+			break
+		}
+	}
+
+	return err.Error()
 }
 
 func (fo *functionOperator) setFunctionScaleToZeroStatus(ctx context.Context,
+	function *nuclioio.NuclioFunction,
 	functionStatus *functionconfig.Status,
 	scaleToZeroEvent scaler_types.ScaleEvent) error {
 
 	fo.logger.DebugWith("Setting scale to zero status",
 		"LastScaleEvent", scaleToZeroEvent)
 	now := time.Now()
+	previousScaleToZeroStatus := functionStatus.ScaleToZero
 	functionStatus.ScaleToZero = &functionconfig.ScaleToZeroStatus{
 		LastScaleEvent:     scaleToZeroEvent,
 		LastScaleEventTime: &now,
 	}
+
+	switch scaleToZeroEvent {
+
+	// once scaled up from zero, hold off scaling down for the stabilization window
+	case scaler_types.ScaleFromZeroCompletedScaleEvent:
+		scaleDownStabilizationWindow, err := function.Spec.ScaleToZero.GetScaleDownStabilizationWindow()
+		if err != nil {
+			return errors.Wrap(err, "Failed to get scale down stabilization window")
+		}
+
+		if scaleDownStabilizationWindow != 0 {
+			scaleDownStabilizedUntil := now.Add(scaleDownStabilizationWindow)
+			functionStatus.ScaleToZero.ScaleDownStabilizedUntil = &scaleDownStabilizedUntil
+		}
+
+	// redeploying neither arms the window nor cuts one short
+	case scaler_types.ResourceUpdatedScaleEvent:
+		if previousScaleToZeroStatus != nil {
+			functionStatus.ScaleToZero.ScaleDownStabilizedUntil = previousScaleToZeroStatus.ScaleDownStabilizedUntil
+		}
+	}
+
 	return nil
 }
 
+// recordFunctionEvent creates a kubernetes event on the function, best effort
+func (fo *functionOperator) recordFunctionEvent(function *nuclioio.NuclioFunction,
+	eventType string,
+	reason string,
+	message string) {
+	now := metav1.Now()
+
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: function.Name + "-",
+			Namespace:    function.Namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion:      nuclioio.SchemeGroupVersion.String(),
+			Kind:            "NuclioFunction",
+			Name:            function.Name,
+			Namespace:       function.Namespace,
+			UID:             function.UID,
+			ResourceVersion: function.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source: v1.EventSource{
+			Component: "nuclio-controller",
+		},
+	}
+
+	if _, err := fo.controller.kubeClientSet.CoreV1().Events(function.Namespace).Create(event); err != nil {
+		fo.logger.WarnWith("Failed to record function event",
+			"functionName", function.Name,
+			"reason", reason,
+			"err", err)
+	}
+}
+
 func (fo *functionOperator) start() error {
 	go fo.operator.Start() // nolint: errcheck
 
@@ -252,16 +643,24 @@ func (fo *functionOperator) setFunctionError(function *nuclioio.NuclioFunction,
 	functionErrorState functionconfig.FunctionState,
 	err error) error {
 
+	return fo.setFunctionErrorWithStatus(function, &functionconfig.Status{
+		State: functionErrorState,
+	}, err)
+}
+
+// setFunctionErrorWithStatus sets the given status (in an error state) along with the error message
+func (fo *functionOperator) setFunctionErrorWithStatus(function *nuclioio.NuclioFunction,
+	functionErrorStatus *functionconfig.Status,
+	err error) error {
+
 	// whatever the error, try to update the function CR
 	fo.logger.WarnWith("Setting function error",
-		"functionErrorState", functionErrorState,
+		"functionErrorState", functionErrorStatus.State,
 		"functionName", function.Name,
 		"err", err)
 
-	if setStatusErr := fo.setFunctionStatus(function, &functionconfig.Status{
-		State:   functionErrorState,
-		Message: errors.GetErrorStackString(err, 10),
-	}); setStatusErr != nil {
+	functionErrorStatus.Message = errors.GetErrorStackString(err, 10)
+	if setStatusErr := fo.setFunctionStatus(function, functionErrorStatus); setStatusErr != nil {
 		fo.logger.Warn("Failed to update function on error",
 			"setStatusErr", errors.Cause(setStatusErr))
 	}
@@ -274,12 +673,24 @@ func (fo *functionOperator) setFunctionStatus(function *nuclioio.NuclioFunction,
 
 	fo.logger.DebugWith("Setting function state", "name", function.Name, "status", status)
 
+	previousState := function.Status.State
+
+	// keep the status' history bounded, however long-lived (or flapping) the function is
+	statusCompaction := fo.controller.GetPlatformConfiguration().Kube.StatusCompaction
+	status.RecordStateTransition(&function.Status, time.Now())
+	status.Compact(statusCompaction.MaxHistoryLength, statusCompaction.MaxStatusSize)
+
 	// indicate error state
 	function.Status = *status
 
 	// try to update the function
-	_, err := fo.controller.nuclioClientSet.NuclioV1beta1().NuclioFunctions(function.Namespace).Update(function)
-	return err
+	if _, err := fo.controller.nuclioClientSet.NuclioV1beta1().NuclioFunctions(function.Namespace).Update(function); err != nil {
+		return err
+	}
+
+	fo.notifyFunctionStateTransition(function, previousState)
+
+	return nil
 }
 
 func (fo *functionOperator) getListWatcher(namespace string) cache.ListerWatcher {
@@ -293,21 +704,91 @@ func (fo *functionOperator) getListWatcher(namespace string) cache.ListerWatcher
 	}
 }
 
-func (fo *functionOperator) getFunctionHTTPPort(functionResources functionres.Resources) (int, error) {
+// returns a copy of the function's status, updated with what was observed while deploying it. whatever else the
+// function's status holds (e.g. its replica history) is kept, except for the message and logs of the previous
+// deployment
+func (fo *functionOperator) getDeployedFunctionStatus(function *nuclioio.NuclioFunction,
+	waitAvailableResult *functionres.WaitAvailableResult,
+	ingressConflict string) *functionconfig.Status {
+
+	functionStatus := function.Status
+	functionStatus.Message = ""
+	functionStatus.Logs = nil
+	functionStatus.ReconcilePausedReason = ""
+	functionStatus.ProvisioningPhases = waitAvailableResult.ProvisioningPhases
+	functionStatus.ForcedReschedules = waitAvailableResult.ForcedReschedules
+
+	// functions that no longer request a stable node port release it
+	if !function.Spec.StableNodePort {
+		functionStatus.StableNodePort = 0
+	}
+
+	fo.setObservedFunctionStatus(&functionStatus, function, waitAvailableResult, ingressConflict)
+
+	return &functionStatus
+}
+
+// updates the status with what's observed on every wait for the function's resources, be it while deploying the
+// function or on resyncs
+func (fo *functionOperator) setObservedFunctionStatus(functionStatus *functionconfig.Status,
+	function *nuclioio.NuclioFunction,
+	waitAvailableResult *functionres.WaitAvailableResult,
+	ingressConflict string) {
+
+	functionStatus.ScaleUpBlockedMessage = waitAvailableResult.ScaleUpBlockedMessage
+	functionStatus.ImagePullAttempts = waitAvailableResult.ImagePullAttempts
+	functionStatus.ImagePullPodTemplateHash = waitAvailableResult.ImagePullPodTemplateHash
+	functionStatus.ExternalSecrets = waitAvailableResult.ExternalSecrets
+	functionStatus.JobRuns = waitAvailableResult.JobRuns
+	functionStatus.ActiveImage = waitAvailableResult.ActiveImage
+	functionStatus.ActiveImageSince = fo.getActiveImageSince(function, waitAvailableResult)
+	functionStatus.PartialReadiness = waitAvailableResult.PartialReadiness
+	functionStatus.IngressConflict = ingressConflict
+	functionStatus.DebugSidecar = waitAvailableResult.DebugSidecar
+}
+
+// returns since when the function runs its active image - now, if it just failed over or changed images
+func (fo *functionOperator) getActiveImageSince(function *nuclioio.NuclioFunction,
+	waitAvailableResult *functionres.WaitAvailableResult) *time.Time {
+	if waitAvailableResult.ActiveImage == "" {
+		return nil
+	}
+
+	if waitAvailableResult.ActiveImage == function.Status.ActiveImage &&
+		!waitAvailableResult.ImageFailedOver &&
+		function.Status.ActiveImageSince != nil {
+		return function.Status.ActiveImageSince
+	}
+
+	now := time.Now()
+	return &now
+}
+
+// returns the function's http port - the node port of its service, if the service's type exposes one - along with
+// the service's type
+func (fo *functionOperator) getFunctionHTTPPort(functionResources functionres.Resources) (int, v1.ServiceType, error) {
 	var httpPort int
 
 	service, err := functionResources.Service()
 	if err != nil {
-		return 0, errors.Wrap(err, "Failed to get function service")
+		return 0, "", errors.Wrap(err, "Failed to get function service")
 	}
 
-	if service != nil && len(service.Spec.Ports) != 0 {
-		for _, port := range service.Spec.Ports {
-			if port.Name == functionres.ContainerHTTPPortName {
-				httpPort = int(port.NodePort)
-				break
-			}
+	if service == nil {
+		return 0, "", nil
+	}
+
+	// node ports of services that changed type (e.g. to ClusterIP) are no longer valid
+	if service.Spec.Type != v1.ServiceTypeNodePort && service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return 0, service.Spec.Type, nil
+	}
+
+	for _, port := range service.Spec.Ports {
+		if port.Name == functionres.ContainerHTTPPortName {
+			httpPort = int(port.NodePort)
+			break
 		}
 	}
-	return httpPort, nil
+
+	return httpPort, service.Spec.Type, nil
 }
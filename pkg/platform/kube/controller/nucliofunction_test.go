@@ -20,6 +20,10 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,11 +31,25 @@ import (
 	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
 	"github.com/nuclio/nuclio/pkg/platform/kube/client/clientset/mocks"
 	"github.com/nuclio/nuclio/pkg/platform/kube/functionres"
+	"github.com/nuclio/nuclio/pkg/platform/kube/operator"
+	"github.com/nuclio/nuclio/pkg/platform/kube/snapshot"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
 
+	"github.com/nuclio/errors"
 	"github.com/nuclio/logger"
 	nucliozap "github.com/nuclio/zap"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"github.com/v3io/scaler-types"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 type NuclioFunctionTestSuite struct {
@@ -54,9 +72,16 @@ func (suite *NuclioFunctionTestSuite) SetupTest() {
 
 	suite.functionresClientMock = &functionres.MockedFunctionRes{}
 
+	// namespaces have no function defaults, unless a test says otherwise
+	suite.functionresClientMock.
+		On("MergeNamespaceFunctionDefaults", mock.Anything, mock.Anything).
+		Return(nil, nil).
+		Maybe()
+
 	suite.functionOperatorInstance, err = newFunctionOperator(suite.logger,
 		&Controller{
-			namespace: suite.namespace,
+			namespace:             suite.namespace,
+			platformConfiguration: &platformconfig.Config{},
 		},
 		&resyncInterval,
 		"",
@@ -101,6 +126,1087 @@ func (suite *NuclioFunctionTestSuite) TestRecoverFromPanic() {
 	suite.Assert().Equal(functionInstance.Status.State, functionconfig.FunctionStateError)
 }
 
+func (suite *NuclioFunctionTestSuite) TestNamespaceFunctionDefaults() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+
+	// the namespace's defaults set a short readiness timeout
+	functionWithDefaults := functionInstance.DeepCopy()
+	functionWithDefaults.Spec.ReadinessTimeoutSeconds = 5
+
+	suite.functionresClientMock.ExpectedCalls = nil
+	suite.functionresClientMock.
+		On("MergeNamespaceFunctionDefaults", mock.Anything, functionInstance).
+		Return(functionWithDefaults, nil)
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(&v1.Service{}, nil)
+
+	// the function is deployed and waited on with its defaults
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionWithDefaults, mock.Anything).
+		Return(resources, nil).
+		Once()
+
+	waitsWithinReadinessTimeout := mock.MatchedBy(func(ctx context.Context) bool {
+		deadline, hasDeadline := ctx.Deadline()
+		return hasDeadline && time.Until(deadline) <= 5*time.Second
+	})
+	suite.functionresClientMock.
+		On("WaitAvailable", waitsWithinReadinessTimeout, functionWithDefaults).
+		Return(&functionres.WaitAvailableResult{}, nil).
+		Once()
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil).
+		Once()
+
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.functionresClientMock.AssertExpectations(suite.T())
+
+	// but only its status is written back
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+	suite.Require().Zero(functionInstance.Spec.ReadinessTimeoutSeconds)
+}
+
+func (suite *NuclioFunctionTestSuite) TestScaleUpBlockedByQuota() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+
+	suite.functionOperatorInstance.controller.kubeClientSet = fake.NewSimpleClientset()
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(&v1.Service{}, nil)
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(resources, nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// scaling up the ready function is blocked
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{
+			ScaleUpBlockedMessage: "exceeded quota: compute-resources",
+		}, nil).
+		Once()
+
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+
+	// function remains ready, with the reason recorded in its status and events
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+	suite.Require().Equal("exceeded quota: compute-resources", functionInstance.Status.ScaleUpBlockedMessage)
+
+	events, err := suite.functionOperatorInstance.controller.kubeClientSet.CoreV1().Events("").List(metav1.ListOptions{})
+	suite.Require().NoError(err)
+	suite.Require().Len(events.Items, 1)
+	suite.Require().Equal("ScaleUpBlockedByQuota", events.Items[0].Reason)
+
+	// quota was freed
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{}, nil).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Empty(functionInstance.Status.ScaleUpBlockedMessage)
+}
+
+func (suite *NuclioFunctionTestSuite) TestWaitAvailableFailedWithoutResult() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(&functionres.MockedResources{}, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(nil, errors.New("Failed to get deployment"))
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// the function is unhealthy rather than the controller panicking
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(functionconfig.FunctionStateUnhealthy, functionInstance.Status.State)
+}
+
+func (suite *NuclioFunctionTestSuite) TestUnhealthyKeepsKnownGood() {
+	knownGood := &functionconfig.KnownGoodStatus{
+		SpecChecksum: "checksum",
+		ImageID:      "docker.io/image@sha256:digest",
+	}
+
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Spec.KnownGoodReadinessTimeoutSeconds = 10
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForScaleResourcesFromZero
+	functionInstance.Status.KnownGood = knownGood
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(&functionres.MockedResources{}, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{}, errors.New("Deployment did not become available"))
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(functionconfig.FunctionStateUnhealthy, functionInstance.Status.State)
+	suite.Require().Equal(knownGood, functionInstance.Status.KnownGood)
+}
+
+func (suite *NuclioFunctionTestSuite) TestUnhealthyKeepsStatus() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Spec.StableNodePort = true
+	functionInstance.Status = functionconfig.Status{
+		State:              functionconfig.FunctionStateWaitingForResourceConfiguration,
+		HTTPPort:           31000,
+		ServiceType:        v1.ServiceTypeNodePort,
+		AuthenticationMode: "oidc",
+		HTTPWorkers:        4,
+		StableNodePort:     31000,
+		ReplicaHistory:     []functionconfig.ReplicaObservation{{Replicas: 3}},
+		Logs:               []map[string]interface{}{{"message": "Deploying"}},
+	}
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(&functionres.MockedResources{}, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{
+			ImagePullAttempts:        2,
+			ImagePullPodTemplateHash: "7c9f5d8b6",
+			UnhealthyCategory:        functionconfig.UnhealthyCategoryImage,
+		}, errors.New("Deployment did not become available"))
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().Error(err)
+
+	// what was observed while waiting is recorded, and the rest of the status kept
+	suite.Require().Equal(functionconfig.FunctionStateUnhealthy, functionInstance.Status.State)
+	suite.Require().Equal(functionconfig.UnhealthyCategoryImage, functionInstance.Status.UnhealthyCategory)
+	suite.Require().Equal(2, functionInstance.Status.ImagePullAttempts)
+	suite.Require().Equal("7c9f5d8b6", functionInstance.Status.ImagePullPodTemplateHash)
+	suite.Require().Equal(31000, functionInstance.Status.HTTPPort)
+	suite.Require().Equal(v1.ServiceTypeNodePort, functionInstance.Status.ServiceType)
+	suite.Require().Equal("oidc", functionInstance.Status.AuthenticationMode)
+	suite.Require().Equal(4, functionInstance.Status.HTTPWorkers)
+	suite.Require().Equal(31000, functionInstance.Status.StableNodePort)
+	suite.Require().Len(functionInstance.Status.ReplicaHistory, 1)
+
+	// but not the previous deployment's logs
+	suite.Require().Empty(functionInstance.Status.Logs)
+	suite.Require().NotEmpty(functionInstance.Status.Message)
+}
+
+func (suite *NuclioFunctionTestSuite) TestPartialReadinessOnDeploy() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(&v1.Service{}, nil)
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(resources, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{
+			PartialReadiness: &functionconfig.PartialReadinessStatus{
+				ReadyReplicas:  2,
+				TargetReplicas: 3,
+			},
+		}, nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// the freshly deployed function reports its partial readiness right away
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+	suite.Require().Equal(&functionconfig.PartialReadinessStatus{
+		ReadyReplicas:  2,
+		TargetReplicas: 3,
+	}, functionInstance.Status.PartialReadiness)
+}
+
+func (suite *NuclioFunctionTestSuite) TestActiveTriggers() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+	functionInstance.Spec.Triggers = map[string]functionconfig.Trigger{
+		"http":   {Kind: "http"},
+		"stream": {Kind: "kafka-cluster"},
+	}
+	functionInstance.Spec.TriggerStartOrder = []string{"http", "stream"}
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(&v1.Service{}, nil)
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(resources, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{
+			ActiveTriggers: []string{"http"},
+		}, nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// the triggers are recorded as observed, rather than as ordered
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+	suite.Require().Equal([]string{"http"}, functionInstance.Status.ActiveTriggers)
+}
+
+func (suite *NuclioFunctionTestSuite) TestHTTPPortFollowsServiceType() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+
+	service := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeNodePort,
+			Ports: []v1.ServicePort{
+				{Name: functionres.ContainerHTTPPortName, Port: 8080, NodePort: 31000},
+			},
+		},
+	}
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(service, nil)
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(resources, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{}, nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// the function becomes ready on its node port
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+	suite.Require().Equal(31000, functionInstance.Status.HTTPPort)
+	suite.Require().Equal(v1.ServiceTypeNodePort, functionInstance.Status.ServiceType)
+
+	// its service changes to ClusterIP while it's ready (the node port may linger until the service is updated)
+	service.Spec.Type = v1.ServiceTypeClusterIP
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+	suite.Require().Zero(functionInstance.Status.HTTPPort)
+	suite.Require().Equal(v1.ServiceTypeClusterIP, functionInstance.Status.ServiceType)
+
+	// and back, with a new node port
+	service.Spec.Type = v1.ServiceTypeNodePort
+	service.Spec.Ports[0].NodePort = 32000
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(32000, functionInstance.Status.HTTPPort)
+	suite.Require().Equal(v1.ServiceTypeNodePort, functionInstance.Status.ServiceType)
+}
+
+func (suite *NuclioFunctionTestSuite) TestReplicasFromHistory() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Spec.ReplicasFromHistory = true
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(&v1.Service{}, nil)
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(resources, nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{
+			AvailableReplicas: 4,
+		}, nil).
+		Once()
+
+	// the ready function's replica count is recorded
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Len(functionInstance.Status.ReplicaHistory, 1)
+	suite.Require().Equal(4, functionInstance.Status.ReplicaHistory[0].Replicas)
+
+	// but not again until the observation interval passes
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{
+			AvailableReplicas: 6,
+		}, nil).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Len(functionInstance.Status.ReplicaHistory, 1)
+
+	// once deployed again, it starts at its steady state replica count, within its bounds
+	functionInstance.Status.ReplicaHistory = []functionconfig.ReplicaObservation{
+		{Replicas: 2}, {Replicas: 6}, {Replicas: 4},
+	}
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+	functionInstance.Spec.MinReplicas = &[]int{1}[0]
+	functionInstance.Spec.MaxReplicas = &[]int{5}[0]
+	suite.Require().Equal(int32(4), *functionInstance.GetComputedReplicas())
+
+	functionInstance.Spec.MaxReplicas = &[]int{3}[0]
+	suite.Require().Equal(int32(3), *functionInstance.GetComputedReplicas())
+
+	// without history, it starts at its min replicas
+	functionInstance.Status.ReplicaHistory = nil
+	suite.Require().Equal(int32(1), *functionInstance.GetComputedReplicas())
+}
+
+func (suite *NuclioFunctionTestSuite) TestStateTransitionHooks() {
+	hook := &recordingStateTransitionHook{}
+	suite.functionOperatorInstance.controller.functionStateTransitionHooks = []functionStateTransitionHook{hook}
+
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = suite.namespace
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(&v1.Service{}, nil)
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(resources, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{}, nil)
+
+	suite.functionresClientMock.
+		On("Delete", mock.Anything, suite.namespace, "func-name").
+		Return(nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// function becomes ready
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]functionconfig.FunctionState{
+		functionconfig.FunctionStateWaitingForResourceConfiguration,
+	}, hook.previousStates)
+
+	// a resync of the ready function is not a transition
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Len(hook.previousStates, 1)
+
+	err = suite.functionOperatorInstance.Delete(context.TODO(), suite.namespace, "func-name")
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"func-name"}, hook.deletedNames)
+}
+
+func (suite *NuclioFunctionTestSuite) TestWarmupRequests() {
+	var receivedPaths []string
+	primed := false
+	functionServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		receivedPaths = append(receivedPaths, request.Method+" "+request.URL.Path)
+		if !primed {
+			responseWriter.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		responseWriter.WriteHeader(http.StatusAccepted)
+	}))
+	defer functionServer.Close()
+
+	suite.functionOperatorInstance.functionWarmer.getFunctionURL = func(function *nuclioio.NuclioFunction) string {
+		return functionServer.URL
+	}
+
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = suite.namespace
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForScaleResourcesFromZero
+	functionInstance.Spec.WarmupRequests = []functionconfig.WarmupRequest{
+		{Method: "post", Path: "/prime", Body: "{}", ExpectedStatusCode: http.StatusAccepted},
+	}
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(&v1.Service{}, nil)
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(resources, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{}, nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// the function doesn't respond as expected - it keeps warming up
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(functionconfig.FunctionStateWaitingForScaleResourcesFromZero, functionInstance.Status.State)
+
+	// and becomes ready once it does
+	primed = true
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+	suite.Require().Equal([]string{"POST /prime", "POST /prime"}, receivedPaths)
+}
+
+func (suite *NuclioFunctionTestSuite) TestUnsyncedCache() {
+	cacheSynced := false
+	suite.functionOperatorInstance.controller.cachesSynced = []cache.InformerSynced{
+		func() bool { return cacheSynced },
+	}
+
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = suite.namespace
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+
+	// nothing is created, updated or deleted while the cache isn't synced - the function is requeued instead
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().Equal(operator.ErrCacheNotSynced, err)
+
+	err = suite.functionOperatorInstance.Delete(context.TODO(), suite.namespace, "func-name")
+	suite.Require().Equal(operator.ErrCacheNotSynced, err)
+
+	suite.functionresClientMock.AssertNotCalled(suite.T(), "CreateOrUpdate", mock.Anything, mock.Anything, mock.Anything)
+	suite.functionresClientMock.AssertNotCalled(suite.T(), "Delete", mock.Anything, mock.Anything, mock.Anything)
+
+	// statuses that don't require touching resources are set regardless
+	functionInstance.Annotations = map[string]string{functionconfig.FunctionAnnotationSkipDeploy: "true"}
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateImported, functionInstance.Status.State)
+
+	// once synced, deletes go through
+	cacheSynced = true
+	suite.functionresClientMock.
+		On("Delete", mock.Anything, suite.namespace, "func-name").
+		Return(nil).
+		Once()
+
+	err = suite.functionOperatorInstance.Delete(context.TODO(), suite.namespace, "func-name")
+	suite.Require().NoError(err)
+	suite.functionresClientMock.AssertExpectations(suite.T())
+}
+
+func (suite *NuclioFunctionTestSuite) TestSchedulingInfeasible() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = suite.namespace
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(&functionres.MockedResources{},
+			errors.Wrap(functionres.ErrSchedulingInfeasible, "pod requires 64Gi memory but largest node has 32Gi")).
+		Once()
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// the function fails, flagged for rechecks
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(functionconfig.FunctionStateError, functionInstance.Status.State)
+	suite.Require().True(functionInstance.Status.SchedulingInfeasible)
+	suite.Require().Contains(functionInstance.Status.Message, "largest node has 32Gi")
+
+	// resyncs leave it be while it still can't be scheduled
+	suite.functionresClientMock.
+		On("CheckSchedulingFeasibility", mock.Anything, functionInstance).
+		Return(errors.Wrap(functionres.ErrSchedulingInfeasible, "still too big")).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateError, functionInstance.Status.State)
+
+	// and redeploy it once it can
+	suite.functionresClientMock.
+		On("CheckSchedulingFeasibility", mock.Anything, functionInstance).
+		Return(nil).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateWaitingForResourceConfiguration, functionInstance.Status.State)
+	suite.Require().False(functionInstance.Status.SchedulingInfeasible)
+	suite.functionresClientMock.AssertExpectations(suite.T())
+}
+
+func (suite *NuclioFunctionTestSuite) TestIngressConflictRejected() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = suite.namespace
+	functionInstance.Spec.IngressConflictPolicy = functionconfig.IngressConflictPolicyReject
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(&functionres.MockedResources{},
+			errors.Wrap(errors.Wrap(functionres.ErrIngressConflict,
+				"Path /api on host example.com overlaps path /api/v1 of function other-func"),
+				"Failed to check ingress conflicts")).
+		Once()
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// the function fails, naming the conflicting function
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(functionconfig.FunctionStateError, functionInstance.Status.State)
+	suite.Require().Equal("Path /api on host example.com overlaps path /api/v1 of function other-func",
+		functionInstance.Status.IngressConflict)
+
+	// resyncs leave it be while the conflict remains
+	suite.functionresClientMock.
+		On("CheckIngressConflicts", mock.Anything, functionInstance).
+		Return(errors.Wrap(functionres.ErrIngressConflict, "still conflicting")).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateError, functionInstance.Status.State)
+
+	// and redeploy it once it's resolved, clearing the conflict
+	suite.functionresClientMock.
+		On("CheckIngressConflicts", mock.Anything, functionInstance).
+		Return(nil).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionconfig.FunctionStateWaitingForResourceConfiguration, functionInstance.Status.State)
+	suite.Require().Empty(functionInstance.Status.IngressConflict)
+	suite.functionresClientMock.AssertExpectations(suite.T())
+}
+
+func (suite *NuclioFunctionTestSuite) TestStatusCompaction() {
+	statusCompaction := &suite.functionOperatorInstance.controller.platformConfiguration.Kube.StatusCompaction
+	statusCompaction.MaxHistoryLength = 10
+	statusCompaction.MaxStatusSize = 4096
+
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = suite.namespace
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// a function flapping between error and ready
+	for flapIndex := 0; flapIndex < 50; flapIndex++ {
+		err := suite.functionOperatorInstance.setFunctionError(functionInstance,
+			functionconfig.FunctionStateError,
+			errors.New(strings.Repeat("Function crashed. ", 20)))
+		suite.Require().Error(err)
+
+		err = suite.functionOperatorInstance.setFunctionStatus(functionInstance, &functionconfig.Status{
+			State: functionconfig.FunctionStateReady,
+		})
+		suite.Require().NoError(err)
+
+		// its status stays bounded throughout
+		suite.Require().True(len(functionInstance.Status.Transitions) <= 10)
+		marshalledStatus, err := json.Marshal(functionInstance.Status)
+		suite.Require().NoError(err)
+		suite.Require().True(len(marshalledStatus) <= 4096)
+	}
+
+	// keeping the latest transitions, and summarizing the rest
+	suite.Require().Len(functionInstance.Status.Transitions, 10)
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.Transitions[9].To)
+	suite.Require().Equal([]string{
+		"45 earlier error→ready transitions omitted",
+		"45 earlier ready→error transitions omitted",
+	}, functionInstance.Status.GetOmittedHistorySummary())
+
+	// older transitions make way for a long error, which is truncated if need be
+	err := suite.functionOperatorInstance.setFunctionError(functionInstance,
+		functionconfig.FunctionStateError,
+		errors.New(strings.Repeat("Function crashed. ", 1000)))
+	suite.Require().Error(err)
+	marshalledStatus, err := json.Marshal(functionInstance.Status)
+	suite.Require().NoError(err)
+	suite.Require().True(len(marshalledStatus) <= 4096)
+	suite.Require().Empty(functionInstance.Status.Transitions)
+	suite.Require().Contains(functionInstance.Status.Message, "Function crashed.")
+}
+
+func (suite *NuclioFunctionTestSuite) TestCRDMigration() {
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(nuclioFunctionCRDName)
+	suite.setCRDVersions(crd, map[string]bool{"v1beta1": true}, []string{"v1beta1"})
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), crd)
+	crdMigration := &CRDMigration{
+		logger:        suite.logger,
+		controller:    suite.functionOperatorInstance.controller,
+		dynamicClient: dynamicClient,
+		targetVersion: "v1",
+	}
+	suite.functionOperatorInstance.controller.crdMigration = crdMigration
+	suite.Require().False(crdMigration.isInProgress())
+
+	// the target version isn't even served yet
+	suite.Require().NoError(crdMigration.check(context.TODO()))
+	suite.Require().True(crdMigration.isInProgress())
+
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = suite.namespace
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil).
+		Once()
+
+	// the function is paused, its resources left as they are
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(crdMigrationPausedReason, functionInstance.Status.ReconcilePausedReason)
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+
+	// once
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.functionresClientMock.AssertNotCalled(suite.T(), "CreateOrUpdate", mock.Anything, mock.Anything, mock.Anything)
+
+	// objects are still stored in the previous version
+	suite.setCRDVersions(crd, map[string]bool{"v1beta1": false, "v1": true}, []string{"v1beta1", "v1"})
+	_, err = dynamicClient.Resource(customResourceDefinitionResources[0]).Update(crd, metav1.UpdateOptions{})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(crdMigration.check(context.TODO()))
+	suite.Require().True(crdMigration.isInProgress())
+
+	// migration completes, resuming paused functions
+	suite.setCRDVersions(crd, map[string]bool{"v1beta1": false, "v1": true}, []string{"v1"})
+	_, err = dynamicClient.Resource(customResourceDefinitionResources[0]).Update(crd, metav1.UpdateOptions{})
+	suite.Require().NoError(err)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("List", metav1.ListOptions{}).
+		Return(&nuclioio.NuclioFunctionList{Items: []nuclioio.NuclioFunction{*functionInstance}}, nil).
+		Once()
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", mock.MatchedBy(func(function *nuclioio.NuclioFunction) bool {
+			return function.Name == "func-name" && function.Status.ReconcilePausedReason == ""
+		})).
+		Return(nil, nil).
+		Once()
+
+	suite.Require().NoError(crdMigration.check(context.TODO()))
+	suite.Require().False(crdMigration.isInProgress())
+	suite.nuclioFunctionInterfaceMock.AssertExpectations(suite.T())
+}
+
+func (suite *NuclioFunctionTestSuite) TestCRDMigrationV1beta1CRD() {
+
+	// clusters that only serve apiextensions.k8s.io/v1beta1, with a single version CRD
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1beta1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(nuclioFunctionCRDName)
+	suite.Require().NoError(unstructured.SetNestedField(crd.Object, "v1beta1", "spec", "version"))
+	suite.Require().NoError(unstructured.SetNestedStringSlice(crd.Object, []string{"v1beta1"}, "status", "storedVersions"))
+
+	crdMigration := &CRDMigration{
+		logger:        suite.logger,
+		controller:    suite.functionOperatorInstance.controller,
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), crd),
+		targetVersion: "v1",
+	}
+
+	suite.Require().NoError(crdMigration.check(context.TODO()))
+	suite.Require().True(crdMigration.isInProgress())
+}
+
+func (suite *NuclioFunctionTestSuite) TestCRDMigrationFailsOpen() {
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(nuclioFunctionCRDName)
+	suite.setCRDVersions(crd, map[string]bool{"v1beta1": true}, []string{"v1beta1"})
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), crd)
+	crdMigration := &CRDMigration{
+		logger:        suite.logger,
+		controller:    suite.functionOperatorInstance.controller,
+		dynamicClient: dynamicClient,
+		targetVersion: "v1",
+	}
+
+	suite.Require().NoError(crdMigration.check(context.TODO()))
+	suite.Require().True(crdMigration.isInProgress())
+
+	// the CRD can no longer be read - paused functions are resumed
+	err := dynamicClient.Resource(customResourceDefinitionResources[0]).
+		Delete(nuclioFunctionCRDName, &metav1.DeleteOptions{})
+	suite.Require().NoError(err)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("List", metav1.ListOptions{}).
+		Return(&nuclioio.NuclioFunctionList{}, nil).
+		Once()
+
+	suite.Require().Error(crdMigration.check(context.TODO()))
+	suite.Require().False(crdMigration.isInProgress())
+	suite.nuclioFunctionInterfaceMock.AssertExpectations(suite.T())
+}
+
+func (suite *NuclioFunctionTestSuite) setCRDVersions(crd *unstructured.Unstructured,
+	versionStorage map[string]bool,
+	storedVersions []string) {
+
+	var versions []interface{}
+	for name, storage := range versionStorage {
+		versions = append(versions, map[string]interface{}{
+			"name":    name,
+			"served":  true,
+			"storage": storage,
+		})
+	}
+
+	suite.Require().NoError(unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"))
+	suite.Require().NoError(unstructured.SetNestedStringSlice(crd.Object, storedVersions, "status", "storedVersions"))
+}
+
+func (suite *NuclioFunctionTestSuite) TestWebhookHook() {
+	payloads := make(chan webhookPayload, 10)
+	requestCount := 0
+
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		requestCount++
+
+		// fail the first delivery, to be retried
+		if requestCount == 1 {
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		suite.Require().Equal("secret", request.Header.Get("X-Token"))
+
+		payload := webhookPayload{}
+		suite.Require().NoError(json.NewDecoder(request.Body).Decode(&payload))
+		payloads <- payload
+	}))
+	defer webhookServer.Close()
+
+	hook, err := newWebhookHook(suite.logger, &platformconfig.FunctionStateWebhook{
+		URL:     webhookServer.URL,
+		Headers: map[string]string{"X-Token": "secret"},
+	})
+	suite.Require().NoError(err)
+	hook.initialRetryWait = 10 * time.Millisecond
+
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = "func-namespace"
+	functionInstance.Annotations = map[string]string{FunctionActorAnnotation: "ci-pipeline"}
+	functionInstance.Spec.Handler = "main:Handler"
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+
+	// transitions to states other than ready, error and unhealthy aren't posted
+	hook.OnFunctionStateTransition(functionInstance, functionconfig.FunctionStateWaitingForResourceConfiguration)
+	functionInstance.Status.State = functionconfig.FunctionStateBuilding
+	hook.OnFunctionStateTransition(functionInstance, functionconfig.FunctionStateReady)
+
+	select {
+	case payload := <-payloads:
+		suite.Require().Equal(webhookEventStateTransition, payload.Event)
+		suite.Require().Equal("func-name", payload.Function)
+		suite.Require().Equal("func-namespace", payload.Namespace)
+		suite.Require().Equal(functionconfig.FunctionStateReady, payload.State)
+		suite.Require().Equal(functionconfig.FunctionStateWaitingForResourceConfiguration, payload.PreviousState)
+		suite.Require().Equal("ci-pipeline", payload.Actor)
+		suite.Require().Len(payload.SpecChecksum, 64)
+	case <-time.After(5 * time.Second):
+		suite.Fail("Timed out waiting for state transition to be posted")
+	}
+
+	hook.OnFunctionDeleted("func-namespace", "func-name")
+
+	select {
+	case payload := <-payloads:
+		suite.Require().Equal(webhookEventDeleted, payload.Event)
+		suite.Require().Equal("func-name", payload.Function)
+	case <-time.After(5 * time.Second):
+		suite.Fail("Timed out waiting for deletion to be posted")
+	}
+
+	suite.Require().Empty(payloads)
+}
+
+func (suite *NuclioFunctionTestSuite) TestScaleDownStabilizationWindow() {
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Spec.ScaleToZero = &functionconfig.ScaleToZeroSpec{
+		ScaleDownStabilizationWindow: "10m",
+	}
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForScaleResourcesFromZero
+
+	resources := &functionres.MockedResources{}
+	resources.On("Service").Return(&v1.Service{}, nil)
+
+	suite.functionresClientMock.
+		On("CreateOrUpdate", mock.Anything, functionInstance, mock.Anything).
+		Return(resources, nil)
+
+	suite.functionresClientMock.
+		On("WaitAvailable", mock.Anything, functionInstance).
+		Return(&functionres.WaitAvailableResult{}, nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+
+	// scaling down is held off for the window once scaled up
+	scaleToZeroStatus := functionInstance.Status.ScaleToZero
+	suite.Require().Equal(functionconfig.FunctionStateReady, functionInstance.Status.State)
+	suite.Require().NotNil(scaleToZeroStatus.ScaleDownStabilizedUntil)
+	suite.Require().Equal(10*time.Minute,
+		scaleToZeroStatus.ScaleDownStabilizedUntil.Sub(*scaleToZeroStatus.LastScaleEventTime))
+	scaleDownStabilizedUntil := *scaleToZeroStatus.ScaleDownStabilizedUntil
+
+	// redeploying keeps the window as it was armed
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(scaleDownStabilizedUntil, *functionInstance.Status.ScaleToZero.ScaleDownStabilizedUntil)
+
+	// and doesn't arm one
+	functionInstance.Status.ScaleToZero = nil
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(scaler_types.ResourceUpdatedScaleEvent, functionInstance.Status.ScaleToZero.LastScaleEvent)
+	suite.Require().Nil(functionInstance.Status.ScaleToZero.ScaleDownStabilizedUntil)
+}
+
+func (suite *NuclioFunctionTestSuite) TestOrphanedResourcesCleanup() {
+	controllerInstance := suite.functionOperatorInstance.controller
+	controllerInstance.functionOperator = suite.functionOperatorInstance
+	controllerInstance.kubeClientSet = fake.NewSimpleClientset(
+		suite.createFunctionDeployment("orphaned-function"),
+		suite.createFunctionDeployment("existing-function"))
+
+	orphanedResourcesCleanup := &OrphanedResourcesCleanup{
+		logger:                       suite.logger,
+		controller:                   controllerInstance,
+		requiredConfirmations:        2,
+		missingFunctionConfirmations: map[string]int{},
+	}
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Get", "existing-function", metav1.GetOptions{}).
+		Return(&nuclioio.NuclioFunction{}, nil)
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Get", "orphaned-function", metav1.GetOptions{}).
+		Return(nil, apierrors.NewNotFound(nuclioio.Resource("nucliofunction"), "orphaned-function"))
+
+	// first pass only suspects the function is missing
+	err := orphanedResourcesCleanup.cleanup(context.TODO())
+	suite.Require().NoError(err)
+	suite.functionresClientMock.AssertNotCalled(suite.T(), "Delete", mock.Anything, mock.Anything, mock.Anything)
+
+	// second pass confirms it
+	suite.functionresClientMock.
+		On("Delete", mock.Anything, suite.namespace, "orphaned-function").
+		Return(nil).
+		Once()
+
+	err = orphanedResourcesCleanup.cleanup(context.TODO())
+	suite.Require().NoError(err)
+	suite.functionresClientMock.AssertExpectations(suite.T())
+}
+
+func (suite *NuclioFunctionTestSuite) TestFunctionSnapshot() {
+	functionDeployment := suite.createFunctionDeployment("func-name")
+	functionDeployment.Status.Replicas = 2
+
+	sink := &recordingSnapshotSink{}
+	snapshotter := &functionSnapshotter{
+		logger:        suite.logger,
+		kubeClientSet: fake.NewSimpleClientset(functionDeployment),
+		sink:          sink,
+		failurePolicy: platformconfig.SnapshotFailurePolicyBlock,
+	}
+	suite.functionOperatorInstance.controller.functionSnapshotter = snapshotter
+	suite.functionOperatorInstance.controller.kubeClientSet = fake.NewSimpleClientset()
+
+	functionInstance := &nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Namespace = suite.namespace
+	functionInstance.Spec.Runtime = "python:3.6"
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", functionInstance).
+		Return(nil, nil)
+
+	// functions are held from being deleted until they're archived
+	err := suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{functionSnapshotFinalizer}, functionInstance.Finalizers)
+
+	// a failed snapshot blocks the delete, keeping the function and its resources
+	now := metav1.Now()
+	functionInstance.DeletionTimestamp = &now
+
+	sink.err = errors.New("archive unavailable")
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal([]string{functionSnapshotFinalizer}, functionInstance.Finalizers)
+	suite.functionresClientMock.AssertNotCalled(suite.T(), "Delete", mock.Anything, mock.Anything, mock.Anything)
+
+	// the function's state is archived before its resources are deleted, and then it's let go
+	sink.err = nil
+	suite.functionresClientMock.
+		On("Delete", mock.Anything, suite.namespace, "func-name").
+		Return(nil).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.functionresClientMock.AssertExpectations(suite.T())
+	suite.Require().Empty(functionInstance.Finalizers)
+
+	suite.Require().Len(sink.snapshots, 1)
+	suite.Require().Equal("func-name", sink.snapshots[0].Name)
+	suite.Require().Equal("python:3.6", sink.snapshots[0].Spec.Runtime)
+	suite.Require().Equal(functionconfig.FunctionStateReady, sink.snapshots[0].Status.State)
+	suite.Require().Equal(int32(2), sink.snapshots[0].Replicas)
+
+	// failures are logged and the function deleted regardless, unless configured to block
+	snapshotter.failurePolicy = platformconfig.SnapshotFailurePolicyProceed
+	sink.err = errors.New("archive unavailable")
+	functionInstance.Finalizers = []string{functionSnapshotFinalizer}
+	suite.functionresClientMock.
+		On("Delete", mock.Anything, suite.namespace, "func-name").
+		Return(nil).
+		Once()
+
+	err = suite.functionOperatorInstance.CreateOrUpdate(context.TODO(), functionInstance)
+	suite.Require().NoError(err)
+	suite.functionresClientMock.AssertExpectations(suite.T())
+	suite.Require().Empty(functionInstance.Finalizers)
+}
+
+func (suite *NuclioFunctionTestSuite) createFunctionDeployment(functionName string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nuclio-" + functionName,
+			Namespace: suite.namespace,
+			Labels: map[string]string{
+				"nuclio.io/class":         "function",
+				"nuclio.io/function-name": functionName,
+			},
+		},
+	}
+}
+
 func TestTestSuite(t *testing.T) {
 	suite.Run(t, new(NuclioFunctionTestSuite))
 }
+
+type recordingStateTransitionHook struct {
+	previousStates []functionconfig.FunctionState
+	deletedNames   []string
+}
+
+func (h *recordingStateTransitionHook) OnFunctionStateTransition(function *nuclioio.NuclioFunction,
+	previousState functionconfig.FunctionState) {
+	h.previousStates = append(h.previousStates, previousState)
+}
+
+func (h *recordingStateTransitionHook) OnFunctionDeleted(namespace string, name string) {
+	h.deletedNames = append(h.deletedNames, name)
+}
+
+type recordingSnapshotSink struct {
+	snapshots []*snapshot.FunctionSnapshot
+	err       error
+}
+
+func (s *recordingSnapshotSink) Snapshot(ctx context.Context, functionSnapshot *snapshot.FunctionSnapshot) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.snapshots = append(s.snapshots, functionSnapshot)
+	return nil
+}
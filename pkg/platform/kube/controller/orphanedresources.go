@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	orphanedResourcesLabelSelector = "nuclio.io/class=function,nuclio.io/function-name"
+
+	defaultOrphanedResourcesCleanupInterval              = 10 * time.Minute
+	defaultOrphanedResourcesCleanupRequiredConfirmations = 3
+)
+
+var orphanedFunctionsCleanedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "nuclio_controller_orphaned_functions_cleaned_total",
+	Help: "Total number of functions whose orphaned resources were deleted",
+})
+
+func init() {
+	prometheus.MustRegister(orphanedFunctionsCleanedTotal)
+}
+
+// OrphanedResourcesCleanup periodically deletes the resources of functions that no longer exist, in case the
+// function's delete event was missed (e.g. while the controller was down). a function must be found missing in
+// several consecutive passes before its resources are deleted
+type OrphanedResourcesCleanup struct {
+	logger                logger.Logger
+	controller            *Controller
+	interval              time.Duration
+	requiredConfirmations int
+	stopChan              chan struct{}
+
+	// number of consecutive passes in which functions were found missing, by namespace/name
+	missingFunctionConfirmations map[string]int
+}
+
+func NewOrphanedResourcesCleanup(parentLogger logger.Logger,
+	controller *Controller) (*OrphanedResourcesCleanup, error) {
+	configuration := controller.platformConfiguration.Kube.OrphanedResourcesCleanup
+
+	newOrphanedResourcesCleanup := &OrphanedResourcesCleanup{
+		logger:                       parentLogger.GetChild("orphaned_resources_cleanup"),
+		controller:                   controller,
+		interval:                     defaultOrphanedResourcesCleanupInterval,
+		requiredConfirmations:        defaultOrphanedResourcesCleanupRequiredConfirmations,
+		missingFunctionConfirmations: map[string]int{},
+	}
+
+	if configuration.Interval != "" {
+		interval, err := time.ParseDuration(configuration.Interval)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to parse orphaned resources cleanup interval")
+		}
+
+		newOrphanedResourcesCleanup.interval = interval
+	}
+
+	if configuration.RequiredConfirmations > 0 {
+		newOrphanedResourcesCleanup.requiredConfirmations = configuration.RequiredConfirmations
+	}
+
+	parentLogger.DebugWith("Successfully created orphaned resources cleanup instance",
+		"interval", newOrphanedResourcesCleanup.interval,
+		"requiredConfirmations", newOrphanedResourcesCleanup.requiredConfirmations)
+
+	return newOrphanedResourcesCleanup, nil
+}
+
+func (orc *OrphanedResourcesCleanup) start() {
+
+	// create stop channel
+	orc.stopChan = make(chan struct{}, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				callStack := debug.Stack()
+				orc.logger.ErrorWith("Panic caught while cleaning up orphaned resources",
+					"err", err,
+					"stack", string(callStack))
+			}
+		}()
+
+		orc.logger.InfoWith("Starting orphaned resources cleanup loop", "interval", orc.interval)
+		for {
+			select {
+			case <-time.After(orc.interval):
+				if err := orc.cleanup(context.Background()); err != nil {
+					orc.logger.WarnWith("Failed to clean up orphaned resources", "err", errors.Cause(err))
+				}
+
+			case <-orc.stopChan:
+				orc.logger.Debug("Stopped orphaned resources cleanup")
+				return
+			}
+		}
+	}()
+}
+
+func (orc *OrphanedResourcesCleanup) stop() {
+	orc.logger.Info("Stopping orphaned resources cleanup")
+
+	// post to channel
+	if orc.stopChan != nil {
+		orc.stopChan <- struct{}{}
+	}
+}
+
+// cleanup performs a single pass, deleting the resources of functions found missing in enough consecutive passes
+func (orc *OrphanedResourcesCleanup) cleanup(ctx context.Context) error {
+	ownerFunctions, err := orc.getResourceOwnerFunctions()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get resource owner functions")
+	}
+
+	missingFunctionConfirmations := map[string]int{}
+
+	for functionKey, ownerFunction := range ownerFunctions {
+
+		// go to the API rather than to a cache, which may be stale
+		_, err := orc.controller.nuclioClientSet.NuclioV1beta1().
+			NuclioFunctions(ownerFunction.Namespace).
+			Get(ownerFunction.Name, metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+
+		if !apierrors.IsNotFound(err) {
+			orc.logger.WarnWith("Failed to get function, skipping",
+				"namespace", ownerFunction.Namespace,
+				"name", ownerFunction.Name,
+				"err", err)
+
+			// neither confirmed nor refuted, keep the count as is
+			missingFunctionConfirmations[functionKey] = orc.missingFunctionConfirmations[functionKey]
+			continue
+		}
+
+		confirmations := orc.missingFunctionConfirmations[functionKey] + 1
+		if confirmations < orc.requiredConfirmations {
+			orc.logger.DebugWith("Function of resources is missing, waiting for confirmation",
+				"namespace", ownerFunction.Namespace,
+				"name", ownerFunction.Name,
+				"confirmations", confirmations,
+				"requiredConfirmations", orc.requiredConfirmations)
+
+			missingFunctionConfirmations[functionKey] = confirmations
+			continue
+		}
+
+		orc.logger.InfoWith("Deleting orphaned function resources",
+			"namespace", ownerFunction.Namespace,
+			"name", ownerFunction.Name)
+
+		if err := orc.controller.functionOperator.Delete(ctx, ownerFunction.Namespace, ownerFunction.Name); err != nil {
+			orc.logger.WarnWith("Failed to delete orphaned function resources",
+				"namespace", ownerFunction.Namespace,
+				"name", ownerFunction.Name,
+				"err", err)
+
+			// retry next pass
+			missingFunctionConfirmations[functionKey] = confirmations
+			continue
+		}
+
+		orphanedFunctionsCleanedTotal.Inc()
+	}
+
+	// functions that showed up again (or whose resources are gone) start over
+	orc.missingFunctionConfirmations = missingFunctionConfirmations
+
+	return nil
+}
+
+// returns the functions that own deployments and services, by namespace/name
+func (orc *OrphanedResourcesCleanup) getResourceOwnerFunctions() (map[string]metav1.ObjectMeta, error) {
+	listOptions := metav1.ListOptions{
+		LabelSelector: orphanedResourcesLabelSelector,
+	}
+
+	var resourceMetas []metav1.ObjectMeta
+
+	deployments, err := orc.controller.kubeClientSet.AppsV1().
+		Deployments(orc.controller.namespace).
+		List(listOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list deployments")
+	}
+
+	for _, deployment := range deployments.Items {
+		resourceMetas = append(resourceMetas, deployment.ObjectMeta)
+	}
+
+	services, err := orc.controller.kubeClientSet.CoreV1().
+		Services(orc.controller.namespace).
+		List(listOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list services")
+	}
+
+	for _, service := range services.Items {
+		resourceMetas = append(resourceMetas, service.ObjectMeta)
+	}
+
+	ownerFunctions := map[string]metav1.ObjectMeta{}
+	for _, resourceMeta := range resourceMetas {
+		functionName := resourceMeta.Labels["nuclio.io/function-name"]
+		ownerFunctions[resourceMeta.Namespace+"/"+functionName] = metav1.ObjectMeta{
+			Namespace: resourceMeta.Namespace,
+			Name:      functionName,
+		}
+	}
+
+	return ownerFunctions, nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+	"github.com/nuclio/nuclio/pkg/platform/kube/snapshot"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	snapshotLogTailLines = 500
+
+	// held by functions while a snapshot sink is configured, so that they're archived (and their resources deleted)
+	// before they're gone
+	functionSnapshotFinalizer = "nuclio.io/function-snapshot"
+)
+
+// functionSnapshotter archives the state of functions to a sink right before their resources are deleted. functions
+// hold a finalizer until then, so their state is read off the function resource itself
+type functionSnapshotter struct {
+	logger        logger.Logger
+	kubeClientSet kubernetes.Interface
+	sink          snapshot.Sink
+	failurePolicy platformconfig.SnapshotFailurePolicy
+}
+
+func newFunctionSnapshotter(parentLogger logger.Logger,
+	kubeClientSet kubernetes.Interface,
+	configuration *platformconfig.FunctionSnapshot) (*functionSnapshotter, error) {
+
+	switch configuration.FailurePolicy {
+	case "":
+		configuration.FailurePolicy = platformconfig.SnapshotFailurePolicyProceed
+	case platformconfig.SnapshotFailurePolicyBlock, platformconfig.SnapshotFailurePolicyProceed:
+	default:
+		return nil, errors.Errorf("Unsupported snapshot failure policy: %s", configuration.FailurePolicy)
+	}
+
+	sink, err := snapshot.RegistrySingleton.NewSink(parentLogger, configuration)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create snapshot sink")
+	}
+
+	return &functionSnapshotter{
+		logger:        parentLogger.GetChild("snapshotter"),
+		kubeClientSet: kubeClientSet,
+		sink:          sink,
+		failurePolicy: configuration.FailurePolicy,
+	}, nil
+}
+
+// snapshotBeforeDelete archives the function's state. returns an error only if the failure policy
+// requires the delete to be blocked
+func (fs *functionSnapshotter) snapshotBeforeDelete(ctx context.Context, function *nuclioio.NuclioFunction) error {
+	err := fs.snapshot(ctx, function)
+	if err == nil {
+		return nil
+	}
+
+	if fs.failurePolicy == platformconfig.SnapshotFailurePolicyBlock {
+		return errors.Wrap(err, "Failed to snapshot function, blocking its deletion")
+	}
+
+	fs.logger.WarnWith("Failed to snapshot function, deleting it regardless",
+		"namespace", function.Namespace,
+		"name", function.Name,
+		"err", errors.Cause(err))
+
+	return nil
+}
+
+func (fs *functionSnapshotter) snapshot(ctx context.Context, function *nuclioio.NuclioFunction) error {
+	functionSnapshot := &snapshot.FunctionSnapshot{
+		Namespace:   function.Namespace,
+		Name:        function.Name,
+		Labels:      function.Labels,
+		Annotations: function.Annotations,
+		Spec:        function.Spec,
+		Status:      function.Status,
+		Time:        time.Now(),
+	}
+
+	deployment, err := fs.kubeClientSet.AppsV1().
+		Deployments(function.Namespace).
+		Get(kube.DeploymentNameFromFunctionName(function.Name), metav1.GetOptions{})
+	if err != nil {
+
+		// functions that were never deployed have no replicas to speak of
+		if !apierrors.IsNotFound(err) {
+			return errors.Wrap(err, "Failed to get function deployment")
+		}
+	} else {
+		functionSnapshot.Replicas = deployment.Status.Replicas
+		functionSnapshot.AvailableReplicas = deployment.Status.AvailableReplicas
+	}
+
+	functionSnapshot.Logs, err = fs.getPodLogs(function.Namespace, function.Name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get function pod logs")
+	}
+
+	if err := fs.sink.Snapshot(ctx, functionSnapshot); err != nil {
+		return errors.Wrap(err, "Failed to archive function snapshot")
+	}
+
+	fs.logger.InfoWith("Archived function snapshot",
+		"namespace", function.Namespace,
+		"name", function.Name)
+
+	return nil
+}
+
+func (fs *functionSnapshotter) getPodLogs(namespace string, name string) (map[string]string, error) {
+	pods, err := fs.kubeClientSet.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("nuclio.io/function-name=%s", name),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list function pods")
+	}
+
+	logs := map[string]string{}
+	tailLines := int64(snapshotLogTailLines)
+
+	for _, pod := range pods.Items {
+		logsStream, err := fs.kubeClientSet.CoreV1().
+			Pods(namespace).
+			GetLogs(pod.Name, &v1.PodLogOptions{TailLines: &tailLines}).
+			Stream()
+
+		// logs are best effort (e.g. the pod may never have started)
+		if err != nil {
+			logs[pod.Name] = "Failed to read logs: " + err.Error()
+			continue
+		}
+
+		podLogs, err := ioutil.ReadAll(logsStream)
+		logsStream.Close() // nolint: errcheck
+		if err != nil {
+			logs[pod.Name] = "Failed to read logs: " + err.Error()
+			continue
+		}
+
+		logs[pod.Name] = string(podLogs)
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+)
+
+// functionStateTransitionHook is notified whenever the controller changes a function's state, and when a function
+// is deleted. hooks are called synchronously from the function operator's workers and must not block
+type functionStateTransitionHook interface {
+
+	// OnFunctionStateTransition is called after a function's status was updated to a state other than previousState
+	OnFunctionStateTransition(function *nuclioio.NuclioFunction, previousState functionconfig.FunctionState)
+
+	// OnFunctionDeleted is called after a function's resources were deleted
+	OnFunctionDeleted(namespace string, name string)
+}
+
+func (fo *functionOperator) notifyFunctionStateTransition(function *nuclioio.NuclioFunction,
+	previousState functionconfig.FunctionState) {
+	if function.Status.State == previousState {
+		return
+	}
+
+	for _, hook := range fo.controller.functionStateTransitionHooks {
+		hook.OnFunctionStateTransition(function, previousState)
+	}
+}
+
+func (fo *functionOperator) notifyFunctionDeleted(namespace string, name string) {
+	for _, hook := range fo.controller.functionStateTransitionHooks {
+		hook.OnFunctionDeleted(namespace, name)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+)
+
+const warmupRequestTimeout = 30 * time.Second
+
+// functionWarmer sends functions that scale from zero their warm-up requests, priming their caches before they're
+// marked ready
+type functionWarmer struct {
+	logger     logger.Logger
+	httpClient *http.Client
+
+	// returns the URL at which the function is invoked, without a trailing slash
+	getFunctionURL func(function *nuclioio.NuclioFunction) string
+}
+
+func newFunctionWarmer(parentLogger logger.Logger) *functionWarmer {
+	return &functionWarmer{
+		logger: parentLogger.GetChild("warmer"),
+		httpClient: &http.Client{
+			Timeout: warmupRequestTimeout,
+		},
+		getFunctionURL: func(function *nuclioio.NuclioFunction) string {
+			host, port := kube.GetDomainNameInvokeURL(kube.ServiceNameFromFunctionName(function.Name),
+				function.Namespace)
+			return fmt.Sprintf("http://%s:%d", host, port)
+		},
+	}
+}
+
+// sends the function's warm-up requests in order, failing on the first that doesn't get the expected status code
+func (fw *functionWarmer) warmUp(ctx context.Context, function *nuclioio.NuclioFunction) error {
+	functionURL := fw.getFunctionURL(function)
+
+	for warmupRequestIndex, warmupRequest := range function.Spec.WarmupRequests {
+		warmupRequest := warmupRequest
+		if err := fw.sendWarmupRequest(ctx, functionURL, &warmupRequest); err != nil {
+			return errors.Wrapf(err, "Warm-up request %d failed", warmupRequestIndex)
+		}
+	}
+
+	fw.logger.DebugWith("Function warmed up",
+		"name", function.Name,
+		"namespace", function.Namespace,
+		"warmupRequests", len(function.Spec.WarmupRequests))
+
+	return nil
+}
+
+func (fw *functionWarmer) sendWarmupRequest(ctx context.Context,
+	functionURL string,
+	warmupRequest *functionconfig.WarmupRequest) error {
+
+	var body io.Reader
+	if warmupRequest.Body != "" {
+		body = strings.NewReader(warmupRequest.Body)
+	}
+
+	request, err := http.NewRequest(warmupRequest.GetMethod(), functionURL+warmupRequest.Path, body)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create request")
+	}
+
+	request = request.WithContext(ctx)
+	for headerName, headerValue := range warmupRequest.Headers {
+		request.Header.Set(headerName, headerValue)
+	}
+
+	response, err := fw.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "Failed to send request")
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	// drain the body, so that the function is done handling the request
+	io.Copy(ioutil.Discard, response.Body) // nolint: errcheck
+
+	if response.StatusCode != warmupRequest.GetExpectedStatusCode() {
+		return errors.Errorf("Expected status code %d, got %d",
+			warmupRequest.GetExpectedStatusCode(),
+			response.StatusCode)
+	}
+
+	return nil
+}
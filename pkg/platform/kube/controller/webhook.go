@@ -0,0 +1,231 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+)
+
+const (
+
+	// set on functions by whoever deploys them (e.g. a CI pipeline), and reported as the actor of their
+	// state transitions
+	FunctionActorAnnotation = "nuclio.io/updated-by"
+
+	defaultWebhookRetryDuration    = 5 * time.Minute
+	defaultWebhookInitialRetryWait = time.Second
+	webhookMaxRetryWait            = time.Minute
+	webhookRequestTimeout          = 30 * time.Second
+)
+
+// the kinds of events posted to the webhook
+const (
+	webhookEventStateTransition = "stateTransition"
+	webhookEventDeleted         = "deleted"
+)
+
+// the body posted to the webhook
+type webhookPayload struct {
+	Event         string                       `json:"event"`
+	Function      string                       `json:"function"`
+	Namespace     string                       `json:"namespace"`
+	State         functionconfig.FunctionState `json:"state,omitempty"`
+	PreviousState functionconfig.FunctionState `json:"previousState,omitempty"`
+	Message       string                       `json:"message,omitempty"`
+	SpecChecksum  string                       `json:"specChecksum,omitempty"`
+	Actor         string                       `json:"actor,omitempty"`
+	Time          time.Time                    `json:"time"`
+}
+
+// webhookHook posts functions' state transitions (to ready, error or unhealthy) and deletions to a webhook.
+// delivery happens in the background, retrying with backoff, and never affects the function's state
+type webhookHook struct {
+	logger           logger.Logger
+	configuration    *platformconfig.FunctionStateWebhook
+	httpClient       *http.Client
+	retryDuration    time.Duration
+	initialRetryWait time.Duration
+}
+
+func newWebhookHook(parentLogger logger.Logger,
+	configuration *platformconfig.FunctionStateWebhook) (*webhookHook, error) {
+	newWebhookHook := &webhookHook{
+		logger:        parentLogger.GetChild("webhook"),
+		configuration: configuration,
+		httpClient: &http.Client{
+			Timeout: webhookRequestTimeout,
+		},
+		retryDuration:    defaultWebhookRetryDuration,
+		initialRetryWait: defaultWebhookInitialRetryWait,
+	}
+
+	if configuration.RetryDuration != "" {
+		retryDuration, err := time.ParseDuration(configuration.RetryDuration)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to parse webhook retry duration")
+		}
+
+		newWebhookHook.retryDuration = retryDuration
+	}
+
+	return newWebhookHook, nil
+}
+
+func (wh *webhookHook) OnFunctionStateTransition(function *nuclioio.NuclioFunction,
+	previousState functionconfig.FunctionState) {
+
+	switch function.Status.State {
+	case functionconfig.FunctionStateReady,
+		functionconfig.FunctionStateError,
+		functionconfig.FunctionStateUnhealthy:
+	default:
+		return
+	}
+
+	// build the payload now, the function may change by the time it's delivered
+	payload := &webhookPayload{
+		Event:         webhookEventStateTransition,
+		Function:      function.Name,
+		Namespace:     function.Namespace,
+		State:         function.Status.State,
+		PreviousState: previousState,
+		Message:       function.Status.Message,
+		Actor:         function.Annotations[FunctionActorAnnotation],
+		Time:          time.Now(),
+	}
+
+	specChecksum, err := wh.getSpecChecksum(function)
+	if err != nil {
+		wh.logger.WarnWith("Failed to get function spec checksum, posting without it",
+			"namespace", function.Namespace,
+			"name", function.Name,
+			"err", err)
+	}
+	payload.SpecChecksum = specChecksum
+
+	go wh.deliver(payload)
+}
+
+func (wh *webhookHook) OnFunctionDeleted(namespace string, name string) {
+	go wh.deliver(&webhookPayload{
+		Event:     webhookEventDeleted,
+		Function:  name,
+		Namespace: namespace,
+		Time:      time.Now(),
+	})
+}
+
+func (wh *webhookHook) getSpecChecksum(function *nuclioio.NuclioFunction) (string, error) {
+	encodedSpec, err := json.Marshal(function.Spec)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to marshal function spec")
+	}
+
+	specChecksum := sha256.Sum256(encodedSpec)
+	return hex.EncodeToString(specChecksum[:]), nil
+}
+
+// posts the payload, retrying with exponential backoff until the retry duration passes
+func (wh *webhookHook) deliver(payload *webhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		wh.logger.WarnWith("Failed to marshal webhook payload", "err", err)
+		return
+	}
+
+	deadline := time.Now().Add(wh.retryDuration)
+	retryWait := wh.initialRetryWait
+
+	for attempt := 1; ; attempt++ {
+		err := wh.post(body)
+		if err == nil {
+			wh.logger.DebugWith("Posted to webhook",
+				"event", payload.Event,
+				"namespace", payload.Namespace,
+				"name", payload.Function,
+				"state", payload.State)
+			return
+		}
+
+		if time.Now().Add(retryWait).After(deadline) {
+			wh.logger.WarnWith("Giving up on posting to webhook",
+				"event", payload.Event,
+				"namespace", payload.Namespace,
+				"name", payload.Function,
+				"attempts", attempt,
+				"err", err)
+			return
+		}
+
+		wh.logger.WarnWith("Failed to post to webhook, retrying",
+			"event", payload.Event,
+			"namespace", payload.Namespace,
+			"name", payload.Function,
+			"attempt", attempt,
+			"retryWait", retryWait,
+			"err", err)
+
+		time.Sleep(retryWait)
+
+		retryWait *= 2
+		if retryWait > webhookMaxRetryWait {
+			retryWait = webhookMaxRetryWait
+		}
+	}
+}
+
+func (wh *webhookHook) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookRequestTimeout)
+	defer cancel()
+
+	request, err := http.NewRequest(http.MethodPost, wh.configuration.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "Failed to create request")
+	}
+
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	for headerName, headerValue := range wh.configuration.Headers {
+		request.Header.Set(headerName, headerValue)
+	}
+
+	response, err := wh.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "Failed to send request")
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.New(fmt.Sprintf("Got unexpected status code: %d", response.StatusCode))
+	}
+
+	return nil
+}
@@ -143,6 +143,20 @@ func (d *deployer) populateFunction(functionConfig *functionconfig.Config,
 	// assuming the processor can reload configuration
 	functionConfig.Spec.ImageHash = strconv.Itoa(int(time.Now().UnixNano()))
 
+	// the replica history outlives deployments, so that redeployed functions start at their historical replica count
+	if functionExisted && functionStatus.ReplicaHistory == nil {
+		functionStatus.ReplicaHistory = functionInstance.Status.ReplicaHistory
+	}
+
+	// as does the stable node port, lest redeploying the function change it - unless it's no longer requested
+	if functionExisted && functionStatus.StableNodePort == 0 && functionConfig.Spec.StableNodePort {
+		functionStatus.StableNodePort = functionInstance.Status.StableNodePort
+	}
+
+	statusCompaction := d.platform.Config.Kube.StatusCompaction
+	functionStatus.RecordStateTransition(&functionInstance.Status, time.Now())
+	functionStatus.Compact(statusCompaction.MaxHistoryLength, statusCompaction.MaxStatusSize)
+
 	// update status
 	functionInstance.Status = *functionStatus
 }
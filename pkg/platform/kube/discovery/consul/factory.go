@@ -0,0 +1,43 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consul
+
+import (
+	"github.com/nuclio/nuclio/pkg/platform/kube/discovery"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+)
+
+type factory struct{}
+
+func (f *factory) Create(parentLogger logger.Logger,
+	serviceDiscoveryConfiguration *platformconfig.ServiceDiscovery) (discovery.Registrar, error) {
+
+	configuration, err := NewConfiguration(serviceDiscoveryConfiguration)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create consul configuration")
+	}
+
+	return newRegistrar(parentLogger, configuration)
+}
+
+// register factory
+func init() {
+	discovery.RegistrySingleton.Register("consul", &factory{})
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/platform/kube/discovery"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+)
+
+// registers functions as services of the local consul agent
+// (see https://www.consul.io/api-docs/agent/service)
+type registrar struct {
+	logger        logger.Logger
+	configuration *Configuration
+	httpClient    *http.Client
+}
+
+func newRegistrar(parentLogger logger.Logger, configuration *Configuration) (*registrar, error) {
+	return &registrar{
+		logger:        parentLogger.GetChild("consul"),
+		configuration: configuration,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+func (r *registrar) Register(ctx context.Context, registration *discovery.Registration) error {
+	status := "critical"
+	if registration.Healthy {
+		status = "passing"
+	}
+
+	agentService := map[string]interface{}{
+		"ID":      registration.ID(),
+		"Name":    registration.Name,
+		"Address": registration.Host,
+		"Port":    registration.Port,
+		"Tags":    append([]string{"nuclio", "namespace=" + registration.Namespace}, r.configuration.Tags...),
+		"Meta": map[string]string{
+			"nuclio-function-name":      registration.Name,
+			"nuclio-function-namespace": registration.Namespace,
+		},
+
+		// the controller reports the function's health, consul doesn't probe it
+		"Check": map[string]interface{}{
+			"TTL":    "87600h",
+			"Status": status,
+		},
+	}
+
+	body, err := json.Marshal(agentService)
+	if err != nil {
+		return errors.Wrap(err, "Failed to marshal consul service")
+	}
+
+	if err := r.put(ctx, "/v1/agent/service/register", body); err != nil {
+		return errors.Wrap(err, "Failed to register consul service")
+	}
+
+	r.logger.DebugWith("Registered function", "id", registration.ID(), "healthy", registration.Healthy)
+	return nil
+}
+
+func (r *registrar) Deregister(ctx context.Context, registration *discovery.Registration) error {
+	if err := r.put(ctx, "/v1/agent/service/deregister/"+registration.ID(), nil); err != nil {
+		return errors.Wrap(err, "Failed to deregister consul service")
+	}
+
+	r.logger.DebugWith("Deregistered function", "id", registration.ID())
+	return nil
+}
+
+func (r *registrar) put(ctx context.Context, path string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPut,
+		strings.TrimSuffix(r.configuration.URL, "/")+path,
+		bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "Failed to create request")
+	}
+
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	if r.configuration.Token != "" {
+		request.Header.Set("X-Consul-Token", r.configuration.Token)
+	}
+
+	response, err := r.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "Failed to send request")
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	if response.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("Got unexpected status code: %d", response.StatusCode))
+	}
+
+	return nil
+}
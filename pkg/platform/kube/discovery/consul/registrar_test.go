@@ -0,0 +1,169 @@
+// +build test_unit
+
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nuclio/nuclio/pkg/platform/kube/discovery"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/nuclio/logger"
+	nucliozap "github.com/nuclio/zap"
+	"github.com/stretchr/testify/suite"
+)
+
+type consulRequest struct {
+	method string
+	path   string
+	token  string
+	body   map[string]interface{}
+}
+
+type RegistrarTestSuite struct {
+	suite.Suite
+	logger         logger.Logger
+	consulServer   *httptest.Server
+	requests       []consulRequest
+	responseStatus int
+	registration   *discovery.Registration
+}
+
+func (suite *RegistrarTestSuite) SetupTest() {
+	var err error
+
+	suite.logger, err = nucliozap.NewNuclioZapTest("test")
+	suite.Require().NoError(err)
+
+	suite.requests = nil
+	suite.responseStatus = http.StatusOK
+	suite.consulServer = httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter,
+		request *http.Request) {
+		consulRequest := consulRequest{
+			method: request.Method,
+			path:   request.URL.Path,
+			token:  request.Header.Get("X-Consul-Token"),
+		}
+
+		if request.ContentLength > 0 {
+			suite.Require().NoError(json.NewDecoder(request.Body).Decode(&consulRequest.body))
+		}
+
+		suite.requests = append(suite.requests, consulRequest)
+		responseWriter.WriteHeader(suite.responseStatus)
+	}))
+
+	suite.registration = &discovery.Registration{
+		Namespace: "func-namespace",
+		Name:      "func-name",
+		Host:      "nuclio-func-name.func-namespace.svc.cluster.local",
+		Port:      8080,
+		Healthy:   true,
+	}
+}
+
+func (suite *RegistrarTestSuite) TearDownTest() {
+	suite.consulServer.Close()
+}
+
+func (suite *RegistrarTestSuite) TestRegister() {
+	registrar := suite.createRegistrar(map[string]interface{}{
+		"token": "secret",
+		"tags":  []string{"team=data"},
+	})
+
+	err := registrar.Register(context.Background(), suite.registration)
+	suite.Require().NoError(err)
+	suite.Require().Len(suite.requests, 1)
+
+	request := suite.requests[0]
+	suite.Require().Equal(http.MethodPut, request.method)
+	suite.Require().Equal("/v1/agent/service/register", request.path)
+	suite.Require().Equal("secret", request.token)
+	suite.Require().Equal("func-namespace-func-name", request.body["ID"])
+	suite.Require().Equal("func-name", request.body["Name"])
+	suite.Require().Equal("nuclio-func-name.func-namespace.svc.cluster.local", request.body["Address"])
+	suite.Require().EqualValues(8080, request.body["Port"])
+	suite.Require().Equal([]interface{}{"nuclio", "namespace=func-namespace", "team=data"}, request.body["Tags"])
+	suite.Require().Equal(map[string]interface{}{
+		"nuclio-function-name":      "func-name",
+		"nuclio-function-namespace": "func-namespace",
+	}, request.body["Meta"])
+	suite.Require().Equal("passing", request.body["Check"].(map[string]interface{})["Status"])
+
+	// unhealthy functions are registered as critical
+	suite.registration.Healthy = false
+	err = registrar.Register(context.Background(), suite.registration)
+	suite.Require().NoError(err)
+	suite.Require().Equal("critical", suite.requests[1].body["Check"].(map[string]interface{})["Status"])
+}
+
+func (suite *RegistrarTestSuite) TestDeregister() {
+	registrar := suite.createRegistrar(nil)
+
+	err := registrar.Deregister(context.Background(), suite.registration)
+	suite.Require().NoError(err)
+	suite.Require().Len(suite.requests, 1)
+	suite.Require().Equal(http.MethodPut, suite.requests[0].method)
+	suite.Require().Equal("/v1/agent/service/deregister/func-namespace-func-name", suite.requests[0].path)
+	suite.Require().Empty(suite.requests[0].token)
+}
+
+func (suite *RegistrarTestSuite) TestUnexpectedStatusCode() {
+	registrar := suite.createRegistrar(nil)
+	suite.responseStatus = http.StatusServiceUnavailable
+
+	err := registrar.Register(context.Background(), suite.registration)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "Failed to register consul service")
+
+	err = registrar.Deregister(context.Background(), suite.registration)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "Failed to deregister consul service")
+}
+
+func (suite *RegistrarTestSuite) TestDefaultURL() {
+	configuration, err := NewConfiguration(&platformconfig.ServiceDiscovery{
+		Kind: "consul",
+	})
+	suite.Require().NoError(err)
+	suite.Require().Equal("http://127.0.0.1:8500", configuration.URL)
+}
+
+func (suite *RegistrarTestSuite) createRegistrar(attributes map[string]interface{}) *registrar {
+	configuration, err := NewConfiguration(&platformconfig.ServiceDiscovery{
+		Kind:       "consul",
+		URL:        suite.consulServer.URL + "/",
+		Attributes: attributes,
+	})
+	suite.Require().NoError(err)
+
+	newRegistrar, err := newRegistrar(suite.logger, configuration)
+	suite.Require().NoError(err)
+
+	return newRegistrar
+}
+
+func TestRegistrarTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistrarTestSuite))
+}
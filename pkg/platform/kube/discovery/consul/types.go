@@ -0,0 +1,51 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consul
+
+import (
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuclio/errors"
+)
+
+type Configuration struct {
+	platformconfig.ServiceDiscovery
+
+	// ACL token, if the consul agent requires one
+	Token string
+
+	// additional tags to set on registered functions
+	Tags []string
+}
+
+func NewConfiguration(serviceDiscoveryConfiguration *platformconfig.ServiceDiscovery) (*Configuration, error) {
+	newConfiguration := Configuration{
+		ServiceDiscovery: *serviceDiscoveryConfiguration,
+	}
+
+	// parse attributes
+	if err := mapstructure.Decode(newConfiguration.Attributes, &newConfiguration); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode attributes")
+	}
+
+	if newConfiguration.URL == "" {
+		newConfiguration.URL = "http://127.0.0.1:8500"
+	}
+
+	return &newConfiguration, nil
+}
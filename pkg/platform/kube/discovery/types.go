@@ -0,0 +1,45 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+)
+
+// Registrar registers functions in an external service discovery registry
+type Registrar interface {
+
+	// Register registers (or re-registers) the function's endpoint
+	Register(context.Context, *Registration) error
+
+	// Deregister removes the function from the registry
+	Deregister(context.Context, *Registration) error
+}
+
+// Registration describes a function's entry in the service discovery registry
+type Registration struct {
+	Namespace string
+	Name      string
+	Host      string
+	Port      int
+	Healthy   bool
+}
+
+// ID returns an identifier of the function that is unique across namespaces
+func (r *Registration) ID() string {
+	return r.Namespace + "-" + r.Name
+}
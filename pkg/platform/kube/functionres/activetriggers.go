@@ -0,0 +1,126 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/common/healthcheck"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+)
+
+// how long a pod's processor may take to report its checks
+const activeTriggersRequestTimeout = 5 * time.Second
+
+// getActiveTriggers returns the function's triggers, in start order, that the processor of one of its ready pods
+// reports ready, per its readiness checks
+func (lc *lazyClient) getActiveTriggers(ctx context.Context, function *nuclioio.NuclioFunction) ([]string, error) {
+	pods, err := lc.getFunctionPods(function.Namespace, function.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get function pods")
+	}
+
+	for _, pod := range pods {
+		pod := pod
+		if pod.DeletionTimestamp != nil || !lc.isPodReady(&pod) {
+			continue
+		}
+
+		checkURL, found := lc.getPodReadinessCheckURL(&pod)
+		if !found {
+			continue
+		}
+
+		checkResults, err := lc.getReadinessCheckResults(ctx, checkURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get readiness checks of pod %s", pod.Name)
+		}
+
+		activeTriggers := []string{}
+		for _, triggerName := range function.Spec.GetTriggerStartOrder() {
+			if checkResults[healthcheck.GetTriggerReadinessCheckName(triggerName)] == "OK" {
+				activeTriggers = append(activeTriggers, triggerName)
+			}
+		}
+
+		return activeTriggers, nil
+	}
+
+	return nil, errors.New("No ready pod to get active triggers from")
+}
+
+func (lc *lazyClient) isPodReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// getPodReadinessCheckURL returns the URL of the full results of the pod's readiness checks, off its readiness probe
+func (lc *lazyClient) getPodReadinessCheckURL(pod *v1.Pod) (string, bool) {
+	if pod.Status.PodIP == "" {
+		return "", false
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "nuclio" ||
+			container.ReadinessProbe == nil ||
+			container.ReadinessProbe.HTTPGet == nil {
+			continue
+		}
+
+		httpGet := container.ReadinessProbe.HTTPGet
+		return fmt.Sprintf("http://%s:%d%s?full=1", pod.Status.PodIP, httpGet.Port.IntValue(), httpGet.Path), true
+	}
+
+	return "", false
+}
+
+// getReadinessCheckResults returns the result of each of the checks, by name ("OK" for those passing)
+func (lc *lazyClient) getReadinessCheckResults(ctx context.Context, checkURL string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, activeTriggersRequestTimeout)
+	defer cancel()
+
+	request, err := http.NewRequest(http.MethodGet, checkURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create request")
+	}
+
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to send request")
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	// failing checks fail the response, which still holds the results
+	checkResults := map[string]string{}
+	if err := json.NewDecoder(response.Body).Decode(&checkResults); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode readiness check results")
+	}
+
+	return checkResults, nil
+}
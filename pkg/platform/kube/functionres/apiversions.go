@@ -0,0 +1,446 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"encoding/json"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+	autosv2 "k8s.io/api/autoscaling/v2beta1"
+	autosv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ingressAPIVersionNetworkingV1beta1 = "networking.k8s.io/v1beta1"
+	ingressAPIVersionExtensionsV1beta1 = "extensions/v1beta1"
+	hpaAPIVersionAutoscalingV2beta2    = "autoscaling/v2beta2"
+	hpaAPIVersionAutoscalingV2beta1    = "autoscaling/v2beta1"
+
+	// the API version a resource was last rendered in. resources rendered before API versions were detected
+	// don't have it, and were rendered in the legacy (last) version
+	apiVersionAnnotation = "nuclio.io/api-version"
+)
+
+// the API versions function ingresses and HPAs are rendered in, most preferred first. the first that the cluster
+// serves is used, so that functions survive upgrades that remove the older versions. the last is the legacy version,
+// used if none is served. networking.k8s.io/v1 ingresses and autoscaling/v2 HPAs aren't supported by the vendored
+// client, so clusters that serve neither of these (kubernetes 1.22 and later for ingresses) aren't either
+var ingressAPIVersions = []string{ingressAPIVersionNetworkingV1beta1, ingressAPIVersionExtensionsV1beta1}
+var hpaAPIVersions = []string{hpaAPIVersionAutoscalingV2beta2, hpaAPIVersionAutoscalingV2beta1}
+
+// returns the most preferred of the API versions that the cluster serves the kind in, or the last (legacy) one
+// if the cluster serves none of them. as the cluster's API versions change only on upgrade, the served version is
+// looked up again only once its cached lookup expires
+func (lc *lazyClient) getServedAPIVersion(kind string, apiVersions []string) (string, error) {
+	servedAPIVersion, err := lc.clusterLookups.get("apiversion/"+kind, func() (interface{}, error) {
+		return lc.detectServedAPIVersion(kind, apiVersions)
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to detect the served API version of %s", kind)
+	}
+
+	return servedAPIVersion.(string), nil
+}
+
+func (lc *lazyClient) detectServedAPIVersion(kind string, apiVersions []string) (string, error) {
+	serverGroups, err := lc.kubeClientSet.Discovery().ServerGroups()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to get server groups")
+	}
+
+	servedGroupVersions := map[string]bool{}
+	for _, serverGroup := range serverGroups.Groups {
+		for _, groupVersion := range serverGroup.Versions {
+			servedGroupVersions[groupVersion.GroupVersion] = true
+		}
+	}
+
+	for _, apiVersion := range apiVersions {
+		if !servedGroupVersions[apiVersion] {
+			continue
+		}
+
+		resourceList, err := lc.kubeClientSet.Discovery().ServerResourcesForGroupVersion(apiVersion)
+		if err != nil {
+
+			// removed since the server groups were listed
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return "", errors.Wrapf(err, "Failed to get server resources of %s", apiVersion)
+		}
+
+		for _, resource := range resourceList.APIResources {
+			if resource.Kind == kind {
+				return apiVersion, nil
+			}
+		}
+	}
+
+	legacyAPIVersion := apiVersions[len(apiVersions)-1]
+
+	lc.logger.DebugWith("No API version detected, falling back to legacy",
+		"kind", kind,
+		"apiVersion", legacyAPIVersion)
+
+	return legacyAPIVersion, nil
+}
+
+// records the API version a resource is rendered in, logging if the resource is migrated to it from another
+func recordAPIVersion(loggerInstance logger.Logger,
+	kind string,
+	previousAnnotations map[string]string,
+	objectMeta *metav1.ObjectMeta,
+	apiVersion string,
+	legacyAPIVersion string) {
+
+	// resources that exist without the annotation were rendered in the legacy version
+	if objectMeta.ResourceVersion != "" {
+		previousAPIVersion, found := previousAnnotations[apiVersionAnnotation]
+		if !found {
+			previousAPIVersion = legacyAPIVersion
+		}
+
+		if previousAPIVersion != apiVersion {
+			loggerInstance.InfoWith("Migrating function resource to API version",
+				"kind", kind,
+				"name", objectMeta.Name,
+				"namespace", objectMeta.Namespace,
+				"from", previousAPIVersion,
+				"to", apiVersion)
+		}
+	}
+
+	if objectMeta.Annotations == nil {
+		objectMeta.Annotations = map[string]string{}
+	}
+
+	objectMeta.Annotations[apiVersionAnnotation] = apiVersion
+}
+
+// ingressAccessor reads and writes function ingresses in a given API version, converting from and to
+// extensions/v1beta1 - the version ingresses are rendered with
+type ingressAccessor struct {
+	kubeClientSet kubernetes.Interface
+	apiVersion    string
+}
+
+func (lc *lazyClient) getIngressAccessor() (*ingressAccessor, error) {
+	apiVersion, err := lc.getServedAPIVersion("Ingress", ingressAPIVersions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ingressAccessor{
+		kubeClientSet: lc.kubeClientSet,
+		apiVersion:    apiVersion,
+	}, nil
+}
+
+func (ia *ingressAccessor) Get(namespace string, name string) (*extv1beta1.Ingress, error) {
+	if ia.apiVersion == ingressAPIVersionExtensionsV1beta1 {
+		return ia.kubeClientSet.ExtensionsV1beta1().Ingresses(namespace).Get(name, metav1.GetOptions{})
+	}
+
+	ingress, err := ia.kubeClientSet.NetworkingV1beta1().Ingresses(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return ia.fromNetworkingV1beta1(ingress)
+}
+
+func (ia *ingressAccessor) List(namespace string, listOptions metav1.ListOptions) ([]extv1beta1.Ingress, error) {
+	if ia.apiVersion == ingressAPIVersionExtensionsV1beta1 {
+		ingressList, err := ia.kubeClientSet.ExtensionsV1beta1().Ingresses(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		return ingressList.Items, nil
+	}
+
+	networkingIngressList, err := ia.kubeClientSet.NetworkingV1beta1().Ingresses(namespace).List(listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var ingresses []extv1beta1.Ingress
+	for ingressIndex := range networkingIngressList.Items {
+		ingress, err := ia.fromNetworkingV1beta1(&networkingIngressList.Items[ingressIndex])
+		if err != nil {
+			return nil, err
+		}
+
+		ingresses = append(ingresses, *ingress)
+	}
+
+	return ingresses, nil
+}
+
+func (ia *ingressAccessor) Create(ingress *extv1beta1.Ingress) (*extv1beta1.Ingress, error) {
+	if ia.apiVersion == ingressAPIVersionExtensionsV1beta1 {
+		return ia.kubeClientSet.ExtensionsV1beta1().Ingresses(ingress.Namespace).Create(ingress)
+	}
+
+	networkingIngress, err := ia.toNetworkingV1beta1(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	createdIngress, err := ia.kubeClientSet.NetworkingV1beta1().Ingresses(ingress.Namespace).Create(networkingIngress)
+	if err != nil {
+		return nil, err
+	}
+
+	return ia.fromNetworkingV1beta1(createdIngress)
+}
+
+func (ia *ingressAccessor) Update(ingress *extv1beta1.Ingress) (*extv1beta1.Ingress, error) {
+	if ia.apiVersion == ingressAPIVersionExtensionsV1beta1 {
+		return ia.kubeClientSet.ExtensionsV1beta1().Ingresses(ingress.Namespace).Update(ingress)
+	}
+
+	networkingIngress, err := ia.toNetworkingV1beta1(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedIngress, err := ia.kubeClientSet.NetworkingV1beta1().Ingresses(ingress.Namespace).Update(networkingIngress)
+	if err != nil {
+		return nil, err
+	}
+
+	return ia.fromNetworkingV1beta1(updatedIngress)
+}
+
+func (ia *ingressAccessor) Delete(namespace string, name string, deleteOptions *metav1.DeleteOptions) error {
+	if ia.apiVersion == ingressAPIVersionExtensionsV1beta1 {
+		return ia.kubeClientSet.ExtensionsV1beta1().Ingresses(namespace).Delete(name, deleteOptions)
+	}
+
+	return ia.kubeClientSet.NetworkingV1beta1().Ingresses(namespace).Delete(name, deleteOptions)
+}
+
+// the two versions share their schema, so they convert field by field through their encoding
+func (ia *ingressAccessor) toNetworkingV1beta1(ingress *extv1beta1.Ingress) (*networkingv1beta1.Ingress, error) {
+	networkingIngress := networkingv1beta1.Ingress{}
+	if err := convertThroughJSON(ingress, &networkingIngress); err != nil {
+		return nil, errors.Wrap(err, "Failed to convert ingress")
+	}
+
+	networkingIngress.TypeMeta = metav1.TypeMeta{}
+
+	return &networkingIngress, nil
+}
+
+func (ia *ingressAccessor) fromNetworkingV1beta1(networkingIngress *networkingv1beta1.Ingress) (*extv1beta1.Ingress, error) {
+	ingress := extv1beta1.Ingress{}
+	if err := convertThroughJSON(networkingIngress, &ingress); err != nil {
+		return nil, errors.Wrap(err, "Failed to convert ingress")
+	}
+
+	ingress.TypeMeta = metav1.TypeMeta{}
+
+	return &ingress, nil
+}
+
+// hpaAccessor reads and writes function HPAs in a given API version, converting from and to autoscaling/v2beta1 -
+// the version HPAs are rendered with
+type hpaAccessor struct {
+	kubeClientSet kubernetes.Interface
+	apiVersion    string
+}
+
+func (lc *lazyClient) getHPAAccessor() (*hpaAccessor, error) {
+	apiVersion, err := lc.getServedAPIVersion("HorizontalPodAutoscaler", hpaAPIVersions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hpaAccessor{
+		kubeClientSet: lc.kubeClientSet,
+		apiVersion:    apiVersion,
+	}, nil
+}
+
+func (ha *hpaAccessor) Get(namespace string, name string) (*autosv2.HorizontalPodAutoscaler, error) {
+	if ha.apiVersion == hpaAPIVersionAutoscalingV2beta1 {
+		return ha.kubeClientSet.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).Get(name, metav1.GetOptions{})
+	}
+
+	hpa, err := ha.kubeClientSet.AutoscalingV2beta2().HorizontalPodAutoscalers(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return hpaFromV2beta2(hpa), nil
+}
+
+func (ha *hpaAccessor) Create(hpa *autosv2.HorizontalPodAutoscaler) (*autosv2.HorizontalPodAutoscaler, error) {
+	if ha.apiVersion == hpaAPIVersionAutoscalingV2beta1 {
+		return ha.kubeClientSet.AutoscalingV2beta1().HorizontalPodAutoscalers(hpa.Namespace).Create(hpa)
+	}
+
+	createdHPA, err := ha.kubeClientSet.AutoscalingV2beta2().
+		HorizontalPodAutoscalers(hpa.Namespace).
+		Create(hpaToV2beta2(hpa))
+	if err != nil {
+		return nil, err
+	}
+
+	return hpaFromV2beta2(createdHPA), nil
+}
+
+func (ha *hpaAccessor) Update(hpa *autosv2.HorizontalPodAutoscaler) (*autosv2.HorizontalPodAutoscaler, error) {
+	if ha.apiVersion == hpaAPIVersionAutoscalingV2beta1 {
+		return ha.kubeClientSet.AutoscalingV2beta1().HorizontalPodAutoscalers(hpa.Namespace).Update(hpa)
+	}
+
+	updatedHPA, err := ha.kubeClientSet.AutoscalingV2beta2().
+		HorizontalPodAutoscalers(hpa.Namespace).
+		Update(hpaToV2beta2(hpa))
+	if err != nil {
+		return nil, err
+	}
+
+	return hpaFromV2beta2(updatedHPA), nil
+}
+
+func (ha *hpaAccessor) Delete(namespace string, name string, deleteOptions *metav1.DeleteOptions) error {
+	if ha.apiVersion == hpaAPIVersionAutoscalingV2beta1 {
+		return ha.kubeClientSet.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).Delete(name, deleteOptions)
+	}
+
+	return ha.kubeClientSet.AutoscalingV2beta2().HorizontalPodAutoscalers(namespace).Delete(name, deleteOptions)
+}
+
+// converts the HPA's metadata and spec. only the metric types function HPAs are rendered with (resource and pods)
+// are converted - the others are dropped, as they'd be overwritten on update anyway
+func hpaToV2beta2(hpa *autosv2.HorizontalPodAutoscaler) *autosv2beta2.HorizontalPodAutoscaler {
+	convertedHPA := autosv2beta2.HorizontalPodAutoscaler{
+		ObjectMeta: hpa.ObjectMeta,
+		Spec: autosv2beta2.HorizontalPodAutoscalerSpec{
+			MinReplicas: hpa.Spec.MinReplicas,
+			MaxReplicas: hpa.Spec.MaxReplicas,
+			ScaleTargetRef: autosv2beta2.CrossVersionObjectReference{
+				APIVersion: hpa.Spec.ScaleTargetRef.APIVersion,
+				Kind:       hpa.Spec.ScaleTargetRef.Kind,
+				Name:       hpa.Spec.ScaleTargetRef.Name,
+			},
+		},
+	}
+
+	for _, metricSpec := range hpa.Spec.Metrics {
+		switch {
+		case metricSpec.Resource != nil:
+			metricTarget := autosv2beta2.MetricTarget{
+				Type:         autosv2beta2.AverageValueMetricType,
+				AverageValue: metricSpec.Resource.TargetAverageValue,
+			}
+
+			if metricSpec.Resource.TargetAverageUtilization != nil {
+				metricTarget = autosv2beta2.MetricTarget{
+					Type:               autosv2beta2.UtilizationMetricType,
+					AverageUtilization: metricSpec.Resource.TargetAverageUtilization,
+				}
+			}
+
+			convertedHPA.Spec.Metrics = append(convertedHPA.Spec.Metrics, autosv2beta2.MetricSpec{
+				Type: autosv2beta2.ResourceMetricSourceType,
+				Resource: &autosv2beta2.ResourceMetricSource{
+					Name:   metricSpec.Resource.Name,
+					Target: metricTarget,
+				},
+			})
+
+		case metricSpec.Pods != nil:
+			targetAverageValue := metricSpec.Pods.TargetAverageValue
+			convertedHPA.Spec.Metrics = append(convertedHPA.Spec.Metrics, autosv2beta2.MetricSpec{
+				Type: autosv2beta2.PodsMetricSourceType,
+				Pods: &autosv2beta2.PodsMetricSource{
+					Metric: autosv2beta2.MetricIdentifier{
+						Name:     metricSpec.Pods.MetricName,
+						Selector: metricSpec.Pods.Selector,
+					},
+					Target: autosv2beta2.MetricTarget{
+						Type:         autosv2beta2.AverageValueMetricType,
+						AverageValue: &targetAverageValue,
+					},
+				},
+			})
+		}
+	}
+
+	return &convertedHPA
+}
+
+func hpaFromV2beta2(hpa *autosv2beta2.HorizontalPodAutoscaler) *autosv2.HorizontalPodAutoscaler {
+	convertedHPA := autosv2.HorizontalPodAutoscaler{
+		ObjectMeta: hpa.ObjectMeta,
+		Spec: autosv2.HorizontalPodAutoscalerSpec{
+			MinReplicas: hpa.Spec.MinReplicas,
+			MaxReplicas: hpa.Spec.MaxReplicas,
+			ScaleTargetRef: autosv2.CrossVersionObjectReference{
+				APIVersion: hpa.Spec.ScaleTargetRef.APIVersion,
+				Kind:       hpa.Spec.ScaleTargetRef.Kind,
+				Name:       hpa.Spec.ScaleTargetRef.Name,
+			},
+		},
+	}
+
+	for _, metricSpec := range hpa.Spec.Metrics {
+		switch {
+		case metricSpec.Resource != nil:
+			convertedHPA.Spec.Metrics = append(convertedHPA.Spec.Metrics, autosv2.MetricSpec{
+				Type: autosv2.ResourceMetricSourceType,
+				Resource: &autosv2.ResourceMetricSource{
+					Name:                     metricSpec.Resource.Name,
+					TargetAverageUtilization: metricSpec.Resource.Target.AverageUtilization,
+					TargetAverageValue:       metricSpec.Resource.Target.AverageValue,
+				},
+			})
+
+		case metricSpec.Pods != nil && metricSpec.Pods.Target.AverageValue != nil:
+			convertedHPA.Spec.Metrics = append(convertedHPA.Spec.Metrics, autosv2.MetricSpec{
+				Type: autosv2.PodsMetricSourceType,
+				Pods: &autosv2.PodsMetricSource{
+					MetricName:         metricSpec.Pods.Metric.Name,
+					Selector:           metricSpec.Pods.Metric.Selector,
+					TargetAverageValue: *metricSpec.Pods.Target.AverageValue,
+				},
+			})
+		}
+	}
+
+	return &convertedHPA
+}
+
+func convertThroughJSON(from interface{}, to interface{}) error {
+	encoded, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, to)
+}
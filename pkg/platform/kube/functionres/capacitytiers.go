@@ -0,0 +1,122 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+)
+
+// validateCapacityTier fails functions that request more of a resource than their capacity tier allows, as
+// these would otherwise be scheduled on the tier's nodes only if they happen to be large enough
+func (lc *lazyClient) validateCapacityTier(function *nuclioio.NuclioFunction) error {
+	if function.Spec.CapacityTier == "" {
+		return nil
+	}
+
+	capacityTier, capacityTierFound :=
+		lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.CapacityTiers[function.Spec.CapacityTier]
+	if !capacityTierFound {
+		return errors.Errorf("Unknown capacity tier: %s", function.Spec.CapacityTier)
+	}
+
+	var mismatchMessages []string
+	for resourceName, required := range lc.getRequiredResources(function) {
+		maxRequest, found := capacityTier.MaxRequests[resourceName]
+		if !found || required.Cmp(maxRequest) <= 0 {
+			continue
+		}
+
+		mismatchMessages = append(mismatchMessages,
+			fmt.Sprintf("pod requires %s %s but capacity tier %s allows at most %s - reduce the function's %s "+
+				"request, or assign it a larger capacity tier",
+				required.String(),
+				resourceName,
+				function.Spec.CapacityTier,
+				maxRequest.String(),
+				resourceName))
+	}
+
+	if len(mismatchMessages) == 0 {
+		return nil
+	}
+
+	sort.Strings(mismatchMessages)
+
+	return errors.New(strings.Join(mismatchMessages, "; "))
+}
+
+// returns the node selector of the capacity tier the function is assigned to, if any
+func (lc *lazyClient) getCapacityTierNodeSelector(function *nuclioio.NuclioFunction) map[string]string {
+	if function.Spec.CapacityTier == "" {
+		return nil
+	}
+
+	capacityTiers := lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.CapacityTiers
+	return capacityTiers[function.Spec.CapacityTier].NodeSelector
+}
+
+// returns the pod's node selector with the labels applied previously for the function's capacity tier replaced by
+// the current ones, leaving labels selected by others as is
+func getMergedNodeSelector(nodeSelector map[string]string,
+	appliedCapacityTierNodeSelector map[string]string,
+	capacityTierNodeSelector map[string]string) map[string]string {
+
+	mergedNodeSelector := map[string]string{}
+	for labelKey, labelValue := range nodeSelector {
+
+		// labels whose value was changed since are no longer ours
+		appliedLabelValue, applied := appliedCapacityTierNodeSelector[labelKey]
+		if applied && appliedLabelValue == labelValue {
+			continue
+		}
+
+		mergedNodeSelector[labelKey] = labelValue
+	}
+
+	for labelKey, labelValue := range capacityTierNodeSelector {
+		mergedNodeSelector[labelKey] = labelValue
+	}
+
+	if len(mergedNodeSelector) == 0 {
+		return nil
+	}
+
+	return mergedNodeSelector
+}
+
+// returns the resources the function's pods require of a node
+func (lc *lazyClient) getRequiredResources(function *nuclioio.NuclioFunction) v1.ResourceList {
+	requiredResources := v1.ResourceList{}
+	for resourceName, quantity := range function.Spec.Resources.Requests {
+		requiredResources[resourceName] = quantity
+	}
+
+	// extended resources (e.g. GPUs) are usually only limited, in which case they're requested as much
+	for resourceName, quantity := range function.Spec.Resources.Limits {
+		if _, requested := requiredResources[resourceName]; !requested {
+			requiredResources[resourceName] = quantity
+		}
+	}
+
+	return requiredResources
+}
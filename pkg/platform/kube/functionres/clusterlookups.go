@@ -0,0 +1,64 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"sync"
+	"time"
+)
+
+// clusterLookupCache holds the results of cluster lookups that rarely change (e.g. the API versions the cluster
+// serves), so that reconciling every function on every resync doesn't repeat them
+type clusterLookupCache struct {
+	lock    sync.Mutex
+	ttl     time.Duration
+	entries map[string]clusterLookupCacheEntry
+}
+
+type clusterLookupCacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newClusterLookupCache(ttl time.Duration) *clusterLookupCache {
+	return &clusterLookupCache{
+		ttl:     ttl,
+		entries: map[string]clusterLookupCacheEntry{},
+	}
+}
+
+// get returns the result of the lookup by the given key, performing the lookup if its result isn't cached or has
+// expired. failed lookups aren't cached, so that they're retried on the next call
+func (clc *clusterLookupCache) get(key string, lookup func() (interface{}, error)) (interface{}, error) {
+	clc.lock.Lock()
+	defer clc.lock.Unlock()
+
+	if entry, found := clc.entries[key]; found && time.Now().Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	value, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	clc.entries[key] = clusterLookupCacheEntry{
+		value:     value,
+		expiresAt: time.Now().Add(clc.ttl),
+	}
+
+	return value, nil
+}
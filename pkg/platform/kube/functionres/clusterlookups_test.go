@@ -0,0 +1,78 @@
+// +build test_unit
+
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nuclio/errors"
+	"github.com/stretchr/testify/suite"
+)
+
+type clusterLookupCacheTestSuite struct {
+	suite.Suite
+}
+
+func (suite *clusterLookupCacheTestSuite) TestGet() {
+	lookups := 0
+	lookup := func() (interface{}, error) {
+		lookups++
+		return lookups, nil
+	}
+
+	cache := newClusterLookupCache(100 * time.Millisecond)
+
+	// looked up once, until the result expires
+	for i := 0; i < 3; i++ {
+		value, err := cache.get("key", lookup)
+		suite.Require().NoError(err)
+		suite.Require().Equal(1, value)
+	}
+
+	// other keys are looked up on their own
+	value, err := cache.get("other-key", lookup)
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, value)
+
+	time.Sleep(150 * time.Millisecond)
+
+	value, err = cache.get("key", lookup)
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, value)
+}
+
+func (suite *clusterLookupCacheTestSuite) TestFailedLookupsNotCached() {
+	cache := newClusterLookupCache(time.Minute)
+
+	_, err := cache.get("key", func() (interface{}, error) {
+		return nil, errors.New("Discovery unavailable")
+	})
+	suite.Require().Error(err)
+
+	// retried on the next call
+	value, err := cache.get("key", func() (interface{}, error) {
+		return "served", nil
+	})
+	suite.Require().NoError(err)
+	suite.Require().Equal("served", value)
+}
+
+func TestClusterLookupCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(clusterLookupCacheTestSuite))
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// returns the debug sidecar to attach to the function's pods, per its debug sidecar annotation, if any
+func (lc *lazyClient) getDebugSidecarContainers(function *nuclioio.NuclioFunction) ([]v1.Container, error) {
+	debugSidecarName := function.Annotations[debugSidecarAnnotation]
+	if debugSidecarName == "" {
+		return nil, nil
+	}
+
+	debugSidecarContainer, found :=
+		lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.DebugSidecars[debugSidecarName]
+	if !found {
+		return nil, errors.Errorf("Debug sidecar %s is not configured", debugSidecarName)
+	}
+
+	if debugSidecarContainer.Name == "" {
+		debugSidecarContainer.Name = "debug-" + debugSidecarName
+	}
+
+	return []v1.Container{debugSidecarContainer}, nil
+}
+
+// returns the pod's containers with the debug sidecar attached previously (if any) replaced by the current one
+func getMergedDebugSidecarContainers(containers []v1.Container,
+	appliedDebugSidecarContainerName string,
+	debugSidecarContainers []v1.Container) []v1.Container {
+
+	ownedContainerNames := map[string]bool{}
+	if appliedDebugSidecarContainerName != "" {
+		ownedContainerNames[appliedDebugSidecarContainerName] = true
+	}
+	for _, debugSidecarContainer := range debugSidecarContainers {
+		ownedContainerNames[debugSidecarContainer.Name] = true
+	}
+
+	// the function's container always comes first
+	mergedContainers := []v1.Container{containers[0]}
+	for _, container := range containers[1:] {
+		if !ownedContainerNames[container.Name] {
+			mergedContainers = append(mergedContainers, container)
+		}
+	}
+
+	return append(mergedContainers, debugSidecarContainers...)
+}
+
+// returns the debug sidecar attached to the function's pods, if any
+func (lc *lazyClient) getDeploymentDebugSidecar(function *nuclioio.NuclioFunction) string {
+	deployment, err := lc.kubeClientSet.AppsV1().
+		Deployments(function.Namespace).
+		Get(kube.DeploymentNameFromFunctionName(function.Name), metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+
+	debugSidecarContainerName := deployment.Annotations[debugSidecarContainerAnnotation]
+	if debugSidecarContainerName == "" {
+		return ""
+	}
+
+	for _, container := range deployment.Spec.Template.Spec.Containers[1:] {
+		if container.Name == debugSidecarContainerName {
+			return deployment.Spec.Template.Annotations[debugSidecarAnnotation]
+		}
+	}
+
+	return ""
+}
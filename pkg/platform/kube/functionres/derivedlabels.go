@@ -0,0 +1,132 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// functions available to the templates of derived labels
+var derivedLabelTemplateFuncs = template.FuncMap{
+
+	// the part of a string up to a separator (e.g. {{ .Runtime | cut ":" }} turns "python:3.7" into "python")
+	"cut": func(separator string, value string) string {
+		return strings.SplitN(value, separator, 2)[0]
+	},
+
+	// the value of an optional number (e.g. {{ deref .MinReplicas }}), 0 if unset
+	"deref": func(value *int) int {
+		if value == nil {
+			return 0
+		}
+
+		return *value
+	},
+}
+
+// returns the labels derived from the function's spec, per the platform configuration. labels that render empty
+// map to an empty value
+func (lc *lazyClient) getDerivedLabels(function *nuclioio.NuclioFunction) (map[string]string, error) {
+	derivedLabels := map[string]string{}
+
+	for _, derivedLabel := range lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.DerivedLabels {
+		labelTemplate, err := template.New(derivedLabel.Name).
+			Funcs(derivedLabelTemplateFuncs).
+			Parse(derivedLabel.Template)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse template of derived label %s", derivedLabel.Name)
+		}
+
+		renderedLabel := bytes.Buffer{}
+		if err := labelTemplate.Execute(&renderedLabel, function.Spec); err != nil {
+			return nil, errors.Wrapf(err, "Failed to render derived label %s", derivedLabel.Name)
+		}
+
+		derivedLabels[derivedLabel.Name] = strings.TrimSpace(renderedLabel.String())
+	}
+
+	return derivedLabels, nil
+}
+
+func (lc *lazyClient) validateDerivedLabels(function *nuclioio.NuclioFunction) error {
+	derivedLabels, err := lc.getDerivedLabels(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get derived labels")
+	}
+
+	for labelName, labelValue := range derivedLabels {
+		if errorMessages := validation.IsQualifiedName(labelName); len(errorMessages) != 0 {
+			return errors.Errorf("Invalid derived label name %s: %s", labelName, strings.Join(errorMessages, ", "))
+		}
+
+		if errorMessages := validation.IsValidLabelValue(labelValue); len(errorMessages) != 0 {
+			return errors.Errorf("Invalid value of derived label %s (%s): %s",
+				labelName,
+				labelValue,
+				strings.Join(errorMessages, ", "))
+		}
+
+		// the function's own labels win
+		if functionLabelValue, functionHasLabel := function.Labels[labelName]; functionHasLabel &&
+			functionLabelValue != labelValue {
+			lc.logger.WarnWith("Function label conflicts with derived label, using function label",
+				"functionName", function.Name,
+				"label", labelName,
+				"functionLabelValue", functionLabelValue,
+				"derivedLabelValue", labelValue)
+		}
+	}
+
+	return nil
+}
+
+// returns a copy of the resource labels, with the labels derived from the function's spec set - or removed, if they
+// render empty. labels that the function sets itself are left as is. derived labels are never part of selectors,
+// as they may change with the function's spec
+func (lc *lazyClient) withDerivedLabels(resourceLabels map[string]string,
+	function *nuclioio.NuclioFunction) map[string]string {
+	result := map[string]string{}
+	for labelKey, labelValue := range resourceLabels {
+		result[labelKey] = labelValue
+	}
+
+	// validated before creating any resources
+	derivedLabels, err := lc.getDerivedLabels(function)
+	if err != nil {
+		return result
+	}
+
+	for labelName, labelValue := range derivedLabels {
+		if _, functionHasLabel := function.Labels[labelName]; functionHasLabel {
+			continue
+		}
+
+		if labelValue == "" {
+			delete(result, labelName)
+		} else {
+			result[labelName] = labelValue
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,336 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/nuclio/nuclio/pkg/common"
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var externalSecretResource = schema.GroupVersionResource{
+	Group:    "external-secrets.io",
+	Version:  "v1beta1",
+	Resource: "externalsecrets",
+}
+
+func (lc *lazyClient) validateExternalSecrets(function *nuclioio.NuclioFunction) error {
+	if len(function.Spec.ExternalSecrets) == 0 {
+		return nil
+	}
+
+	externalSecretsConfig := lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.ExternalSecrets
+	if externalSecretsConfig.SecretStore == "" {
+		return errors.New("External secrets require a secret store in the platform configuration")
+	}
+
+	switch externalSecretsConfig.SecretStoreKind {
+	case "", "SecretStore", "ClusterSecretStore":
+	default:
+		return errors.Errorf("Unsupported secret store kind: %s", externalSecretsConfig.SecretStoreKind)
+	}
+
+	externalSecretNames := map[string]bool{}
+	mountPaths := map[string]bool{}
+
+	for _, externalSecret := range function.Spec.ExternalSecrets {
+		if errorMessages := validation.IsDNS1123Label(externalSecret.Name); len(errorMessages) != 0 {
+			return errors.Errorf("Invalid external secret name %s: %s",
+				externalSecret.Name,
+				strings.Join(errorMessages, ", "))
+		}
+
+		if externalSecretNames[externalSecret.Name] {
+			return errors.Errorf("Duplicate external secret name: %s", externalSecret.Name)
+		}
+		externalSecretNames[externalSecret.Name] = true
+
+		if externalSecret.RemoteKey == "" {
+			return errors.Errorf("External secret %s requires a remote key", externalSecret.Name)
+		}
+
+		if !filepath.IsAbs(externalSecret.MountPath) {
+			return errors.Errorf("External secret %s requires an absolute mount path", externalSecret.Name)
+		}
+
+		if mountPaths[externalSecret.MountPath] {
+			return errors.Errorf("Duplicate external secret mount path: %s", externalSecret.MountPath)
+		}
+		mountPaths[externalSecret.MountPath] = true
+	}
+
+	externalSecretsSupported, err := lc.externalSecretsSupported()
+	if err != nil {
+		return errors.Wrap(err, "Failed to check whether external secrets are supported")
+	}
+
+	if !externalSecretsSupported {
+		return errors.Errorf("External secrets require the %s CRD (%s), which isn't installed",
+			externalSecretKind,
+			externalSecretAPIVersion)
+	}
+
+	return nil
+}
+
+// externalSecretsSupported returns whether the cluster serves ExternalSecret resources (i.e. the external secrets
+// operator is installed)
+func (lc *lazyClient) externalSecretsSupported() (bool, error) {
+	if lc.dynamicClient == nil {
+		return false, nil
+	}
+
+	resourceList, err := lc.kubeClientSet.Discovery().ServerResourcesForGroupVersion(externalSecretAPIVersion)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, errors.Wrap(err, "Failed to get server resources")
+	}
+
+	if resourceList == nil {
+		return false, nil
+	}
+
+	for _, resource := range resourceList.APIResources {
+		if resource.Kind == externalSecretKind {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// createOrUpdateExternalSecrets creates an ExternalSecret per external secret of the function, each syncing into
+// a secret of the same name, and deletes those the function no longer references
+func (lc *lazyClient) createOrUpdateExternalSecrets(functionLabels labels.Set,
+	function *nuclioio.NuclioFunction) error {
+
+	if len(function.Spec.ExternalSecrets) == 0 {
+		hadExternalSecrets, err := lc.hadExternalSecrets(function.Namespace, function.Name)
+		if err != nil {
+			return errors.Wrap(err, "Failed to check whether function had external secrets")
+		}
+
+		// most functions never had any, so there's nothing to list
+		if !hadExternalSecrets {
+			return nil
+		}
+
+		return lc.deleteExternalSecrets(function.Namespace, function.Name, nil)
+	}
+
+	externalSecretsClient := lc.dynamicClient.Resource(externalSecretResource).Namespace(function.Namespace)
+	var externalSecretNamesToKeep []string
+
+	for _, externalSecret := range function.Spec.ExternalSecrets {
+		externalSecretName := kube.ExternalSecretNameFromFunctionName(function.Name, externalSecret.Name)
+		externalSecretNamesToKeep = append(externalSecretNamesToKeep, externalSecretName)
+
+		externalSecretLabels := lc.withDeployGenerationLabel(labels.Merge(functionLabels, labels.Set{
+			"nuclio.io/component": "external-secret",
+		}), function)
+
+		externalSecretSpec := lc.getExternalSecretSpec(externalSecretName, &externalSecret)
+
+		existingExternalSecret, err := externalSecretsClient.Get(externalSecretName, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "Failed to get external secret %s", externalSecretName)
+			}
+
+			newExternalSecret := &unstructured.Unstructured{}
+			newExternalSecret.SetAPIVersion(externalSecretAPIVersion)
+			newExternalSecret.SetKind(externalSecretKind)
+			newExternalSecret.SetName(externalSecretName)
+			newExternalSecret.SetNamespace(function.Namespace)
+			newExternalSecret.SetLabels(externalSecretLabels)
+			newExternalSecret.Object["spec"] = externalSecretSpec
+
+			if _, err := externalSecretsClient.Create(newExternalSecret, metav1.CreateOptions{}); err != nil {
+				return errors.Wrapf(err, "Failed to create external secret %s", externalSecretName)
+			}
+
+			lc.logger.DebugWith("Created external secret",
+				"namespace", function.Namespace,
+				"externalSecretName", externalSecretName)
+			continue
+		}
+
+		existingExternalSecret.SetLabels(externalSecretLabels)
+		existingExternalSecret.Object["spec"] = externalSecretSpec
+
+		if _, err := externalSecretsClient.Update(existingExternalSecret, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrapf(err, "Failed to update external secret %s", externalSecretName)
+		}
+	}
+
+	return lc.deleteExternalSecrets(function.Namespace, function.Name, externalSecretNamesToKeep)
+}
+
+func (lc *lazyClient) getExternalSecretSpec(externalSecretName string,
+	externalSecret *functionconfig.ExternalSecretReference) map[string]interface{} {
+	externalSecretsConfig := lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.ExternalSecrets
+
+	secretStoreKind := externalSecretsConfig.SecretStoreKind
+	if secretStoreKind == "" {
+		secretStoreKind = defaultExternalSecretStoreKind
+	}
+
+	refreshInterval := externalSecretsConfig.RefreshInterval
+	if refreshInterval == "" {
+		refreshInterval = defaultExternalSecretRefreshRate
+	}
+
+	return map[string]interface{}{
+		"refreshInterval": refreshInterval,
+		"secretStoreRef": map[string]interface{}{
+			"name": externalSecretsConfig.SecretStore,
+			"kind": secretStoreKind,
+		},
+
+		// the operator owns the secret, deleting it along with the external secret
+		"target": map[string]interface{}{
+			"name":           externalSecretName,
+			"creationPolicy": "Owner",
+		},
+		"dataFrom": []interface{}{
+			map[string]interface{}{
+				"extract": map[string]interface{}{
+					"key": externalSecret.RemoteKey,
+				},
+			},
+		},
+	}
+}
+
+// hadExternalSecrets returns whether the function's deployment was last applied with external secrets
+func (lc *lazyClient) hadExternalSecrets(namespace string, functionName string) (bool, error) {
+	deployment, err := lc.kubeClientSet.AppsV1().
+		Deployments(namespace).
+		Get(kube.DeploymentNameFromFunctionName(functionName), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, errors.Wrap(err, "Failed to get deployment")
+	}
+
+	var appliedExternalSecretNames []string
+	lc.decodeAppliedDeploymentAnnotation(deployment, externalSecretsAnnotation, &appliedExternalSecretNames)
+
+	return len(appliedExternalSecretNames) > 0, nil
+}
+
+// deleteExternalSecrets deletes the function's external secrets, other than those to keep
+func (lc *lazyClient) deleteExternalSecrets(namespace string, functionName string, namesToKeep []string) error {
+	externalSecretsSupported, err := lc.externalSecretsSupported()
+	if err != nil {
+		return errors.Wrap(err, "Failed to check whether external secrets are supported")
+	}
+
+	// nothing could have been created
+	if !externalSecretsSupported {
+		return nil
+	}
+
+	externalSecretsClient := lc.dynamicClient.Resource(externalSecretResource).Namespace(namespace)
+
+	externalSecrets, err := externalSecretsClient.List(metav1.ListOptions{
+		LabelSelector: labels.Set{
+			"nuclio.io/function-name": functionName,
+			"nuclio.io/component":     "external-secret",
+		}.String(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list external secrets")
+	}
+
+	for _, externalSecret := range externalSecrets.Items {
+		if common.StringSliceContainsString(namesToKeep, externalSecret.GetName()) {
+			continue
+		}
+
+		err := externalSecretsClient.Delete(externalSecret.GetName(), &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "Failed to delete external secret %s", externalSecret.GetName())
+		}
+
+		lc.logger.DebugWith("Deleted external secret",
+			"namespace", namespace,
+			"functionName", functionName,
+			"externalSecretName", externalSecret.GetName())
+	}
+
+	return nil
+}
+
+// getExternalSecretStatuses returns whether each of the function's external secrets was synced, per the Ready
+// condition the operator sets on it. best effort
+func (lc *lazyClient) getExternalSecretStatuses(function *nuclioio.NuclioFunction) []functionconfig.ExternalSecretStatus {
+	if len(function.Spec.ExternalSecrets) == 0 || lc.dynamicClient == nil {
+		return nil
+	}
+
+	externalSecretsClient := lc.dynamicClient.Resource(externalSecretResource).Namespace(function.Namespace)
+	var externalSecretStatuses []functionconfig.ExternalSecretStatus
+
+	for _, externalSecret := range function.Spec.ExternalSecrets {
+		externalSecretStatus := functionconfig.ExternalSecretStatus{
+			Name: externalSecret.Name,
+		}
+
+		externalSecretInstance, err := externalSecretsClient.Get(
+			kube.ExternalSecretNameFromFunctionName(function.Name, externalSecret.Name),
+			metav1.GetOptions{})
+		if err != nil {
+			externalSecretStatus.Message = "Failed to get external secret: " + err.Error()
+			externalSecretStatuses = append(externalSecretStatuses, externalSecretStatus)
+			continue
+		}
+
+		externalSecretStatus.Message = "Not synced yet"
+
+		conditions, _, _ := unstructured.NestedSlice(externalSecretInstance.Object, "status", "conditions")
+		for _, condition := range conditions {
+			conditionFields, isMap := condition.(map[string]interface{})
+			if !isMap || conditionFields["type"] != "Ready" {
+				continue
+			}
+
+			externalSecretStatus.Synced = conditionFields["status"] == "True"
+			externalSecretStatus.Message, _ = conditionFields["message"].(string)
+		}
+
+		externalSecretStatuses = append(externalSecretStatuses, externalSecretStatus)
+	}
+
+	return externalSecretStatuses
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rolls the deployment to the image following the current one (the primary image, then the fallback images in
+// order) once the function's pods back off pulling the current image. once out of images, an error is returned
+func (lc *lazyClient) failOverImage(function *nuclioio.NuclioFunction,
+	deployment *appsv1.Deployment,
+	result *WaitAvailableResult) error {
+	if len(function.Spec.FallbackImages) == 0 {
+		return nil
+	}
+
+	containerIndex := -1
+	for index, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "nuclio" {
+			containerIndex = index
+		}
+	}
+
+	if containerIndex == -1 {
+		return nil
+	}
+
+	currentImage := deployment.Spec.Template.Spec.Containers[containerIndex].Image
+
+	pods, err := lc.getFunctionPods(function.Namespace, function.Name)
+	if err != nil {
+		lc.logger.DebugWith("Failed to get function pods, skipping image failover check",
+			"functionName", function.Name,
+			"err", err)
+		return nil
+	}
+
+	// only pods of the current image count, those of previous ones are being replaced
+	backOffMessage := ""
+	for _, pod := range pods {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.Name == "nuclio" &&
+				containerStatus.Image == currentImage &&
+				containerStatus.State.Waiting != nil &&
+				containerStatus.State.Waiting.Reason == "ImagePullBackOff" {
+				backOffMessage = containerStatus.State.Waiting.Message
+			}
+		}
+	}
+
+	if backOffMessage == "" {
+		return nil
+	}
+
+	images := append([]string{function.Spec.Image}, function.Spec.FallbackImages...)
+	nextImage := ""
+	for imageIndex, image := range images {
+		if image == currentImage && imageIndex+1 < len(images) {
+			nextImage = images[imageIndex+1]
+		}
+	}
+
+	if nextImage == "" {
+		return errors.Errorf("Failed pulling image %s, and no fallback images are left: %s", currentImage, backOffMessage)
+	}
+
+	lc.logger.WarnWith("Failed pulling function image, failing over to the next image",
+		"functionName", function.Name,
+		"currentImage", currentImage,
+		"nextImage", nextImage,
+		"message", backOffMessage)
+
+	deployment.Spec.Template.Spec.Containers[containerIndex].Image = nextImage
+	if _, err := lc.kubeClientSet.AppsV1().
+		Deployments(deployment.Namespace).
+		Update(deployment); err != nil {
+		return errors.Wrap(err, "Failed to update deployment image")
+	}
+
+	result.ImageFailedOver = true
+
+	return nil
+}
+
+// returns the image the function's deployment runs, best effort
+func (lc *lazyClient) getDeploymentImage(function *nuclioio.NuclioFunction) string {
+	deployment, err := lc.kubeClientSet.AppsV1().
+		Deployments(function.Namespace).
+		Get(kube.DeploymentNameFromFunctionName(function.Name), metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "nuclio" {
+			return container.Image
+		}
+	}
+
+	return ""
+}
+
+// returns the image the function should run - the fallback image it failed over to, if any. if the function rolls
+// back to its primary image, it does so once it ran the fallback image for a while
+func (lc *lazyClient) getFunctionImage(function *nuclioio.NuclioFunction) string {
+	for _, fallbackImage := range function.Spec.FallbackImages {
+		if fallbackImage != function.Status.ActiveImage {
+			continue
+		}
+
+		if function.Spec.FallbackImageRollback &&
+			function.Status.ActiveImageSince != nil &&
+			time.Since(*function.Status.ActiveImageSince) > fallbackImageRollbackInterval {
+			lc.logger.InfoWith("Attempting to roll back to the function's primary image",
+				"functionName", function.Name,
+				"activeImage", function.Status.ActiveImage,
+				"primaryImage", function.Spec.Image)
+			return function.Spec.Image
+		}
+
+		return fallbackImage
+	}
+
+	return function.Spec.Image
+}
+
+func (lc *lazyClient) validateFallbackImages(function *nuclioio.NuclioFunction) error {
+	images := map[string]bool{
+		function.Spec.Image: true,
+	}
+
+	for _, fallbackImage := range function.Spec.FallbackImages {
+		if fallbackImage == "" {
+			return errors.New("Fallback images must not be empty")
+		}
+
+		if images[fallbackImage] {
+			return errors.Errorf("Image %s is listed more than once", fallbackImage)
+		}
+
+		images[fallbackImage] = true
+	}
+
+	return nil
+}
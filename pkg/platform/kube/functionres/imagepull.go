@@ -0,0 +1,113 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"fmt"
+	"time"
+
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// deletes function pods that have been failing to pull their image for longer than the pull timeout, so that
+// their replacements re-attempt the pull. once the configured retries are exhausted, an error is returned
+func (lc *lazyClient) retryFailedImagePulls(function *nuclioio.NuclioFunction, result *WaitAvailableResult) error {
+	if function.Spec.ImagePullRetries == 0 {
+		return nil
+	}
+
+	pods, err := lc.getFunctionPods(function.Namespace, function.Name)
+	if err != nil {
+		lc.logger.DebugWith("Failed to get function pods, skipping image pull check",
+			"functionName", function.Name,
+			"err", err)
+		return nil
+	}
+
+	imagePullTimeout := defaultImagePullTimeout
+	if function.Spec.ImagePullTimeoutSeconds != 0 {
+		imagePullTimeout = time.Duration(function.Spec.ImagePullTimeoutSeconds) * time.Second
+	}
+
+	for _, pod := range pods {
+		failedPulling, message := lc.podFailedPullingImage(&pod)
+		if !failedPulling || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		// measure from the time the pod was scheduled, which is when the kubelet started pulling
+		pullStartTime := pod.CreationTimestamp.Time
+		for _, podCondition := range pod.Status.Conditions {
+			if podCondition.Type == v1.PodScheduled && podCondition.Status == v1.ConditionTrue {
+				pullStartTime = podCondition.LastTransitionTime.Time
+			}
+		}
+
+		if time.Since(pullStartTime) < imagePullTimeout {
+			continue
+		}
+
+		// retries are budgeted per rollout, so pods of another pod template (e.g. a redeploy with a fixed image)
+		// start counting over
+		podTemplateHash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		if podTemplateHash != result.ImagePullPodTemplateHash {
+			result.ImagePullAttempts = 0
+			result.ImagePullPodTemplateHash = podTemplateHash
+		}
+
+		result.ImagePullAttempts++
+		if result.ImagePullAttempts > function.Spec.ImagePullRetries {
+			return errors.Errorf("Image pull failed %d times: %s", result.ImagePullAttempts, message)
+		}
+
+		lc.logger.InfoWith("Pod failed pulling image, deleting it to force a fresh pull",
+			"functionName", function.Name,
+			"podName", pod.Name,
+			"message", message,
+			"imagePullAttempts", result.ImagePullAttempts,
+			"imagePullRetries", function.Spec.ImagePullRetries)
+
+		if err := lc.kubeClientSet.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{}); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "Failed to delete pod %s", pod.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// returns whether any of the pod's containers failed pulling its image, along with the failure message
+func (lc *lazyClient) podFailedPullingImage(pod *v1.Pod) (bool, string) {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Waiting == nil {
+			continue
+		}
+
+		switch containerStatus.State.Waiting.Reason {
+		case "ErrImagePull", "ImagePullBackOff":
+			return true, fmt.Sprintf("%s: %s", containerStatus.State.Waiting.Reason, containerStatus.State.Waiting.Message)
+		}
+	}
+
+	return false, ""
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// returns the values of the function's immutable fields (encoded as JSON), by path. absent fields are empty
+func (lc *lazyClient) getImmutableFieldValues(function *nuclioio.NuclioFunction) (map[string]string, error) {
+	immutableFieldValues := map[string]string{}
+
+	encodedSpec, err := json.Marshal(function.Spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to encode function spec")
+	}
+
+	var spec map[string]interface{}
+	if err := json.Unmarshal(encodedSpec, &spec); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode function spec")
+	}
+
+	for _, immutableField := range lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.ImmutableFields {
+		var value interface{}
+
+		switch {
+		case strings.HasPrefix(immutableField, "metadata.labels."):
+			if labelValue, found := function.Labels[strings.TrimPrefix(immutableField, "metadata.labels.")]; found {
+				value = labelValue
+			}
+		case strings.HasPrefix(immutableField, "metadata.annotations."):
+			if annotationValue, found := function.Annotations[strings.TrimPrefix(immutableField, "metadata.annotations.")]; found {
+				value = annotationValue
+			}
+		case strings.HasPrefix(immutableField, "spec."):
+			value = spec
+			for _, fieldName := range strings.Split(strings.TrimPrefix(immutableField, "spec."), ".") {
+				fields, isObject := value.(map[string]interface{})
+				if !isObject {
+					value = nil
+					break
+				}
+				value = fields[fieldName]
+			}
+		default:
+			return nil, errors.Errorf("Unsupported immutable field path %s", immutableField)
+		}
+
+		if value == nil {
+			immutableFieldValues[immutableField] = ""
+			continue
+		}
+
+		encodedValue, err := json.Marshal(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to encode value of field %s", immutableField)
+		}
+
+		immutableFieldValues[immutableField] = string(encodedValue)
+	}
+
+	return immutableFieldValues, nil
+}
+
+// rejects changes to the function's immutable fields, compared to their values as last applied to its deployment.
+// fields that weren't immutable when last applied are accepted as is
+func (lc *lazyClient) validateImmutableFields(function *nuclioio.NuclioFunction) error {
+	if len(lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.ImmutableFields) == 0 {
+		return nil
+	}
+
+	immutableFieldValues, err := lc.getImmutableFieldValues(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get immutable field values")
+	}
+
+	deployment, err := lc.kubeClientSet.AppsV1().
+		Deployments(function.Namespace).
+		Get(kube.DeploymentNameFromFunctionName(function.Name), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "Failed to get function deployment")
+	}
+
+	encodedAppliedImmutableFieldValues, found := deployment.Annotations[immutableFieldsAnnotation]
+	if !found {
+		return nil
+	}
+
+	appliedImmutableFieldValues := map[string]string{}
+	if err := json.Unmarshal([]byte(encodedAppliedImmutableFieldValues), &appliedImmutableFieldValues); err != nil {
+		lc.logger.WarnWith("Failed to decode applied immutable field values, skipping validation",
+			"functionName", function.Name,
+			"err", err)
+		return nil
+	}
+
+	var changedFields []string
+	for immutableField, value := range immutableFieldValues {
+		if appliedValue, applied := appliedImmutableFieldValues[immutableField]; applied && appliedValue != value {
+			changedFields = append(changedFields, immutableField)
+		}
+	}
+
+	switch len(changedFields) {
+	case 0:
+		return nil
+	case 1:
+		return errors.Errorf("Field %s is immutable", changedFields[0])
+	default:
+		sort.Strings(changedFields)
+		return errors.Errorf("Fields %s are immutable", strings.Join(changedFields, ", "))
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (lc *lazyClient) CheckIngressConflicts(ctx context.Context, function *nuclioio.NuclioFunction) error {
+	if lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.UniqueIngressHosts {
+		if err := lc.checkIngressHostUniqueness(function); err != nil {
+			return err
+		}
+	}
+
+	if function.Spec.IngressConflictPolicy == "" {
+		return nil
+	}
+
+	return lc.checkIngressConflicts(function)
+}
+
+func (lc *lazyClient) checkIngressConflicts(function *nuclioio.NuclioFunction) error {
+	ingressSpec, err := lc.getIngressSpec(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get ingress spec")
+	}
+
+	if len(ingressSpec.Rules) == 0 {
+		return nil
+	}
+
+	ingresses, err := lc.getIngressAccessor()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get ingress accessor")
+	}
+
+	otherIngresses, err := ingresses.List(function.Namespace, metav1.ListOptions{
+		LabelSelector: "nuclio.io/class=function",
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list function ingresses")
+	}
+
+	var conflictMessages []string
+	for _, otherIngress := range otherIngresses {
+		otherFunctionName := otherIngress.Labels["nuclio.io/function-name"]
+		if otherFunctionName == "" || otherFunctionName == function.Name {
+			continue
+		}
+
+		for _, rule := range ingressSpec.Rules {
+			for _, otherRule := range otherIngress.Spec.Rules {
+				if rule.Host != otherRule.Host || rule.HTTP == nil || otherRule.HTTP == nil {
+					continue
+				}
+
+				for _, path := range rule.HTTP.Paths {
+					for _, otherPath := range otherRule.HTTP.Paths {
+						if ingressPathsOverlap(path.Path, otherPath.Path) {
+							conflictMessages = append(conflictMessages,
+								fmt.Sprintf("Path %s on host %s overlaps path %s of function %s",
+									path.Path,
+									rule.Host,
+									otherPath.Path,
+									otherFunctionName))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if len(conflictMessages) == 0 {
+		return nil
+	}
+
+	// keep the message stable across resyncs
+	sort.Strings(conflictMessages)
+
+	return errors.Wrap(ErrIngressConflict, strings.Join(conflictMessages, "; "))
+}
+
+// returns whether requests to one of the paths may be routed by the other - i.e. they're equal, or one is a
+// prefix of the other (by path segments)
+func ingressPathsOverlap(path string, otherPath string) bool {
+	path = strings.TrimSuffix(path, "/")
+	otherPath = strings.TrimSuffix(otherPath, "/")
+
+	return path == otherPath ||
+		strings.HasPrefix(otherPath, path+"/") ||
+		strings.HasPrefix(path, otherPath+"/")
+}
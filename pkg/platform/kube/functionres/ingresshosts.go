@@ -0,0 +1,104 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkIngressHostUniqueness fails functions whose ingress hosts are used by functions in other namespaces, which
+// may well be functions of the same name, owned by other teams
+func (lc *lazyClient) checkIngressHostUniqueness(function *nuclioio.NuclioFunction) error {
+	ingressSpec, err := lc.getIngressSpec(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get ingress spec")
+	}
+
+	hosts := map[string]bool{}
+	for _, rule := range ingressSpec.Rules {
+		if rule.Host != "" {
+			hosts[rule.Host] = true
+		}
+	}
+
+	// a controller watching a single namespace has no other namespaces' functions to collide with
+	if len(hosts) == 0 || lc.watchedNamespace != metav1.NamespaceAll {
+		return nil
+	}
+
+	ingresses, err := lc.getIngressAccessor()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get ingress accessor")
+	}
+
+	otherIngresses, err := ingresses.List(lc.watchedNamespace, metav1.ListOptions{
+		LabelSelector: "nuclio.io/class=function",
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list function ingresses")
+	}
+
+	var conflictMessages []string
+	for _, otherIngress := range otherIngresses {
+		otherFunctionName := otherIngress.Labels["nuclio.io/function-name"]
+		if otherFunctionName == "" || otherIngress.Namespace == function.Namespace {
+			continue
+		}
+
+		conflictingHosts := map[string]bool{}
+		for _, otherRule := range otherIngress.Spec.Rules {
+			if hosts[otherRule.Host] && !conflictingHosts[otherRule.Host] {
+				conflictingHosts[otherRule.Host] = true
+				conflictMessages = append(conflictMessages,
+					fmt.Sprintf("Host %s is used by function %s in namespace %s",
+						otherRule.Host,
+						otherFunctionName,
+						otherIngress.Namespace))
+			}
+		}
+	}
+
+	if len(conflictMessages) == 0 {
+		return nil
+	}
+
+	// keep the message stable across resyncs
+	sort.Strings(conflictMessages)
+
+	return errors.Wrap(ErrIngressConflict, strings.Join(conflictMessages, "; "))
+}
+
+// returns the spec of the function's ingress, as it would be rendered
+func (lc *lazyClient) getIngressSpec(function *nuclioio.NuclioFunction) (*extv1beta1.IngressSpec, error) {
+	functionLabels := lc.getFunctionLabels(function)
+	functionLabels["nuclio.io/function-name"] = function.Name
+
+	ingressMeta := metav1.ObjectMeta{}
+	ingressSpec := extv1beta1.IngressSpec{}
+	if err := lc.populateIngressConfig(functionLabels, function, &ingressMeta, &ingressSpec); err != nil {
+		return nil, errors.Wrap(err, "Failed to populate ingress spec")
+	}
+
+	return &ingressSpec, nil
+}
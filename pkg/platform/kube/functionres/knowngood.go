@@ -0,0 +1,161 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// returns the function's known-good status if it's scaling from zero with the image and configuration recorded in
+// it, and short-circuits readiness for them. nil otherwise
+func (lc *lazyClient) getMatchingKnownGood(function *nuclioio.NuclioFunction) *functionconfig.KnownGoodStatus {
+	if function.Spec.KnownGoodReadinessTimeoutSeconds <= 0 ||
+		function.Status.State != functionconfig.FunctionStateWaitingForScaleResourcesFromZero ||
+		function.Status.KnownGood == nil {
+		return nil
+	}
+
+	specChecksum, err := getSpecChecksum(function)
+	if err != nil || specChecksum != function.Status.KnownGood.SpecChecksum {
+		return nil
+	}
+
+	return function.Status.KnownGood
+}
+
+// returns what the function is ready with - its spec checksum and the ID of the image its pods run. nil if the
+// pods don't all run the same image (e.g. mid rollout)
+func (lc *lazyClient) getKnownGood(function *nuclioio.NuclioFunction) *functionconfig.KnownGoodStatus {
+	specChecksum, err := getSpecChecksum(function)
+	if err != nil {
+		lc.logger.WarnWith("Failed to get function spec checksum", "functionName", function.Name, "err", err)
+		return nil
+	}
+
+	pods, err := lc.getFunctionPods(function.Namespace, function.Name)
+	if err != nil {
+		lc.logger.WarnWith("Failed to get function pods", "functionName", function.Name, "err", err)
+		return nil
+	}
+
+	imageID := ""
+	for podIndex := range pods {
+		podImageID := getReadyFunctionContainerImageID(&pods[podIndex])
+		if podImageID == "" {
+			continue
+		}
+
+		if imageID != "" && imageID != podImageID {
+			return nil
+		}
+
+		imageID = podImageID
+	}
+
+	if imageID == "" {
+		return nil
+	}
+
+	return &functionconfig.KnownGoodStatus{
+		SpecChecksum: specChecksum,
+		ImageID:      imageID,
+	}
+}
+
+// waits for a replica of the deployment to be available, running the known-good image. unlike a full verification,
+// the other replicas aren't waited for, and failing pods aren't acted on
+func (lc *lazyClient) waitKnownGoodDeploymentAvailable(ctx context.Context,
+	function *nuclioio.NuclioFunction,
+	knownGood *functionconfig.KnownGoodStatus,
+	result *WaitAvailableResult) error {
+	deploymentName := kube.DeploymentNameFromFunctionName(function.Name)
+	waitMs := 250
+
+	for {
+		time.Sleep(time.Duration(waitMs) * time.Millisecond)
+
+		// exponentially wait more next time, up to 1 second
+		waitMs *= 2
+		if waitMs > 1000 {
+			waitMs = 1000
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		deployment, err := lc.kubeClientSet.AppsV1().
+			Deployments(function.Namespace).
+			Get(deploymentName, metav1.GetOptions{})
+		if err != nil || deployment.Status.AvailableReplicas == 0 {
+			continue
+		}
+
+		pods, err := lc.getFunctionPods(function.Namespace, function.Name)
+		if err != nil {
+			continue
+		}
+
+		for podIndex := range pods {
+			imageID := getReadyFunctionContainerImageID(&pods[podIndex])
+			if imageID == "" {
+				continue
+			}
+
+			// the tag may have been pushed over since - the image isn't known to be good anymore
+			if imageID != knownGood.ImageID {
+				return errors.Errorf("Function runs image %s rather than the known-good %s", imageID, knownGood.ImageID)
+			}
+
+			result.AvailableReplicas = int(deployment.Status.AvailableReplicas)
+			return nil
+		}
+	}
+}
+
+// returns the ID of the image the pod's function container runs, if the container is ready
+func getReadyFunctionContainerImageID(pod *v1.Pod) string {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Name == "nuclio" && containerStatus.Ready {
+			return containerStatus.ImageID
+		}
+	}
+
+	return ""
+}
+
+// returns a checksum of the function's spec, identifying its image and configuration
+func getSpecChecksum(function *nuclioio.NuclioFunction) (string, error) {
+	encodedSpec, err := json.Marshal(function.Spec)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to marshal function spec")
+	}
+
+	specChecksum := sha256.Sum256(encodedSpec)
+	return hex.EncodeToString(specChecksum[:]), nil
+}
@@ -21,10 +21,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -59,7 +62,11 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 const (
@@ -67,6 +74,74 @@ const (
 	containerMetricPort           = 8090
 	containerMetricPortName       = "metrics"
 	nginxIngressUpdateGracePeriod = 5 * time.Second
+
+	nginxIngressProxyBodySizeAnnotation = "nginx.ingress.kubernetes.io/proxy-body-size"
+	nginxIngressAuthAnnotationPrefix    = "nginx.ingress.kubernetes.io/auth-"
+
+	// the reason of a deployment's Progressing condition once its rollout exceeded the progress deadline
+	deploymentProgressDeadlineExceededReason = "ProgressDeadlineExceeded"
+
+	// annotations through which services prefer same-zone endpoints, by the kubernetes versions supporting them
+	serviceTopologyModeAnnotation           = "service.kubernetes.io/topology-mode"
+	serviceTopologyAwareHintsAnnotation     = "service.kubernetes.io/topology-aware-hints"
+	serviceTopologyModeMinimumVersion       = "1.27"
+	serviceTopologyAwareHintsMinimumVersion = "1.24"
+
+	// set on the pod template when the function is restarted, to roll out new pods
+	functionRestartedAtAnnotation = "nuclio.io/restarted-at"
+
+	// set on the resources owned by a function, holding the generation of the function that produced them
+	deployGenerationLabel = "nuclio.io/deploy-generation"
+
+	// set on a function to attach one of the platform's debug sidecars to its pods, by name. as function
+	// annotations, it's propagated to the pod template
+	debugSidecarAnnotation = "nuclio.io/debug-sidecar"
+
+	// set on the deployment, holding the tolerations of the function's node pool as last applied to its pods
+	nodePoolTolerationsAnnotation = "nuclio.io/node-pool-tolerations"
+
+	// set on the deployment, holding the node selector of the function's capacity tier as last applied to its pods
+	capacityTierNodeSelectorAnnotation = "nuclio.io/capacity-tier-node-selector"
+
+	// set on the deployment, holding the name of the debug sidecar container attached to its pods
+	debugSidecarContainerAnnotation = "nuclio.io/debug-sidecar-container"
+
+	// how long a pod may fail pulling the function's image before it's deleted, unless the function says otherwise
+	defaultImagePullTimeout = 2 * time.Minute
+
+	// how long a scheduled pod may take to start before it's rescheduled, unless the function says otherwise
+	defaultPodStartupTimeout = 5 * time.Minute
+
+	// how many of the most recent cron job runs are recorded in the function's status
+	maxRecordedJobRuns = 5
+
+	// how long a function runs a fallback image before rolling back to its primary image is attempted
+	fallbackImageRollbackInterval = 30 * time.Minute
+
+	// how long the results of cluster lookups (e.g. the API versions the cluster serves) are cached for
+	clusterLookupCacheTTL = time.Minute
+
+	// set on the deployment, holding the values of the function's immutable fields as last applied
+	immutableFieldsAnnotation = "nuclio.io/immutable-fields"
+
+	// set on the deployment, holding the names of the function's external secrets as last applied. functions
+	// whose deployment doesn't have it have no external secrets to clean up
+	externalSecretsAnnotation = "nuclio.io/external-secrets"
+
+	// where the function's TLS secret is mounted, when the function serves TLS
+	functionTLSVolumeName = "tls-volume"
+	functionTLSMountPath  = "/etc/nuclio/tls"
+
+	// functions' external secrets are synced by the external secrets operator, through these resources
+	externalSecretAPIVersion         = "external-secrets.io/v1beta1"
+	externalSecretKind               = "ExternalSecret"
+	defaultExternalSecretStoreKind   = "ClusterSecretStore"
+	defaultExternalSecretRefreshRate = "1h"
+
+	// a configmap by this name holds defaults for the functions of its namespace, as a function config
+	// (metadata and spec) under this key
+	FunctionDefaultsConfigMapName = "nuclio-function-defaults"
+	FunctionDefaultsConfigMapKey  = "functionDefaults.yaml"
 )
 
 type deploymentResourceMethod string
@@ -84,19 +159,42 @@ type lazyClient struct {
 	logger                        logger.Logger
 	kubeClientSet                 kubernetes.Interface
 	nuclioClientSet               nuclioioclient.Interface
+	dynamicClient                 dynamic.Interface
 	classLabels                   labels.Set
 	platformConfigurationProvider PlatformConfigurationProvider
+
+	// the namespace the controller watches functions in, empty for all namespaces. lookups across functions are
+	// scoped to it
+	watchedNamespace string
+
+	// stable node ports are allocated off the ports that services hold, so allocating one holds off others until
+	// the service holding it is created
+	stableNodePortAllocationLock sync.Mutex
+
+	// lookups of the cluster's capabilities, cached across reconciles
+	clusterLookups *clusterLookupCache
+
+	// the namespaces' function defaults and the cluster's nodes are read off caches, started along with the
+	// controller, rather than off the API server on every reconcile
+	cachesLock             sync.Mutex
+	functionDefaultsLister corev1listers.ConfigMapLister
+	functionDefaultsSynced cache.InformerSynced
+	nodeLister             corev1listers.NodeLister
+	nodesSynced            cache.InformerSynced
 }
 
 func NewLazyClient(parentLogger logger.Logger,
 	kubeClientSet kubernetes.Interface,
-	nuclioClientSet nuclioioclient.Interface) (Client, error) {
+	nuclioClientSet nuclioioclient.Interface,
+	dynamicClient dynamic.Interface) (Client, error) {
 
 	newClient := lazyClient{
 		logger:          parentLogger.GetChild("functionres"),
 		kubeClientSet:   kubeClientSet,
 		nuclioClientSet: nuclioClientSet,
+		dynamicClient:   dynamicClient,
 		classLabels:     make(labels.Set),
+		clusterLookups:  newClusterLookupCache(clusterLookupCacheTTL),
 	}
 
 	newClient.initClassLabels()
@@ -196,6 +294,25 @@ func (lc *lazyClient) CreateOrUpdate(ctx context.Context, function *nuclioio.Nuc
 		}
 	}
 
+	functionLabels = labels.Merge(lc.getFunctionLabels(function), functionLabels)
+
+	// validate the function before creating any resources
+	if err := lc.validateFunction(function); err != nil {
+		return nil, errors.Wrap(err, "Failed to validate function")
+	}
+
+	if lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.UniqueIngressHosts {
+		if err := lc.checkIngressHostUniqueness(function); err != nil {
+			return nil, errors.Wrap(err, "Failed to check ingress host uniqueness")
+		}
+	}
+
+	if function.Spec.IngressConflictPolicy == functionconfig.IngressConflictPolicyReject {
+		if err := lc.checkIngressConflicts(function); err != nil {
+			return nil, errors.Wrap(err, "Failed to check ingress conflicts")
+		}
+	}
+
 	// create or update the applicable configMap
 	if resources.configMap, err = lc.createOrUpdateConfigMap(function); err != nil {
 		return nil, errors.Wrap(err, "Failed to create/update configMap")
@@ -206,6 +323,16 @@ func (lc *lazyClient) CreateOrUpdate(ctx context.Context, function *nuclioio.Nuc
 		return nil, errors.Wrap(err, "Failed to create/update service")
 	}
 
+	// create or update the service alias
+	if resources.serviceAlias, err = lc.createOrUpdateServiceAlias(functionLabels, function); err != nil {
+		return nil, errors.Wrap(err, "Failed to create/update service alias")
+	}
+
+	// create or update the external secrets, which the deployment's pods mount
+	if err = lc.createOrUpdateExternalSecrets(functionLabels, function); err != nil {
+		return nil, errors.Wrap(err, "Failed to create/update external secrets")
+	}
+
 	// create or update the applicable deployment
 	if resources.deployment, err = lc.createOrUpdateDeployment(functionLabels,
 		imagePullSecrets,
@@ -235,13 +362,196 @@ func (lc *lazyClient) CreateOrUpdate(ctx context.Context, function *nuclioio.Nuc
 	return &resources, nil
 }
 
-func (lc *lazyClient) WaitAvailable(ctx context.Context, namespace string, name string) error {
-	deploymentName := kube.DeploymentNameFromFunctionName(name)
+func (lc *lazyClient) WaitAvailable(ctx context.Context, function *nuclioio.NuclioFunction) (*WaitAvailableResult, error) {
+	deploymentName := kube.DeploymentNameFromFunctionName(function.Name)
 	lc.logger.DebugWith("Waiting for deployment to be available",
-		"namespace", namespace,
-		"functionName", name,
+		"namespace", function.Namespace,
+		"functionName", function.Name,
 		"deploymentName", deploymentName)
 
+	// image pulls are retried a limited number of times per rollout, however many waits it takes
+	result := &WaitAvailableResult{
+		ImagePullAttempts:        function.Status.ImagePullAttempts,
+		ImagePullPodTemplateHash: function.Status.ImagePullPodTemplateHash,
+	}
+
+	// a function scaling from zero with its known-good image and configuration is given a shorter wait with
+	// lighter checks, falling back to full verification if it doesn't pass them
+	var waitErr error
+	if knownGood := lc.getMatchingKnownGood(function); knownGood != nil {
+		knownGoodCtx, cancel := context.WithTimeout(ctx,
+			time.Duration(function.Spec.KnownGoodReadinessTimeoutSeconds)*time.Second)
+		knownGoodErr := lc.waitKnownGoodDeploymentAvailable(knownGoodCtx, function, knownGood, result)
+		cancel()
+
+		if knownGoodErr == nil {
+			lc.logger.InfoWith("Short-circuited readiness of known-good function",
+				"functionName", function.Name,
+				"imageID", knownGood.ImageID)
+			result.KnownGoodShortCircuited = true
+		} else {
+			lc.logger.InfoWith("Known-good function not available, falling back to full readiness verification",
+				"functionName", function.Name,
+				"err", errors.Cause(knownGoodErr))
+		}
+	}
+
+	if !result.KnownGoodShortCircuited {
+		waitErr = lc.waitDeploymentAvailable(ctx, function, result)
+	}
+
+	// what's only recorded as the function becomes ready is skipped on resyncs of a function that's ready (or
+	// scaled to zero), whose deployment didn't change since
+	provisioning := functionconfig.FunctionStateProvisioning(function.Status.State)
+
+	// record what the function was verified ready with, to short-circuit its readiness next time
+	if waitErr == nil && provisioning && function.Spec.KnownGoodReadinessTimeoutSeconds > 0 {
+		result.KnownGood = lc.getKnownGood(function)
+	}
+
+	// attribute the time spent waiting, whether the deployment became available or not, so that the bottleneck
+	// of a slow deployment is visible
+	if provisioning || waitErr != nil {
+		result.ProvisioningPhases = lc.getProvisioningPhases(function.Namespace, function.Name)
+	}
+
+	if waitErr != nil {
+		result.UnhealthyCategory = lc.getUnhealthyCategory(function.Namespace, function.Name)
+	}
+
+	// pods can't start before their external secrets are synced, so report why they weren't
+	result.ExternalSecrets = lc.getExternalSecretStatuses(function)
+
+	if len(function.Spec.FallbackImages) > 0 {
+		result.ActiveImage = lc.getDeploymentImage(function)
+	}
+
+	result.DebugSidecar = lc.getDeploymentDebugSidecar(function)
+
+	// the processor reports which of the triggers it started in order are ready. best effort
+	if waitErr == nil && provisioning && len(function.Spec.TriggerStartOrder) > 0 {
+		activeTriggers, err := lc.getActiveTriggers(ctx, function)
+		if err != nil {
+			lc.logger.WarnWith("Failed to get function active triggers",
+				"functionName", function.Name,
+				"err", errors.Cause(err))
+		}
+		result.ActiveTriggers = activeTriggers
+	}
+
+	if lc.platformConfigurationProvider.GetPlatformConfiguration().CronTriggerCreationMode == platformconfig.KubeCronTriggerCreationMode {
+		jobRuns, err := lc.cleanupFinishedJobs(function)
+		if err != nil {
+			lc.logger.WarnWith("Failed to clean up function finished jobs",
+				"functionName", function.Name,
+				"err", errors.Cause(err))
+		}
+		result.JobRuns = jobRuns
+	}
+
+	return result, waitErr
+}
+
+// deletes the function's finished cron jobs beyond its retention count, most recent kept. returns the outcomes
+// of the most recent runs
+func (lc *lazyClient) cleanupFinishedJobs(function *nuclioio.NuclioFunction) ([]functionconfig.JobRunStatus, error) {
+	if len(functionconfig.GetTriggersByKind(function.Spec.Triggers, "cron")) == 0 {
+		return nil, nil
+	}
+
+	jobs, err := lc.kubeClientSet.BatchV1().
+		Jobs(function.Namespace).
+		List(metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("nuclio.io/function-name=%s,nuclio.io/function-cron-job-pod=true", function.Name),
+		})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list function jobs")
+	}
+
+	type finishedJob struct {
+		name     string
+		finished time.Time
+		run      functionconfig.JobRunStatus
+	}
+
+	var finishedJobs []finishedJob
+	for _, job := range jobs.Items {
+		for _, jobCondition := range job.Status.Conditions {
+			if jobCondition.Status != v1.ConditionTrue ||
+				(jobCondition.Type != batchv1.JobComplete && jobCondition.Type != batchv1.JobFailed) {
+				continue
+			}
+
+			// in UTC, as read back from the function's status - lest the status be considered changed
+			finished := jobCondition.LastTransitionTime.Time.UTC()
+			finishedJobs = append(finishedJobs, finishedJob{
+				name:     job.Name,
+				finished: finished,
+				run: functionconfig.JobRunStatus{
+					Name:           job.Name,
+					Succeeded:      jobCondition.Type == batchv1.JobComplete,
+					CompletionTime: &finished,
+					Message:        jobCondition.Message,
+				},
+			})
+			break
+		}
+	}
+
+	sort.Slice(finishedJobs, func(i, j int) bool {
+		return finishedJobs[i].finished.After(finishedJobs[j].finished)
+	})
+
+	var jobRuns []functionconfig.JobRunStatus
+	for finishedJobIndex, finishedJob := range finishedJobs {
+		if function.Spec.JobRetentionCount > 0 && finishedJobIndex >= function.Spec.JobRetentionCount {
+			lc.logger.DebugWith("Deleting finished function job beyond retention count",
+				"functionName", function.Name,
+				"jobName", finishedJob.name)
+
+			propagationPolicy := metav1.DeletePropagationBackground
+			if err := lc.kubeClientSet.BatchV1().
+				Jobs(function.Namespace).
+				Delete(finishedJob.name, &metav1.DeleteOptions{
+					PropagationPolicy: &propagationPolicy,
+				}); err != nil && !apierrors.IsNotFound(err) {
+				return jobRuns, errors.Wrapf(err, "Failed to delete job %s", finishedJob.name)
+			}
+			continue
+		}
+
+		if len(jobRuns) < maxRecordedJobRuns {
+			jobRuns = append(jobRuns, finishedJob.run)
+		}
+	}
+
+	return jobRuns, nil
+}
+
+// classifies why the function's deployment isn't available, best effort
+func (lc *lazyClient) getUnhealthyCategory(namespace string, name string) functionconfig.UnhealthyCategory {
+	deployment, err := lc.kubeClientSet.AppsV1().
+		Deployments(namespace).
+		Get(kube.DeploymentNameFromFunctionName(name), metav1.GetOptions{})
+	if err != nil {
+		deployment = nil
+	}
+
+	pods, err := lc.getFunctionPods(namespace, name)
+	if err != nil {
+		lc.logger.DebugWith("Failed to get function pods, classifying without them",
+			"functionName", name,
+			"err", err)
+	}
+
+	return ClassifyUnhealthy(deployment, pods)
+}
+
+func (lc *lazyClient) waitDeploymentAvailable(ctx context.Context,
+	function *nuclioio.NuclioFunction,
+	result *WaitAvailableResult) error {
+	namespace := function.Namespace
+	deploymentName := kube.DeploymentNameFromFunctionName(function.Name)
 	waitMs := 250
 
 	for {
@@ -261,7 +571,7 @@ func (lc *lazyClient) WaitAvailable(ctx context.Context, namespace string, name
 		}
 
 		// get the deployment. if it doesn't exist yet, retry a bit later
-		result, err := lc.kubeClientSet.AppsV1().
+		deployment, err := lc.kubeClientSet.AppsV1().
 			Deployments(namespace).
 			Get(deploymentName, metav1.GetOptions{})
 		if err != nil {
@@ -269,32 +579,201 @@ func (lc *lazyClient) WaitAvailable(ctx context.Context, namespace string, name
 		}
 
 		// find the condition whose type is Available - that's the one we want to examine
-		for _, deploymentCondition := range result.Status.Conditions {
+		for _, deploymentCondition := range deployment.Status.Conditions {
 
 			// when we find the right condition, check its Status to see if it's true.
 			// a DeploymentCondition whose Type == Available and Status == True means the deployment is available
 			if deploymentCondition.Type == appsv1.DeploymentAvailable {
 				available := deploymentCondition.Status == v1.ConditionTrue
 
-				if available && result.Status.UnavailableReplicas == 0 {
+				if available && deployment.Status.UnavailableReplicas == 0 {
 					lc.logger.DebugWith("Deployment is available",
 						"reason", deploymentCondition.Reason,
 						"deploymentName", deploymentName)
+					result.AvailableReplicas = int(deployment.Status.AvailableReplicas)
 					return nil
 				}
 
 				lc.logger.DebugWith("Deployment not available yet",
 					"reason", deploymentCondition.Reason,
-					"unavailableReplicas", result.Status.UnavailableReplicas,
+					"unavailableReplicas", deployment.Status.UnavailableReplicas,
 					"deploymentName", deploymentName)
 
 				// we found the condition, wasn't available
 				break
 			}
 		}
+
+		// a ready function scaling up may settle for some of its target replicas, leaving the rest to come up in
+		// the background
+		result.PartialReadiness = lc.getScaleUpPartialReadiness(function, deployment)
+		if result.PartialReadiness != nil {
+			lc.logger.InfoWith("Deployment scale up is partially ready",
+				"deploymentName", deploymentName,
+				"readyReplicas", result.PartialReadiness.ReadyReplicas,
+				"targetReplicas", result.PartialReadiness.TargetReplicas)
+			result.AvailableReplicas = result.PartialReadiness.ReadyReplicas
+			return nil
+		}
+
+		// the rollout won't make any more progress. report it specifically rather than waiting out the timeout
+		if progressDeadlineExceededMessage := lc.getProgressDeadlineExceededMessage(deployment); progressDeadlineExceededMessage != "" {
+			return errors.Errorf("Deployment exceeded its progress deadline: %s", progressDeadlineExceededMessage)
+		}
+
+		// if the quota doesn't allow any more replicas, settle for the ones that are already available - the
+		// deployment will keep trying to scale up, and resyncs will pick it up once the quota allows
+		result.ScaleUpBlockedMessage = lc.getQuotaExceededMessage(deployment)
+		if result.ScaleUpBlockedMessage != "" {
+			lc.logger.WarnWith("Deployment scale up is blocked by quota",
+				"deploymentName", deploymentName,
+				"availableReplicas", deployment.Status.AvailableReplicas,
+				"message", result.ScaleUpBlockedMessage)
+
+			// only replicas of the current pod template count, otherwise an update could be considered
+			// available while running the previous template
+			if deployment.Status.AvailableReplicas > 0 &&
+				deployment.Status.UpdatedReplicas == deployment.Status.Replicas {
+				return nil
+			}
+
+			return errors.Errorf("Scale up blocked by quota: %s", result.ScaleUpBlockedMessage)
+		}
+
+		// roll the deployment to the next fallback image if pods can't pull the current one
+		if err := lc.failOverImage(function, deployment, result); err != nil {
+			return errors.Wrap(err, "Failed to fail over function image")
+		}
+
+		// force a fresh pull for pods that fail pulling the image, or bail once out of retries
+		if err := lc.retryFailedImagePulls(function, result); err != nil {
+			return errors.Wrap(err, "Failed to pull function image")
+		}
+
+		// force a reschedule of pods stuck starting, or bail once out of retries
+		if err := lc.rescheduleStuckPods(function, result); err != nil {
+			return errors.Wrap(err, "Failed to start function pods")
+		}
+	}
+}
+
+// returns how many of the deployment's target replicas are ready, if the function is ready and scaling up with
+// enough of them ready to settle for. nil otherwise
+func (lc *lazyClient) getScaleUpPartialReadiness(function *nuclioio.NuclioFunction,
+	deployment *appsv1.Deployment) *functionconfig.PartialReadinessStatus {
+	if function.Status.State != functionconfig.FunctionStateReady ||
+		function.Spec.ScaleUpMinReadyFraction == 0 ||
+		deployment.Spec.Replicas == nil {
+		return nil
+	}
+
+	// only a scale up of the current pod template counts, otherwise an update could be considered ready while
+	// running the previous template
+	if deployment.Status.ObservedGeneration < deployment.Generation ||
+		deployment.Status.UpdatedReplicas != deployment.Status.Replicas {
+		return nil
+	}
+
+	targetReplicas := int(*deployment.Spec.Replicas)
+	readyReplicas := int(deployment.Status.AvailableReplicas)
+	minReadyReplicas, err := function.Spec.GetScaleUpMinReadyReplicas(targetReplicas)
+	if err != nil || readyReplicas >= targetReplicas || readyReplicas < minReadyReplicas {
+		return nil
+	}
+
+	return &functionconfig.PartialReadinessStatus{
+		ReadyReplicas:  readyReplicas,
+		TargetReplicas: targetReplicas,
 	}
 }
 
+// returns the message of the deployment's Progressing condition, if its rollout exceeded the progress deadline
+func (lc *lazyClient) getProgressDeadlineExceededMessage(deployment *appsv1.Deployment) string {
+	for _, deploymentCondition := range deployment.Status.Conditions {
+		if deploymentCondition.Type == appsv1.DeploymentProgressing &&
+			deploymentCondition.Status == v1.ConditionFalse &&
+			deploymentCondition.Reason == deploymentProgressDeadlineExceededReason {
+			return deploymentCondition.Message
+		}
+	}
+
+	return ""
+}
+
+// returns the reason the deployment failed creating replicas, if it's due to an exceeded resource quota
+func (lc *lazyClient) getQuotaExceededMessage(deployment *appsv1.Deployment) string {
+	for _, deploymentCondition := range deployment.Status.Conditions {
+		if deploymentCondition.Type == appsv1.DeploymentReplicaFailure &&
+			deploymentCondition.Status == v1.ConditionTrue &&
+			strings.Contains(deploymentCondition.Message, "exceeded quota") {
+			return deploymentCondition.Message
+		}
+	}
+
+	return ""
+}
+
+func (lc *lazyClient) validateWarmupRequests(function *nuclioio.NuclioFunction) error {
+	for warmupRequestIndex, warmupRequest := range function.Spec.WarmupRequests {
+		switch warmupRequest.GetMethod() {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return errors.Errorf("Warm-up request %d has unsupported method %s", warmupRequestIndex, warmupRequest.Method)
+		}
+
+		if !strings.HasPrefix(warmupRequest.Path, "/") {
+			return errors.Errorf("Warm-up request %d path must start with /", warmupRequestIndex)
+		}
+
+		if expectedStatusCode := warmupRequest.GetExpectedStatusCode(); expectedStatusCode < 100 || expectedStatusCode > 599 {
+			return errors.Errorf("Warm-up request %d has invalid expected status code %d",
+				warmupRequestIndex,
+				expectedStatusCode)
+		}
+	}
+
+	return nil
+}
+
+// returns the pods of the function's deployment (excluding cron job pods)
+func (lc *lazyClient) getFunctionPods(namespace string, name string) ([]v1.Pod, error) {
+	pods, err := lc.kubeClientSet.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("nuclio.io/function-name=%s,!nuclio.io/function-cron-job-pod", name),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list function pods")
+	}
+
+	return pods.Items, nil
+}
+
+func (lc *lazyClient) Restart(ctx context.Context, function *nuclioio.NuclioFunction) error {
+	deploymentName := kube.DeploymentNameFromFunctionName(function.Name)
+
+	deployment, err := lc.kubeClientSet.AppsV1().
+		Deployments(function.Namespace).
+		Get(deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to get function deployment")
+	}
+
+	// changing the pod template rolls out new pods, per the deployment's strategy
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[functionRestartedAtAnnotation] = time.Now().Format(time.RFC3339)
+
+	if _, err := lc.kubeClientSet.AppsV1().Deployments(function.Namespace).Update(deployment); err != nil {
+		return errors.Wrap(err, "Failed to update function deployment")
+	}
+
+	lc.logger.InfoWith("Restarted function",
+		"namespace", function.Namespace,
+		"functionName", function.Name)
+
+	return nil
+}
+
 func (lc *lazyClient) Delete(ctx context.Context, namespace string, name string) error {
 	propagationPolicy := metav1.DeletePropagationForeground
 	deleteOptions := &metav1.DeleteOptions{
@@ -303,7 +782,12 @@ func (lc *lazyClient) Delete(ctx context.Context, namespace string, name string)
 
 	// Delete ingress
 	ingressName := kube.IngressNameFromFunctionName(name)
-	err := lc.kubeClientSet.ExtensionsV1beta1().Ingresses(namespace).Delete(ingressName, deleteOptions)
+	ingresses, err := lc.getIngressAccessor()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get ingress accessor")
+	}
+
+	err = ingresses.Delete(namespace, ingressName, deleteOptions)
 	if err != nil {
 		if !apierrors.IsNotFound(err) {
 			return errors.Wrap(err, "Failed to delete ingress")
@@ -314,7 +798,12 @@ func (lc *lazyClient) Delete(ctx context.Context, namespace string, name string)
 
 	// Delete HPA if exists
 	hpaName := kube.HPANameFromFunctionName(name)
-	err = lc.kubeClientSet.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).Delete(hpaName, deleteOptions)
+	hpas, err := lc.getHPAAccessor()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get HPA accessor")
+	}
+
+	err = hpas.Delete(namespace, hpaName, deleteOptions)
 	if err != nil {
 		if !apierrors.IsNotFound(err) {
 			return errors.Wrap(err, "Failed to delete HPA")
@@ -334,6 +823,17 @@ func (lc *lazyClient) Delete(ctx context.Context, namespace string, name string)
 		lc.logger.DebugWith("Deleted service", "namespace", namespace, "serviceName", serviceName)
 	}
 
+	// Delete service aliases if exist
+	if err = lc.deleteServiceAliases(namespace, name, ""); err != nil {
+		return errors.Wrap(err, "Failed to delete service aliases")
+	}
+
+	// whether the function has external secrets to delete, as told by its deployment
+	hadExternalSecrets, err := lc.hadExternalSecrets(namespace, name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to check whether function had external secrets")
+	}
+
 	// Delete Deployment if exists
 	deploymentName := kube.DeploymentNameFromFunctionName(name)
 	err = lc.kubeClientSet.AppsV1().Deployments(namespace).Delete(deploymentName, deleteOptions)
@@ -347,6 +847,13 @@ func (lc *lazyClient) Delete(ctx context.Context, namespace string, name string)
 			"deploymentName", deploymentName)
 	}
 
+	// Delete external secrets (their secrets are deleted along with them)
+	if hadExternalSecrets {
+		if err = lc.deleteExternalSecrets(namespace, name, nil); err != nil {
+			return errors.Wrap(err, "Failed to delete external secrets")
+		}
+	}
+
 	// Delete configMap if exists
 	configMapName := kube.ConfigMapNameFromFunctionName(name)
 	err = lc.kubeClientSet.CoreV1().ConfigMaps(namespace).Delete(configMapName, deleteOptions)
@@ -378,6 +885,268 @@ func (lc *lazyClient) SetPlatformConfigurationProvider(platformConfigurationProv
 	lc.platformConfigurationProvider = platformConfigurationProvider
 }
 
+// SetWatchedNamespace sets the namespace the controller watches functions in (empty for all namespaces)
+func (lc *lazyClient) SetWatchedNamespace(namespace string) {
+	lc.watchedNamespace = namespace
+}
+
+func (lc *lazyClient) StartCaches(stopChannel <-chan struct{}) {
+	lc.startFunctionDefaultsCache(stopChannel)
+	lc.startNodeCache(stopChannel)
+}
+
+// validates the parts of the function spec that are resolved while reconciling
+func (lc *lazyClient) validateFunction(function *nuclioio.NuclioFunction) error {
+	if _, err := lc.resolveMaxRequestBodySize(function); err != nil {
+		return errors.Wrap(err, "Failed to resolve max request body size")
+	}
+
+	if _, err := lc.resolveRegions(function); err != nil {
+		return errors.Wrap(err, "Failed to resolve regions")
+	}
+
+	if function.Spec.ServiceAlias != "" {
+		if errorMessages := validation.IsDNS1035Label(function.Spec.ServiceAlias); len(errorMessages) != 0 {
+			return errors.Errorf("Invalid service alias %s: %s",
+				function.Spec.ServiceAlias,
+				strings.Join(errorMessages, ", "))
+		}
+	}
+
+	if _, err := lc.getAuthenticationAnnotations(function); err != nil {
+		return errors.Wrap(err, "Invalid authentication")
+	}
+
+	if _, err := function.Spec.ScaleToZero.GetScaleDownStabilizationWindow(); err != nil {
+		return errors.Wrap(err, "Invalid scale to zero configuration")
+	}
+
+	if _, err := function.Spec.ScaleToZero.GetScaleEventDeduplicationWindow(); err != nil {
+		return errors.Wrap(err, "Invalid scale to zero configuration")
+	}
+
+	if _, err := lc.getNodePoolTolerations(function); err != nil {
+		return errors.Wrap(err, "Invalid target node pool")
+	}
+
+	if err := lc.validateTLS(function); err != nil {
+		return errors.Wrap(err, "Invalid TLS configuration")
+	}
+
+	if err := lc.validateProgressDeadline(function); err != nil {
+		return errors.Wrap(err, "Invalid progress deadline")
+	}
+
+	if _, err := lc.getServiceTopologyAnnotations(function); err != nil {
+		return errors.Wrap(err, "Invalid service topology")
+	}
+
+	if err := lc.validateMemoryPressureRestart(function); err != nil {
+		return errors.Wrap(err, "Invalid memory pressure restart")
+	}
+
+	if err := lc.validateDerivedLabels(function); err != nil {
+		return errors.Wrap(err, "Invalid derived labels")
+	}
+
+	if err := lc.validateExternalSecrets(function); err != nil {
+		return errors.Wrap(err, "Invalid external secrets")
+	}
+
+	if err := lc.validateTriggerStartOrder(function); err != nil {
+		return errors.Wrap(err, "Invalid trigger start order")
+	}
+
+	if err := lc.validateFallbackImages(function); err != nil {
+		return errors.Wrap(err, "Invalid fallback images")
+	}
+
+	if err := lc.validateImmutableFields(function); err != nil {
+		return errors.Wrap(err, "Invalid function update")
+	}
+
+	if err := lc.validateWarmupRequests(function); err != nil {
+		return errors.Wrap(err, "Invalid warm-up requests")
+	}
+
+	if function.Spec.StableNodePort && function.Spec.GetHTTPPort() != 0 {
+		return errors.New("Stable node port can't be requested along with an explicit HTTP trigger port")
+	}
+
+	switch function.Spec.IngressConflictPolicy {
+	case "", functionconfig.IngressConflictPolicyWarn, functionconfig.IngressConflictPolicyReject:
+	default:
+		return errors.Errorf("Unknown ingress conflict policy: %s", function.Spec.IngressConflictPolicy)
+	}
+
+	if _, err := function.Spec.GetScaleUpMinReadyReplicas(int(function.GetComputedMaxReplicas())); err != nil {
+		return errors.Wrap(err, "Invalid scale up configuration")
+	}
+
+	if _, err := lc.getDebugSidecarContainers(function); err != nil {
+		return errors.Wrap(err, "Invalid debug sidecar")
+	}
+
+	if err := lc.validateRuntimeClass(function); err != nil {
+		return errors.Wrap(err, "Invalid runtime class")
+	}
+
+	if err := lc.validateCapacityTier(function); err != nil {
+		return errors.Wrap(err, "Function doesn't match its capacity tier")
+	}
+
+	if err := lc.validateSchedulingFeasibility(function); err != nil {
+		return errors.Wrap(err, "Function can't be scheduled")
+	}
+
+	return nil
+}
+
+func (lc *lazyClient) validateTriggerStartOrder(function *nuclioio.NuclioFunction) error {
+	orderedTriggerNames := map[string]bool{}
+
+	for _, triggerName := range function.Spec.TriggerStartOrder {
+		trigger, found := function.Spec.Triggers[triggerName]
+		if !found {
+			return errors.Errorf("Trigger %s does not exist", triggerName)
+		}
+
+		if orderedTriggerNames[triggerName] {
+			return errors.Errorf("Trigger %s is listed more than once", triggerName)
+		}
+
+		// these run as k8s cron jobs, outside the function's pods
+		if trigger.Kind == "cron" &&
+			lc.platformConfigurationProvider.GetPlatformConfiguration().CronTriggerCreationMode == platformconfig.KubeCronTriggerCreationMode {
+			return errors.Errorf("Cron trigger %s runs as a cron job and can't be ordered", triggerName)
+		}
+
+		orderedTriggerNames[triggerName] = true
+	}
+
+	return nil
+}
+
+func (lc *lazyClient) validateProgressDeadline(function *nuclioio.NuclioFunction) error {
+	if function.Spec.ProgressDeadlineSeconds < 0 {
+		return errors.Errorf("Progress deadline must not be negative: %d", function.Spec.ProgressDeadlineSeconds)
+	}
+
+	if function.Spec.ProgressDeadlineSeconds == 0 {
+		return nil
+	}
+
+	readinessTimeoutSeconds := function.Spec.ReadinessTimeoutSeconds
+	if readinessTimeoutSeconds == 0 {
+		readinessTimeoutSeconds = abstract.DefaultReadinessTimeoutSeconds
+	}
+
+	// the wait for the function to become ready would time out before the deployment fails, so the failure
+	// would be reported as a plain timeout
+	if function.Spec.ProgressDeadlineSeconds >= readinessTimeoutSeconds {
+		lc.logger.WarnWith("Progress deadline is not less than the readiness timeout",
+			"functionName", function.Name,
+			"progressDeadlineSeconds", function.Spec.ProgressDeadlineSeconds,
+			"readinessTimeoutSeconds", readinessTimeoutSeconds)
+	}
+
+	return nil
+}
+
+func (lc *lazyClient) validateMemoryPressureRestart(function *nuclioio.NuclioFunction) error {
+	if function.Spec.MemoryPressureRestart == nil {
+		return nil
+	}
+
+	if _, err := function.Spec.MemoryPressureRestart.GetUsageThreshold(); err != nil {
+		return errors.Wrap(err, "Invalid usage threshold")
+	}
+
+	if _, err := function.Spec.MemoryPressureRestart.GetSustainedPeriod(); err != nil {
+		return errors.Wrap(err, "Invalid sustained period")
+	}
+
+	// usage is measured against the limit
+	if function.Spec.Resources.Limits.Memory().IsZero() {
+		return errors.New("Memory pressure restart requires a memory limit")
+	}
+
+	return nil
+}
+
+// returns the progress deadline for the function's deployment, or nil to leave the kubernetes default
+func (lc *lazyClient) getProgressDeadlineSeconds(function *nuclioio.NuclioFunction) *int32 {
+	if function.Spec.ProgressDeadlineSeconds <= 0 {
+		return nil
+	}
+
+	progressDeadlineSeconds := int32(function.Spec.ProgressDeadlineSeconds)
+	return &progressDeadlineSeconds
+}
+
+// getAuthenticationAnnotations returns the ingress annotations that enforce the function's authentication (if any),
+// validating it along the way
+func (lc *lazyClient) getAuthenticationAnnotations(function *nuclioio.NuclioFunction) (map[string]string, error) {
+	if function.Spec.Authentication.GetAuthenticationMode() == "" {
+		return nil, nil
+	}
+
+	// authentication set through the http trigger's ingress annotations would be silently overridden
+	for _, httpTrigger := range functionconfig.GetTriggersByKind(function.Spec.Triggers, "http") {
+		for annotation := range httpTrigger.Annotations {
+			if strings.HasPrefix(annotation, nginxIngressAuthAnnotationPrefix) {
+				return nil, errors.Errorf("Authentication can't be set along with the %s trigger annotation",
+					annotation)
+			}
+		}
+	}
+
+	oidc := function.Spec.Authentication.OIDC
+
+	oidcAuthURL := lc.platformConfigurationProvider.GetPlatformConfiguration().IngressConfig.OIDCAuthURL
+	if oidcAuthURL == "" {
+		return nil, errors.New("OIDC authentication requires an OIDC auth URL in the platform configuration")
+	}
+
+	issuerURL, err := url.Parse(oidc.IssuerURL)
+	if err != nil || issuerURL.Scheme != "https" || issuerURL.Host == "" {
+		return nil, errors.Errorf("OIDC issuer URL must be an https URL, got: %s", oidc.IssuerURL)
+	}
+
+	if len(oidc.Audiences) == 0 {
+		return nil, errors.New("OIDC authentication requires at least one audience")
+	}
+
+	// the auth service is told what to verify through the auth URL's query
+	query := url.Values{}
+	query.Set("issuer", oidc.IssuerURL)
+	for _, audience := range oidc.Audiences {
+		if audience == "" {
+			return nil, errors.New("OIDC audiences can't be empty")
+		}
+
+		query.Add("audience", audience)
+	}
+
+	for claimName, claimValue := range oidc.RequiredClaims {
+		if claimName == "" {
+			return nil, errors.New("OIDC required claim names can't be empty")
+		}
+
+		query.Set("claim."+claimName, claimValue)
+	}
+
+	separator := "?"
+	if strings.Contains(oidcAuthURL, "?") {
+		separator = "&"
+	}
+
+	return map[string]string{
+		nginxIngressAuthAnnotationPrefix + "url":              oidcAuthURL + separator + query.Encode(),
+		nginxIngressAuthAnnotationPrefix + "response-headers": "Authorization",
+	}, nil
+}
+
 func (lc *lazyClient) createOrUpdateCronJobs(functionLabels labels.Set,
 	function *nuclioio.NuclioFunction,
 	resources Resources) ([]*batchv1beta1.CronJob, error) {
@@ -637,6 +1406,17 @@ func (lc *lazyClient) createOrUpdateConfigMap(function *nuclioio.NuclioFunction)
 func (lc *lazyClient) createOrUpdateService(functionLabels labels.Set,
 	function *nuclioio.NuclioFunction) (*v1.Service, error) {
 
+	var stableNodePort int
+	if function.Spec.StableNodePort && lc.resolveFunctionServiceType(function) == v1.ServiceTypeNodePort {
+		lc.stableNodePortAllocationLock.Lock()
+		defer lc.stableNodePortAllocationLock.Unlock()
+
+		var err error
+		if stableNodePort, err = lc.getStableNodePort(function); err != nil {
+			return nil, errors.Wrap(err, "Failed to get stable node port")
+		}
+	}
+
 	getService := func() (interface{}, error) {
 		return lc.kubeClientSet.CoreV1().
 			Services(function.Namespace).
@@ -649,13 +1429,23 @@ func (lc *lazyClient) createOrUpdateService(functionLabels labels.Set,
 
 	createService := func() (interface{}, error) {
 		spec := v1.ServiceSpec{}
-		lc.populateServiceSpec(functionLabels, function, &spec)
+		lc.populateServiceSpec(functionLabels, function, stableNodePort, &spec)
+
+		annotations := map[string]string{}
+		if err := lc.populateRegionsAnnotation(function, annotations); err != nil {
+			return nil, errors.Wrap(err, "Failed to populate regions annotation")
+		}
+
+		if err := lc.populateServiceTopologyAnnotations(function, annotations); err != nil {
+			return nil, errors.Wrap(err, "Failed to populate service topology annotations")
+		}
 
 		return lc.kubeClientSet.CoreV1().Services(function.Namespace).Create(&v1.Service{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      kube.ServiceNameFromFunctionName(function.Name),
-				Namespace: function.Namespace,
-				Labels:    functionLabels,
+				Name:        kube.ServiceNameFromFunctionName(function.Name),
+				Namespace:   function.Namespace,
+				Labels:      lc.withDeployGenerationLabel(lc.withDerivedLabels(functionLabels, function), function),
+				Annotations: annotations,
 			},
 			Spec: spec,
 		})
@@ -665,8 +1455,19 @@ func (lc *lazyClient) createOrUpdateService(functionLabels labels.Set,
 		service := resource.(*v1.Service)
 
 		// update existing
-		service.Labels = functionLabels
-		lc.populateServiceSpec(functionLabels, function, &service.Spec)
+		service.Labels = lc.withDeployGenerationLabel(lc.withDerivedLabels(functionLabels, function), function)
+		lc.populateServiceSpec(functionLabels, function, stableNodePort, &service.Spec)
+
+		if service.Annotations == nil {
+			service.Annotations = map[string]string{}
+		}
+		if err := lc.populateRegionsAnnotation(function, service.Annotations); err != nil {
+			return nil, errors.Wrap(err, "Failed to populate regions annotation")
+		}
+
+		if err := lc.populateServiceTopologyAnnotations(function, service.Annotations); err != nil {
+			return nil, errors.Wrap(err, "Failed to populate service topology annotations")
+		}
 
 		return lc.kubeClientSet.CoreV1().Services(function.Namespace).Update(service)
 	}
@@ -706,6 +1507,61 @@ func (lc *lazyClient) createOrUpdateDeployment(functionLabels labels.Set,
 	// get volumes and volumeMounts from configuration
 	volumes, volumeMounts := lc.getFunctionVolumeAndMounts(function)
 
+	tolerations, err := lc.getNodePoolTolerations(function)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get node pool tolerations")
+	}
+
+	// record the tolerations, so that they're told apart from those set by others on update
+	if len(tolerations) > 0 {
+		encodedTolerations, err := json.Marshal(tolerations)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to encode node pool tolerations")
+		}
+
+		deploymentAnnotations[nodePoolTolerationsAnnotation] = string(encodedTolerations)
+	}
+
+	// record the node selector too, as others may select nodes as well
+	capacityTierNodeSelector := lc.getCapacityTierNodeSelector(function)
+	if len(capacityTierNodeSelector) > 0 {
+		encodedCapacityTierNodeSelector, err := json.Marshal(capacityTierNodeSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to encode capacity tier node selector")
+		}
+
+		deploymentAnnotations[capacityTierNodeSelectorAnnotation] = string(encodedCapacityTierNodeSelector)
+	}
+
+	// record the external secrets, so that they're cleaned up once the function no longer has any
+	if len(function.Spec.ExternalSecrets) > 0 {
+		var externalSecretNames []string
+		for _, externalSecret := range function.Spec.ExternalSecrets {
+			externalSecretNames = append(externalSecretNames,
+				kube.ExternalSecretNameFromFunctionName(function.Name, externalSecret.Name))
+		}
+
+		encodedExternalSecretNames, err := json.Marshal(externalSecretNames)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to encode external secret names")
+		}
+
+		deploymentAnnotations[externalSecretsAnnotation] = string(encodedExternalSecretNames)
+	}
+
+	debugSidecarContainers, err := lc.getDebugSidecarContainers(function)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get debug sidecar containers")
+	}
+
+	// debug tools (e.g. profilers) need to see the function's processes
+	var shareProcessNamespace *bool
+	if len(debugSidecarContainers) > 0 {
+		trueVal := true
+		shareProcessNamespace = &trueVal
+		deploymentAnnotations[debugSidecarContainerAnnotation] = debugSidecarContainers[0].Name
+	}
+
 	getDeployment := func() (interface{}, error) {
 		return lc.kubeClientSet.AppsV1().
 			Deployments(function.Namespace).
@@ -726,28 +1582,33 @@ func (lc *lazyClient) createOrUpdateDeployment(functionLabels labels.Set,
 		lc.populateDeploymentContainer(functionLabels, function, &container)
 		container.VolumeMounts = volumeMounts
 
+		resourceLabels := lc.withDerivedLabels(functionLabels, function)
+
 		deploymentSpec := appsv1.DeploymentSpec{
 			Selector: &metav1.LabelSelector{
 				MatchLabels: functionLabels,
 			},
-			Replicas: replicas,
+			Replicas:                replicas,
+			ProgressDeadlineSeconds: lc.getProgressDeadlineSeconds(function),
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:        kube.PodNameFromFunctionName(function.Name),
 					Namespace:   function.Namespace,
-					Labels:      functionLabels,
+					Labels:      resourceLabels,
 					Annotations: podAnnotations,
 				},
 				Spec: v1.PodSpec{
 					ImagePullSecrets: []v1.LocalObjectReference{
 						{Name: imagePullSecrets},
 					},
-					Containers: []v1.Container{
-						container,
-					},
-					Volumes:            volumes,
-					ServiceAccountName: function.Spec.ServiceAccount,
-					SecurityContext:    function.Spec.SecurityContext,
+					Containers:            append([]v1.Container{container}, debugSidecarContainers...),
+					Volumes:               volumes,
+					ServiceAccountName:    function.Spec.ServiceAccount,
+					SecurityContext:       function.Spec.SecurityContext,
+					Tolerations:           tolerations,
+					RuntimeClassName:      lc.getRuntimeClassName(function),
+					ShareProcessNamespace: shareProcessNamespace,
+					NodeSelector:          capacityTierNodeSelector,
 				},
 			},
 		}
@@ -756,7 +1617,7 @@ func (lc *lazyClient) createOrUpdateDeployment(functionLabels labels.Set,
 			ObjectMeta: metav1.ObjectMeta{
 				Name:        kube.DeploymentNameFromFunctionName(function.Name),
 				Namespace:   function.Namespace,
-				Labels:      functionLabels,
+				Labels:      lc.withDeployGenerationLabel(resourceLabels, function),
 				Annotations: deploymentAnnotations,
 			},
 			Spec: deploymentSpec,
@@ -800,13 +1661,46 @@ func (lc *lazyClient) createOrUpdateDeployment(functionLabels labels.Set,
 			}
 		}
 
+		// what was applied previously, if anything, before the annotations are replaced
+		var appliedTolerations []v1.Toleration
+		lc.decodeAppliedDeploymentAnnotation(deployment, nodePoolTolerationsAnnotation, &appliedTolerations)
+		var appliedCapacityTierNodeSelector map[string]string
+		lc.decodeAppliedDeploymentAnnotation(deployment,
+			capacityTierNodeSelectorAnnotation,
+			&appliedCapacityTierNodeSelector)
+		appliedDebugSidecarContainerName := deployment.Annotations[debugSidecarContainerAnnotation]
+
 		deployment.Annotations = deploymentAnnotations
+		deployment.Labels = lc.withDeployGenerationLabel(lc.withDerivedLabels(deployment.Labels, function), function)
+		deployment.Spec.Template.Labels = lc.withDerivedLabels(deployment.Spec.Template.Labels, function)
 		deployment.Spec.Replicas = replicas
+		deployment.Spec.ProgressDeadlineSeconds = lc.getProgressDeadlineSeconds(function)
+
+		// keep the last restart, otherwise updating would roll out the pods once more
+		if restartedAt, restarted := deployment.Spec.Template.Annotations[functionRestartedAtAnnotation]; restarted {
+			podAnnotations[functionRestartedAtAnnotation] = restartedAt
+		}
 		deployment.Spec.Template.Annotations = podAnnotations
 		lc.populateDeploymentContainer(functionLabels, function, &deployment.Spec.Template.Spec.Containers[0])
 		deployment.Spec.Template.Spec.Volumes = volumes
 		deployment.Spec.Template.Spec.Containers[0].VolumeMounts = volumeMounts
 		deployment.Spec.Template.Spec.SecurityContext = function.Spec.SecurityContext
+		deployment.Spec.Template.Spec.Tolerations = getMergedTolerations(deployment.Spec.Template.Spec.Tolerations,
+			appliedTolerations,
+			tolerations)
+		deployment.Spec.Template.Spec.RuntimeClassName = lc.getRuntimeClassName(function)
+		deployment.Spec.Template.Spec.NodeSelector = getMergedNodeSelector(deployment.Spec.Template.Spec.NodeSelector,
+			appliedCapacityTierNodeSelector,
+			capacityTierNodeSelector)
+
+		// attach or detach the debug sidecar, rolling out the pods. containers added by others are left as is
+		deployment.Spec.Template.Spec.Containers = getMergedDebugSidecarContainers(
+			deployment.Spec.Template.Spec.Containers,
+			appliedDebugSidecarContainerName,
+			debugSidecarContainers)
+		if shareProcessNamespace != nil || appliedDebugSidecarContainerName != "" {
+			deployment.Spec.Template.Spec.ShareProcessNamespace = shareProcessNamespace
+		}
 
 		if function.Spec.ServiceAccount != "" {
 			deployment.Spec.Template.Spec.ServiceAccountName = function.Spec.ServiceAccount
@@ -948,10 +1842,14 @@ func (lc *lazyClient) createOrUpdateHorizontalPodAutoscaler(functionLabels label
 		targetCPU = abstract.DefaultTargetCPU
 	}
 
+	// render in the best API version the cluster serves
+	hpas, err := lc.getHPAAccessor()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get HPA accessor")
+	}
+
 	getHorizontalPodAutoscaler := func() (interface{}, error) {
-		return lc.kubeClientSet.AutoscalingV2beta1().
-			HorizontalPodAutoscalers(function.Namespace).
-			Get(kube.HPANameFromFunctionName(function.Name), metav1.GetOptions{})
+		return hpas.Get(function.Namespace, kube.HPANameFromFunctionName(function.Name))
 	}
 
 	horizontalPodAutoscalerIsDeleting := func(resource interface{}) bool {
@@ -972,7 +1870,7 @@ func (lc *lazyClient) createOrUpdateHorizontalPodAutoscaler(functionLabels label
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      kube.HPANameFromFunctionName(function.Name),
 				Namespace: function.Namespace,
-				Labels:    functionLabels,
+				Labels:    lc.withDeployGenerationLabel(lc.withDerivedLabels(functionLabels, function), function),
 			},
 			Spec: autosv2.HorizontalPodAutoscalerSpec{
 				MinReplicas: &minReplicas,
@@ -986,7 +1884,9 @@ func (lc *lazyClient) createOrUpdateHorizontalPodAutoscaler(functionLabels label
 			},
 		}
 
-		return lc.kubeClientSet.AutoscalingV2beta1().HorizontalPodAutoscalers(function.Namespace).Create(&hpa)
+		recordAPIVersion(lc.logger, "hpa", nil, &hpa.ObjectMeta, hpas.apiVersion, hpaAPIVersionAutoscalingV2beta1)
+
+		return hpas.Create(&hpa)
 	}
 
 	updateHorizontalPodAutoscaler := func(resourceToUpdate interface{}) (interface{}, error) {
@@ -998,7 +1898,7 @@ func (lc *lazyClient) createOrUpdateHorizontalPodAutoscaler(functionLabels label
 		}
 
 		hpa.Spec.Metrics = metricSpecs
-		hpa.Labels = functionLabels
+		hpa.Labels = lc.withDeployGenerationLabel(lc.withDerivedLabels(functionLabels, function), function)
 		hpa.Spec.MinReplicas = &minReplicas
 		hpa.Spec.MaxReplicas = maxReplicas
 
@@ -1013,13 +1913,18 @@ func (lc *lazyClient) createOrUpdateHorizontalPodAutoscaler(functionLabels label
 				"functionName", function.Name,
 				"name", hpa.Name)
 
-			err := lc.kubeClientSet.AutoscalingV2beta1().
-				HorizontalPodAutoscalers(function.Namespace).
-				Delete(hpa.Name, deleteOptions)
+			err := hpas.Delete(function.Namespace, hpa.Name, deleteOptions)
 			return nil, err
 		}
 
-		return lc.kubeClientSet.AutoscalingV2beta1().HorizontalPodAutoscalers(function.Namespace).Update(hpa)
+		recordAPIVersion(lc.logger,
+			"hpa",
+			hpa.Annotations,
+			&hpa.ObjectMeta,
+			hpas.apiVersion,
+			hpaAPIVersionAutoscalingV2beta1)
+
+		return hpas.Update(hpa)
 	}
 
 	resource, err := lc.createOrUpdateResource("hpa",
@@ -1039,10 +1944,14 @@ func (lc *lazyClient) createOrUpdateHorizontalPodAutoscaler(functionLabels label
 func (lc *lazyClient) createOrUpdateIngress(functionLabels labels.Set,
 	function *nuclioio.NuclioFunction) (*extv1beta1.Ingress, error) {
 
+	// render in the best API version the cluster serves
+	ingresses, err := lc.getIngressAccessor()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get ingress accessor")
+	}
+
 	getIngress := func() (interface{}, error) {
-		return lc.kubeClientSet.ExtensionsV1beta1().
-			Ingresses(function.Namespace).
-			Get(kube.IngressNameFromFunctionName(function.Name), metav1.GetOptions{})
+		return ingresses.Get(function.Namespace, kube.IngressNameFromFunctionName(function.Name))
 	}
 
 	ingressIsDeleting := func(resource interface{}) bool {
@@ -1053,7 +1962,7 @@ func (lc *lazyClient) createOrUpdateIngress(functionLabels labels.Set,
 		ingressMeta := metav1.ObjectMeta{
 			Name:      kube.IngressNameFromFunctionName(function.Name),
 			Namespace: function.Namespace,
-			Labels:    functionLabels,
+			Labels:    lc.withDeployGenerationLabel(lc.withDerivedLabels(functionLabels, function), function),
 		}
 
 		ingressSpec := extv1beta1.IngressSpec{}
@@ -1067,12 +1976,12 @@ func (lc *lazyClient) createOrUpdateIngress(functionLabels labels.Set,
 			return nil, nil
 		}
 
-		resultIngress, err := lc.kubeClientSet.ExtensionsV1beta1().
-			Ingresses(function.Namespace).
-			Create(&extv1beta1.Ingress{
-				ObjectMeta: ingressMeta,
-				Spec:       ingressSpec,
-			})
+		recordAPIVersion(lc.logger, "ingress", nil, &ingressMeta, ingresses.apiVersion, ingressAPIVersionExtensionsV1beta1)
+
+		resultIngress, err := ingresses.Create(&extv1beta1.Ingress{
+			ObjectMeta: ingressMeta,
+			Spec:       ingressSpec,
+		})
 		if err == nil {
 			lc.waitForNginxIngressToStabilize(resultIngress)
 		}
@@ -1086,6 +1995,11 @@ func (lc *lazyClient) createOrUpdateIngress(functionLabels labels.Set,
 		// save to bool if there are current rules
 		ingressRulesExist := len(ingress.Spec.Rules) > 0
 
+		// populating the ingress config resets its annotations
+		previousAnnotations := ingress.Annotations
+
+		ingress.Labels = lc.withDeployGenerationLabel(lc.withDerivedLabels(ingress.Labels, function), function)
+
 		if err := lc.populateIngressConfig(functionLabels, function, &ingress.ObjectMeta, &ingress.Spec); err != nil {
 			return nil, errors.Wrap(err, "Failed to populate ingress spec")
 		}
@@ -1099,9 +2013,9 @@ func (lc *lazyClient) createOrUpdateIngress(functionLabels labels.Set,
 					PropagationPolicy: &propogationPolicy,
 				}
 
-				err := lc.kubeClientSet.ExtensionsV1beta1().
-					Ingresses(function.Namespace).
-					Delete(kube.IngressNameFromFunctionName(function.Name), deleteOptions)
+				err := ingresses.Delete(function.Namespace,
+					kube.IngressNameFromFunctionName(function.Name),
+					deleteOptions)
 				return nil, err
 
 			}
@@ -1110,7 +2024,14 @@ func (lc *lazyClient) createOrUpdateIngress(functionLabels labels.Set,
 			return nil, nil
 		}
 
-		resultIngress, err := lc.kubeClientSet.ExtensionsV1beta1().Ingresses(function.Namespace).Update(ingress)
+		recordAPIVersion(lc.logger,
+			"ingress",
+			previousAnnotations,
+			&ingress.ObjectMeta,
+			ingresses.apiVersion,
+			ingressAPIVersionExtensionsV1beta1)
+
+		resultIngress, err := ingresses.Update(ingress)
 		if err == nil {
 			lc.waitForNginxIngressToStabilize(ingress)
 		}
@@ -1186,7 +2107,7 @@ func (lc *lazyClient) createOrUpdateCronJob(functionLabels labels.Set,
 	cronJobMeta := metav1.ObjectMeta{
 		Name:      kube.CronJobName(),
 		Namespace: function.Namespace,
-		Labels:    cronJobMetaLabels,
+		Labels:    lc.withDeployGenerationLabel(cronJobMetaLabels, function),
 	}
 
 	// prepare pod template labels
@@ -1196,6 +2117,9 @@ func (lc *lazyClient) createOrUpdateCronJob(functionLabels labels.Set,
 	podTemplateLabels = labels.Merge(podTemplateLabels, functionLabels)
 	cronJobSpec.JobTemplate.Spec.Template.Labels = podTemplateLabels
 
+	// label the jobs as well, so that finished ones can be found for cleanup
+	cronJobSpec.JobTemplate.Labels = podTemplateLabels
+
 	// this new object will be used both on creation/update
 	newCronJob := batchv1beta1.CronJob{
 		ObjectMeta: cronJobMeta,
@@ -1299,6 +2223,20 @@ func (lc *lazyClient) getFunctionLabels(function *nuclioio.NuclioFunction) label
 	return result
 }
 
+// returns the labels of a resource owned by the function, stamped with the function's generation so that the
+// resources of a given deploy can be told apart. not set on pod templates, lest every generation roll out new pods
+func (lc *lazyClient) withDeployGenerationLabel(resourceLabels map[string]string,
+	function *nuclioio.NuclioFunction) map[string]string {
+	result := map[string]string{}
+	for labelKey, labelValue := range resourceLabels {
+		result[labelKey] = labelValue
+	}
+
+	result[deployGenerationLabel] = strconv.FormatInt(function.Generation, 10)
+
+	return result
+}
+
 func (lc *lazyClient) getPodAnnotations(function *nuclioio.NuclioFunction) (map[string]string, error) {
 	annotations := map[string]string{
 		"nuclio.io/image-hash": function.Spec.ImageHash,
@@ -1318,6 +2256,24 @@ func (lc *lazyClient) getPodAnnotations(function *nuclioio.NuclioFunction) (map[
 	return annotations, nil
 }
 
+// decodes a value recorded on the deployment as JSON into the given value, leaving it as is if there's none
+func (lc *lazyClient) decodeAppliedDeploymentAnnotation(deployment *appsv1.Deployment,
+	annotationKey string,
+	value interface{}) {
+
+	encodedValue, found := deployment.Annotations[annotationKey]
+	if !found {
+		return
+	}
+
+	if err := json.Unmarshal([]byte(encodedValue), value); err != nil {
+		lc.logger.WarnWith("Failed to decode applied deployment annotation, ignoring",
+			"deploymentName", deployment.Name,
+			"annotationKey", annotationKey,
+			"err", err)
+	}
+}
+
 func (lc *lazyClient) getDeploymentAnnotations(function *nuclioio.NuclioFunction) (map[string]string, error) {
 	annotations := make(map[string]string)
 
@@ -1345,6 +2301,21 @@ func (lc *lazyClient) getDeploymentAnnotations(function *nuclioio.NuclioFunction
 		annotations[annotationKey] = annotationValue
 	}
 
+	// record the immutable fields' values, against which changes are rejected
+	if len(lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.ImmutableFields) > 0 {
+		immutableFieldValues, err := lc.getImmutableFieldValues(function)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get immutable field values")
+		}
+
+		encodedImmutableFieldValues, err := json.Marshal(immutableFieldValues)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to encode immutable field values")
+		}
+
+		annotations[immutableFieldsAnnotation] = string(encodedImmutableFieldValues)
+	}
+
 	return annotations, nil
 }
 
@@ -1383,6 +2354,7 @@ func (lc *lazyClient) serializeFunctionJSON(function *nuclioio.NuclioFunction) (
 
 func (lc *lazyClient) populateServiceSpec(functionLabels labels.Set,
 	function *nuclioio.NuclioFunction,
+	stableNodePort int,
 	spec *v1.ServiceSpec) {
 
 	if function.Status.State == functionconfig.FunctionStateScaledToZero ||
@@ -1397,6 +2369,16 @@ func (lc *lazyClient) populateServiceSpec(functionLabels labels.Set,
 	spec.Type = lc.resolveFunctionServiceType(function)
 	serviceTypeIsNodePort := spec.Type == v1.ServiceTypeNodePort
 	functionHTTPPort := function.Spec.GetHTTPPort()
+	if stableNodePort != 0 {
+		functionHTTPPort = stableNodePort
+	}
+
+	// a function that no longer requests a stable node port releases the one it was allocated, getting a port
+	// assigned by kubernetes instead
+	releasesStableNodePort := stableNodePort == 0 &&
+		function.Status.StableNodePort != 0 &&
+		len(spec.Ports) != 0 &&
+		int(spec.Ports[0].NodePort) == function.Status.StableNodePort
 
 	// update the service's node port on the following conditions:
 	// 1. this is a new service (spec.Ports is an empty list)
@@ -1405,9 +2387,11 @@ func (lc *lazyClient) populateServiceSpec(functionLabels labels.Set,
 	//    port and then updating it causes node port change
 	// 3. this is an existing service (spec.Ports is not an empty list) and node port was previously configured, but
 	//    the trigger type has been updated to ClusterIP(or any other type which isn't NodePort).
+	// 4. the service holds a stable node port that the function no longer requests
 	if len(spec.Ports) == 0 ||
-		!(spec.Ports[0].NodePort != 0 && function.Spec.GetHTTPPort() == 0) ||
-		(spec.Ports[0].NodePort != 0 && !serviceTypeIsNodePort) {
+		!(spec.Ports[0].NodePort != 0 && functionHTTPPort == 0) ||
+		(spec.Ports[0].NodePort != 0 && !serviceTypeIsNodePort) ||
+		releasesStableNodePort {
 
 		spec.Ports = []v1.ServicePort{
 			{
@@ -1611,6 +2595,18 @@ func (lc *lazyClient) generateCronTriggerCronJobSpec(functionLabels labels.Set,
 	spec.SuccessfulJobsHistoryLimit = &one
 	spec.FailedJobsHistoryLimit = &one
 
+	// keep as many finished jobs as the function retains, deleting them once their TTL passes
+	if function.Spec.JobRetentionCount > 0 {
+		jobRetentionCount := int32(function.Spec.JobRetentionCount)
+		spec.SuccessfulJobsHistoryLimit = &jobRetentionCount
+		spec.FailedJobsHistoryLimit = &jobRetentionCount
+	}
+
+	if function.Spec.JobTTLSecondsAfterFinished > 0 {
+		jobTTLSecondsAfterFinished := int32(function.Spec.JobTTLSecondsAfterFinished)
+		spec.JobTemplate.Spec.TTLSecondsAfterFinished = &jobTTLSecondsAfterFinished
+	}
+
 	return &spec, nil
 }
 
@@ -1654,6 +2650,37 @@ func (lc *lazyClient) populateIngressConfig(functionLabels labels.Set,
 	meta.Annotations["nginx.ingress.kubernetes.io/configuration-snippet"] = fmt.Sprintf(
 		`proxy_set_header X-Nuclio-Target "%s";`, function.Name)
 
+	// limit the request body size at the ingress, unless the http trigger annotations already do
+	maxRequestBodySize, err := lc.resolveMaxRequestBodySize(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve max request body size")
+	}
+
+	if _, annotationExists := meta.Annotations[nginxIngressProxyBodySizeAnnotation]; !annotationExists &&
+		maxRequestBodySize != 0 {
+		meta.Annotations[nginxIngressProxyBodySizeAnnotation] = strconv.FormatInt(maxRequestBodySize, 10)
+	}
+
+	if err := lc.populateRegionsAnnotation(function, meta.Annotations); err != nil {
+		return errors.Wrap(err, "Failed to populate regions annotation")
+	}
+
+	authenticationAnnotations, err := lc.getAuthenticationAnnotations(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get authentication annotations")
+	}
+
+	for annotation, annotationValue := range authenticationAnnotations {
+		meta.Annotations[annotation] = annotationValue
+	}
+
+	switch lc.resolveTLSMode(function) {
+	case functionconfig.TLSModePassthrough:
+		meta.Annotations["nginx.ingress.kubernetes.io/ssl-passthrough"] = "true"
+	case functionconfig.TLSModeReencrypt:
+		meta.Annotations["nginx.ingress.kubernetes.io/backend-protocol"] = "HTTPS"
+	}
+
 	// clear out existing so that we don't keep adding rules
 	spec.Rules = []extv1beta1.IngressRule{}
 	spec.TLS = []extv1beta1.IngressTLS{}
@@ -1759,7 +2786,7 @@ func (lc *lazyClient) populateDeploymentContainer(functionLabels labels.Set,
 	container *v1.Container) {
 	healthCheckHTTPPort := 8082
 
-	container.Image = function.Spec.Image
+	container.Image = lc.getFunctionImage(function)
 	container.Resources = function.Spec.Resources
 	if container.Resources.Requests == nil {
 		container.Resources.Requests = make(v1.ResourceList)
@@ -1835,6 +2862,11 @@ func (lc *lazyClient) populateConfigMap(functionLabels labels.Set,
 	// create configMap contents - generate a processor configuration based on the function CR
 	configMapContents := bytes.Buffer{}
 
+	functionSpec, err := lc.getProcessorFunctionSpec(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get processor function spec")
+	}
+
 	if err := configWriter.Write(&configMapContents, &processor.Configuration{
 		Config: functionconfig.Config{
 			Meta: functionconfig.Meta{
@@ -1843,7 +2875,7 @@ func (lc *lazyClient) populateConfigMap(functionLabels labels.Set,
 				Labels:      functionLabels,
 				Annotations: function.Annotations,
 			},
-			Spec: function.Spec,
+			Spec: *functionSpec,
 		},
 	}); err != nil {
 
@@ -1854,6 +2886,7 @@ func (lc *lazyClient) populateConfigMap(functionLabels labels.Set,
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      kube.ConfigMapNameFromFunctionName(function.Name),
 			Namespace: function.Namespace,
+			Labels:    lc.withDeployGenerationLabel(nil, function),
 		},
 		Data: map[string]string{
 			"processor.yaml": configMapContents.String(),
@@ -1863,6 +2896,82 @@ func (lc *lazyClient) populateConfigMap(functionLabels labels.Set,
 	return nil
 }
 
+// returns a copy of the function spec, enriched with whatever the processor needs to know that isn't
+// explicitly set on the function's triggers
+func (lc *lazyClient) getProcessorFunctionSpec(function *nuclioio.NuclioFunction) (*functionconfig.Spec, error) {
+	functionSpec := function.Spec
+
+	maxRequestBodySize, err := lc.resolveMaxRequestBodySize(function)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve max request body size")
+	}
+
+	functionServesTLS := lc.resolveTLSMode(function) != functionconfig.TLSModeTerminate
+	derivedHTTPWorkers := function.Spec.GetDerivedHTTPWorkers()
+
+	if maxRequestBodySize == 0 && !functionServesTLS && derivedHTTPWorkers == 0 {
+		return &functionSpec, nil
+	}
+
+	// copy the triggers so that the function's own triggers remain untouched
+	functionSpec.Triggers = map[string]functionconfig.Trigger{}
+	for triggerName, trigger := range function.Spec.Triggers {
+		if trigger.Kind == "http" {
+			triggerAttributes := map[string]interface{}{}
+			for attributeName, attributeValue := range trigger.Attributes {
+				triggerAttributes[attributeName] = attributeValue
+			}
+
+			// the runtime limit is enforced by the processor, unless the user set it explicitly
+			if _, maxRequestBodySizeExists := triggerAttributes["maxRequestBodySize"]; !maxRequestBodySizeExists &&
+				maxRequestBodySize != 0 {
+				triggerAttributes["maxRequestBodySize"] = int(maxRequestBodySize)
+			}
+
+			// serve TLS with the mounted TLS secret
+			if functionServesTLS {
+				triggerAttributes["tlsCertFile"] = filepath.Join(functionTLSMountPath, v1.TLSCertKey)
+				triggerAttributes["tlsKeyFile"] = filepath.Join(functionTLSMountPath, v1.TLSPrivateKeyKey)
+			}
+
+			trigger.Attributes = triggerAttributes
+
+			// size the worker pool by the CPU the function gets, unless the user sized it
+			if trigger.MaxWorkers == 0 && derivedHTTPWorkers != 0 {
+				trigger.MaxWorkers = derivedHTTPWorkers
+			}
+		}
+
+		functionSpec.Triggers[triggerName] = trigger
+	}
+
+	return &functionSpec, nil
+}
+
+// returns the max request body size in bytes, falling back to the platform default. 0 means no limit
+func (lc *lazyClient) resolveMaxRequestBodySize(function *nuclioio.NuclioFunction) (int64, error) {
+	maxRequestBodySize := function.Spec.MaxRequestBodySize
+	if maxRequestBodySize == "" {
+		maxRequestBodySize = lc.platformConfigurationProvider.GetPlatformConfiguration().IngressConfig.MaxRequestBodySize
+	}
+
+	if maxRequestBodySize == "" {
+		return 0, nil
+	}
+
+	maxRequestBodySizeQuantity, err := apiresource.ParseQuantity(maxRequestBodySize)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Invalid max request body size: %s", maxRequestBodySize)
+	}
+
+	maxRequestBodySizeBytes, ok := maxRequestBodySizeQuantity.AsInt64()
+	if !ok || maxRequestBodySizeBytes <= 0 {
+		return 0, errors.Errorf("Max request body size must be a positive number of bytes: %s", maxRequestBodySize)
+	}
+
+	return maxRequestBodySizeBytes, nil
+}
+
 func (lc *lazyClient) getFunctionVolumeAndMounts(function *nuclioio.NuclioFunction) ([]v1.Volume, []v1.VolumeMount) {
 	trueVal := true
 	var configVolumes []functionconfig.Volume
@@ -1908,6 +3017,34 @@ func (lc *lazyClient) getFunctionVolumeAndMounts(function *nuclioio.NuclioFuncti
 	configVolumes = append(configVolumes, processorConfigVolume)
 	configVolumes = append(configVolumes, platformConfigVolume)
 
+	// the TLS secret, for functions that serve TLS
+	if lc.resolveTLSMode(function) != functionconfig.TLSModeTerminate && function.Spec.TLSSecret != "" {
+		tlsVolume := functionconfig.Volume{}
+		tlsVolume.Volume.Name = functionTLSVolumeName
+		tlsVolume.Volume.Secret = &v1.SecretVolumeSource{
+			SecretName: function.Spec.TLSSecret,
+		}
+		tlsVolume.VolumeMount.Name = functionTLSVolumeName
+		tlsVolume.VolumeMount.MountPath = functionTLSMountPath
+		tlsVolume.VolumeMount.ReadOnly = true
+
+		configVolumes = append(configVolumes, tlsVolume)
+	}
+
+	// the secrets synced from the external secret store
+	for _, externalSecret := range function.Spec.ExternalSecrets {
+		externalSecretVolume := functionconfig.Volume{}
+		externalSecretVolume.Volume.Name = "external-secret-" + externalSecret.Name
+		externalSecretVolume.Volume.Secret = &v1.SecretVolumeSource{
+			SecretName: kube.ExternalSecretNameFromFunctionName(function.Name, externalSecret.Name),
+		}
+		externalSecretVolume.VolumeMount.Name = externalSecretVolume.Volume.Name
+		externalSecretVolume.VolumeMount.MountPath = externalSecret.MountPath
+		externalSecretVolume.VolumeMount.ReadOnly = true
+
+		configVolumes = append(configVolumes, externalSecretVolume)
+	}
+
 	var volumes []v1.Volume
 	var volumeMounts []v1.VolumeMount
 
@@ -2100,6 +3237,7 @@ type lazyResources struct {
 	deployment              *appsv1.Deployment
 	configMap               *v1.ConfigMap
 	service                 *v1.Service
+	serviceAlias            *v1.Service
 	horizontalPodAutoscaler *autosv2.HorizontalPodAutoscaler
 	ingress                 *extv1beta1.Ingress
 	cronJobs                []*batchv1beta1.CronJob
@@ -2120,6 +3258,11 @@ func (lr *lazyResources) Service() (*v1.Service, error) {
 	return lr.service, nil
 }
 
+// ServiceAlias returns the service through which the function is reachable by its alias
+func (lr *lazyResources) ServiceAlias() (*v1.Service, error) {
+	return lr.serviceAlias, nil
+}
+
 // HorizontalPodAutoscaler returns the hpa
 func (lr *lazyResources) HorizontalPodAutoscaler() (*autosv2.HorizontalPodAutoscaler, error) {
 	return lr.horizontalPodAutoscaler, nil
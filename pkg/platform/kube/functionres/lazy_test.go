@@ -19,22 +19,44 @@ limitations under the License.
 package functionres
 
 import (
+	"context"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nuclio/nuclio/pkg/functionconfig"
 	"github.com/nuclio/nuclio/pkg/platform/abstract"
+	"github.com/nuclio/nuclio/pkg/platform/kube"
 	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
 	"github.com/nuclio/nuclio/pkg/platformconfig"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/nuclio/errors"
 	"github.com/nuclio/logger"
 	"github.com/nuclio/zap"
 	"github.com/stretchr/testify/suite"
 	appsv1 "k8s.io/api/apps/v1"
+	autosv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
 	"k8s.io/api/core/v1"
 	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	nodev1beta1 "k8s.io/api/node/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 type mockedPlatformConfigurationProvider struct {
@@ -62,6 +84,19 @@ func (suite *lazyTestSuite) SetupTest() {
 	// use a fake kube client
 	suite.client.kubeClientSet = fake.NewSimpleClientset()
 
+	// watch all namespaces
+	suite.client.SetWatchedNamespace(metav1.NamespaceAll)
+
+	// list nodes and function defaults off the new kube client
+	suite.client.nodeLister = nil
+	suite.client.functionDefaultsLister = nil
+
+	// look the cluster up through the new kube client
+	suite.client.clusterLookups = newClusterLookupCache(clusterLookupCacheTTL)
+
+	// functions aren't looked up, unless a test says otherwise
+	suite.client.nuclioClientSet = nil
+
 	// use the default platform configuration
 	defaultPlatformConfiguration, err := platformconfig.NewPlatformConfig("")
 	suite.Require().NoError(err)
@@ -384,6 +419,2438 @@ func (suite *lazyTestSuite) TestEnrichDeploymentFromPlatformConfiguration() {
 	suite.Require().NoError(err)
 }
 
+func (suite *lazyTestSuite) TestDerivedHTTPWorkers() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Spec.Triggers = map[string]functionconfig.Trigger{
+		"mh": {
+			Kind: "http",
+		},
+		"stream": {
+			Kind: "kafka-cluster",
+		},
+	}
+
+	// without a CPU request, the processor's default applies
+	functionSpec, err := suite.client.getProcessorFunctionSpec(&functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Zero(functionSpec.Triggers["mh"].MaxWorkers)
+	suite.Require().Zero(functionInstance.Spec.GetHTTPWorkers())
+
+	// a worker per requested CPU, rounded up, injected into the processor's http trigger only
+	functionInstance.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceCPU: resource.MustParse("2500m"),
+	}
+	functionSpec, err = suite.client.getProcessorFunctionSpec(&functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, functionSpec.Triggers["mh"].MaxWorkers)
+	suite.Require().Zero(functionSpec.Triggers["stream"].MaxWorkers)
+	suite.Require().Zero(functionInstance.Spec.Triggers["mh"].MaxWorkers)
+	suite.Require().Equal(3, functionInstance.Spec.GetHTTPWorkers())
+
+	// the user's worker count wins
+	functionInstance.Spec.Triggers["mh"] = functionconfig.Trigger{
+		Kind:       "http",
+		MaxWorkers: 8,
+	}
+	functionSpec, err = suite.client.getProcessorFunctionSpec(&functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(8, functionSpec.Triggers["mh"].MaxWorkers)
+	suite.Require().Equal(8, functionInstance.Spec.GetHTTPWorkers())
+}
+
+func (suite *lazyTestSuite) TestMaxRequestBodySize() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Spec.MaxRequestBodySize = "10Mi"
+	functionInstance.Spec.Triggers = map[string]functionconfig.Trigger{
+		"mh": {
+			Kind:       "http",
+			Attributes: map[string]interface{}{},
+		},
+	}
+
+	// limit is set on the ingress
+	ingressMeta := metav1.ObjectMeta{}
+	err := suite.client.populateIngressConfig(map[string]string{},
+		&functionInstance,
+		&ingressMeta,
+		&extv1beta1.IngressSpec{})
+	suite.Require().NoError(err)
+	suite.Require().Equal("10485760", ingressMeta.Annotations[nginxIngressProxyBodySizeAnnotation])
+
+	// limit is injected into the processor's http trigger, leaving the function untouched
+	functionSpec, err := suite.client.getProcessorFunctionSpec(&functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(10485760, functionSpec.Triggers["mh"].Attributes["maxRequestBodySize"])
+	suite.Require().Empty(functionInstance.Spec.Triggers["mh"].Attributes)
+
+	// invalid values are rejected
+	for _, invalidMaxRequestBodySize := range []string{"abc", "-1Mi", "0"} {
+		functionInstance.Spec.MaxRequestBodySize = invalidMaxRequestBodySize
+		_, err = suite.client.resolveMaxRequestBodySize(&functionInstance)
+		suite.Require().Error(err, invalidMaxRequestBodySize)
+	}
+
+	// falls back to the platform default, or no limit at all
+	functionInstance.Spec.MaxRequestBodySize = ""
+	maxRequestBodySize, err := suite.client.resolveMaxRequestBodySize(&functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Zero(maxRequestBodySize)
+
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().IngressConfig.MaxRequestBodySize = "1k"
+	maxRequestBodySize, err = suite.client.resolveMaxRequestBodySize(&functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(int64(1000), maxRequestBodySize)
+}
+
+func (suite *lazyTestSuite) TestOIDCAuthentication() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+	functionInstance.Spec.Authentication = &functionconfig.Authentication{
+		OIDC: &functionconfig.OIDCAuthentication{
+			IssuerURL: "https://issuer.example.com",
+			Audiences: []string{"api", "web"},
+			RequiredClaims: map[string]string{
+				"group": "admins",
+			},
+		},
+	}
+
+	// no auth service configured
+	_, err := suite.client.getAuthenticationAnnotations(&functionInstance)
+	suite.Require().Error(err)
+
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().IngressConfig.OIDCAuthURL =
+		"http://oidc-verifier.auth.svc/verify"
+
+	ingressMeta := metav1.ObjectMeta{}
+	err = suite.client.populateIngressConfig(map[string]string{},
+		&functionInstance,
+		&ingressMeta,
+		&extv1beta1.IngressSpec{})
+	suite.Require().NoError(err)
+	suite.Require().Equal("http://oidc-verifier.auth.svc/verify?"+
+		"audience=api&audience=web&claim.group=admins&issuer=https%3A%2F%2Fissuer.example.com",
+		ingressMeta.Annotations["nginx.ingress.kubernetes.io/auth-url"])
+
+	// invalid configurations are rejected
+	for _, invalidOIDCAuthentication := range []functionconfig.OIDCAuthentication{
+		{IssuerURL: "http://issuer.example.com", Audiences: []string{"api"}},
+		{IssuerURL: "https://issuer.example.com"},
+		{IssuerURL: "https://issuer.example.com", Audiences: []string{""}},
+	} {
+		invalidOIDCAuthentication := invalidOIDCAuthentication
+		functionInstance.Spec.Authentication.OIDC = &invalidOIDCAuthentication
+		suite.Require().Error(suite.client.validateFunction(&functionInstance), invalidOIDCAuthentication)
+	}
+
+	// auth annotations on the http trigger conflict with the authentication
+	functionInstance.Spec.Authentication.OIDC.Audiences = []string{"api"}
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	functionInstance.Spec.Triggers = map[string]functionconfig.Trigger{
+		"http": {
+			Kind: "http",
+			Annotations: map[string]string{
+				"nginx.ingress.kubernetes.io/auth-type": "basic",
+			},
+		},
+	}
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestTLSMode() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "func-name",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			Triggers: map[string]functionconfig.Trigger{
+				"http": {
+					Kind: "http",
+					Attributes: map[string]interface{}{
+						"ingresses": map[string]interface{}{
+							"i1": map[string]interface{}{
+								"host":  "func.example.com",
+								"paths": []string{"/"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// terminate by default - nothing to mount, processor serves plain http
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+	volumes, _ := suite.client.getFunctionVolumeAndMounts(&functionInstance)
+	for _, volume := range volumes {
+		suite.Require().NotEqual(functionTLSVolumeName, volume.Name)
+	}
+
+	// the secret is required, and must exist with a certificate and key
+	functionInstance.Spec.TLSMode = functionconfig.TLSModePassthrough
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+
+	functionInstance.Spec.TLSSecret = "func-tls"
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+
+	_, err := suite.client.kubeClientSet.CoreV1().Secrets(functionInstance.Namespace).Create(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "func-tls",
+			Namespace: functionInstance.Namespace,
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       []byte("cert"),
+			v1.TLSPrivateKeyKey: []byte("key"),
+		},
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	// the ingress passes connections through, the secret is mounted and the processor serves TLS with it
+	ingressMeta := metav1.ObjectMeta{}
+	err = suite.client.populateIngressConfig(map[string]string{},
+		&functionInstance,
+		&ingressMeta,
+		&extv1beta1.IngressSpec{})
+	suite.Require().NoError(err)
+	suite.Require().Equal("true", ingressMeta.Annotations["nginx.ingress.kubernetes.io/ssl-passthrough"])
+
+	volumes, volumeMounts := suite.client.getFunctionVolumeAndMounts(&functionInstance)
+	tlsVolumeFound := false
+	for _, volume := range volumes {
+		if volume.Name == functionTLSVolumeName {
+			tlsVolumeFound = true
+			suite.Require().Equal("func-tls", volume.Secret.SecretName)
+		}
+	}
+	suite.Require().True(tlsVolumeFound)
+
+	tlsVolumeMountFound := false
+	for _, volumeMount := range volumeMounts {
+		if volumeMount.Name == functionTLSVolumeName {
+			tlsVolumeMountFound = true
+			suite.Require().Equal(functionTLSMountPath, volumeMount.MountPath)
+		}
+	}
+	suite.Require().True(tlsVolumeMountFound)
+
+	functionSpec, err := suite.client.getProcessorFunctionSpec(&functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal("/etc/nuclio/tls/tls.crt", functionSpec.Triggers["http"].Attributes["tlsCertFile"])
+	suite.Require().Equal("/etc/nuclio/tls/tls.key", functionSpec.Triggers["http"].Attributes["tlsKeyFile"])
+
+	// reencrypt has the ingress talk https to the function
+	functionInstance.Spec.TLSMode = functionconfig.TLSModeReencrypt
+	ingressMeta = metav1.ObjectMeta{}
+	err = suite.client.populateIngressConfig(map[string]string{},
+		&functionInstance,
+		&ingressMeta,
+		&extv1beta1.IngressSpec{})
+	suite.Require().NoError(err)
+	suite.Require().Equal("HTTPS", ingressMeta.Annotations["nginx.ingress.kubernetes.io/backend-protocol"])
+
+	// unknown modes are rejected
+	functionInstance.Spec.TLSMode = "mutual"
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestProgressDeadline() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			ProgressDeadlineSeconds: 30,
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	// propagated to the deployment
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+	deploymentInstance, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(int32(30), *deploymentInstance.Spec.ProgressDeadlineSeconds)
+
+	// a rollout that exceeded the deadline fails the wait, without waiting out the timeout
+	deploymentInstance.Status.Conditions = []appsv1.DeploymentCondition{
+		{
+			Type:    appsv1.DeploymentProgressing,
+			Status:  v1.ConditionFalse,
+			Reason:  deploymentProgressDeadlineExceededReason,
+			Message: `ReplicaSet "my-function-abc" has timed out progressing.`,
+		},
+	}
+	_, err = suite.client.kubeClientSet.AppsV1().Deployments(functionInstance.Namespace).Update(deploymentInstance)
+	suite.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = suite.client.waitDeploymentAvailable(ctx, &functionInstance, &WaitAvailableResult{})
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "progress deadline")
+	suite.Require().NoError(ctx.Err())
+
+	// unsetting leaves the kubernetes default
+	functionInstance.Spec.ProgressDeadlineSeconds = 0
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Nil(deploymentInstance.Spec.ProgressDeadlineSeconds)
+
+	// negative deadlines are rejected
+	functionInstance.Spec.ProgressDeadlineSeconds = -1
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestServiceTopology() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			ServiceTopology: functionconfig.ServiceTopologyPreferSameZone,
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	fakeDiscovery := suite.client.kubeClientSet.Discovery().(*fakediscovery.FakeDiscovery)
+
+	// clusters that can't prefer same-zone endpoints are rejected
+	fakeDiscovery.FakedServerVersion = &version.Info{GitVersion: "v1.21.14"}
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+
+	// topology aware hints
+	fakeDiscovery.FakedServerVersion = &version.Info{GitVersion: "v1.25.3-gke.100"}
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+	serviceInstance, err := suite.client.createOrUpdateService(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal("auto", serviceInstance.Annotations[serviceTopologyAwareHintsAnnotation])
+
+	// topology mode replaces the hints once the cluster supports it
+	fakeDiscovery.FakedServerVersion = &version.Info{GitVersion: "v1.29.0"}
+	serviceInstance, err = suite.client.createOrUpdateService(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(map[string]string{serviceTopologyModeAnnotation: "Auto"}, serviceInstance.Annotations)
+
+	// unsetting removes the preference
+	functionInstance.Spec.ServiceTopology = ""
+	serviceInstance, err = suite.client.createOrUpdateService(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Empty(serviceInstance.Annotations)
+
+	// unknown topologies are rejected
+	functionInstance.Spec.ServiceTopology = "preferSameNode"
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestRestart() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	_, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+
+	// restarting changes the pod template
+	err = suite.client.Restart(context.Background(), &functionInstance)
+	suite.Require().NoError(err)
+
+	deploymentInstance, err := suite.client.kubeClientSet.AppsV1().
+		Deployments(functionInstance.Namespace).
+		Get(kube.DeploymentNameFromFunctionName(functionInstance.Name), metav1.GetOptions{})
+	suite.Require().NoError(err)
+	restartedAt := deploymentInstance.Spec.Template.Annotations[functionRestartedAtAnnotation]
+	suite.Require().NotEmpty(restartedAt)
+
+	// updating the function keeps the restart, rather than rolling out the pods again
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(restartedAt, deploymentInstance.Spec.Template.Annotations[functionRestartedAtAnnotation])
+
+	// memory pressure restarts require a memory limit to measure usage against
+	functionInstance.Spec.MemoryPressureRestart = &functionconfig.MemoryPressureRestartSpec{}
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+
+	functionInstance.Spec.Resources.Limits = v1.ResourceList{
+		v1.ResourceMemory: resource.MustParse("256Mi"),
+	}
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	functionInstance.Spec.MemoryPressureRestart.UsageThreshold = 1.5
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestDerivedLabels() {
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.DerivedLabels = []platformconfig.DerivedLabel{
+		{Name: "nuclio.io/runtime", Template: `{{ .Runtime | cut ":" }}`},
+		{Name: "nuclio.io/min-replicas-tier", Template: `{{ if .MinReplicas }}{{ if ge (deref .MinReplicas) 3 }}high{{ else }}low{{ end }}{{ end }}`},
+	}
+
+	minReplicas := 3
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			Runtime:     "python:3.7",
+			MinReplicas: &minReplicas,
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	// derived labels are set on the resources and their pods, but not on selectors
+	deploymentInstance, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal("python", deploymentInstance.Labels["nuclio.io/runtime"])
+	suite.Require().Equal("high", deploymentInstance.Spec.Template.Labels["nuclio.io/min-replicas-tier"])
+	suite.Require().NotContains(deploymentInstance.Spec.Selector.MatchLabels, "nuclio.io/runtime")
+
+	serviceInstance, err := suite.client.createOrUpdateService(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal("python", serviceInstance.Labels["nuclio.io/runtime"])
+	suite.Require().NotContains(serviceInstance.Spec.Selector, "nuclio.io/runtime")
+
+	// updated along with the spec, and removed once they render empty
+	functionInstance.Spec.Runtime = "golang"
+	functionInstance.Spec.MinReplicas = nil
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal("golang", deploymentInstance.Spec.Template.Labels["nuclio.io/runtime"])
+	suite.Require().NotContains(deploymentInstance.Spec.Template.Labels, "nuclio.io/min-replicas-tier")
+
+	// the function's own labels win
+	functionInstance.Labels = map[string]string{"nuclio.io/runtime": "custom"}
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+	suite.Require().Equal("custom", suite.client.withDerivedLabels(functionInstance.Labels, &functionInstance)["nuclio.io/runtime"])
+
+	// values that aren't valid label values are rejected
+	functionInstance.Labels = nil
+	functionInstance.Spec.Runtime = "python 3"
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestTargetNodePool() {
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.NodePools = map[string]platformconfig.NodePool{
+		"gpu": {
+			Taints: []v1.Taint{
+				{Key: "nvidia.com/gpu", Effect: v1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "ml", Effect: v1.TaintEffectNoExecute},
+			},
+		},
+	}
+
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			TargetNodePool: "gpu",
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	deploymentInstance, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]v1.Toleration{
+		{Key: "nvidia.com/gpu", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+		{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "ml", Effect: v1.TaintEffectNoExecute},
+	}, deploymentInstance.Spec.Template.Spec.Tolerations)
+
+	// tolerations set by others (e.g. a webhook) are kept across reconciles
+	foreignToleration := v1.Toleration{Key: "spot", Operator: v1.TolerationOpExists}
+	deploymentInstance.Spec.Template.Spec.Tolerations = append(deploymentInstance.Spec.Template.Spec.Tolerations,
+		foreignToleration)
+	_, err = suite.client.kubeClientSet.AppsV1().Deployments(functionInstance.Namespace).Update(deploymentInstance)
+	suite.Require().NoError(err)
+
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Len(deploymentInstance.Spec.Template.Spec.Tolerations, 3)
+	suite.Require().Contains(deploymentInstance.Spec.Template.Spec.Tolerations, foreignToleration)
+
+	// untargeting the pool removes its tolerations only
+	functionInstance.Spec.TargetNodePool = ""
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]v1.Toleration{foreignToleration}, deploymentInstance.Spec.Template.Spec.Tolerations)
+
+	// unknown pools are rejected
+	functionInstance.Spec.TargetNodePool = "unknown"
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestSchedulingFeasibility() {
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.NodePools = map[string]platformconfig.NodePool{
+		"small": {
+			Taints: []v1.Taint{
+				{Key: "dedicated", Value: "small", Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+	}
+
+	for _, node := range []*v1.Node{
+		suite.createNode("large-node", "8", "32Gi", nil),
+		suite.createNode("small-node", "2", "4Gi", []v1.Taint{
+			{Key: "dedicated", Value: "small", Effect: v1.TaintEffectNoSchedule},
+		}),
+	} {
+		_, err := suite.client.kubeClientSet.CoreV1().Nodes().Create(node)
+		suite.Require().NoError(err)
+	}
+
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("4"),
+					v1.ResourceMemory: resource.MustParse("128Gi"),
+				},
+			},
+		},
+	}
+
+	// the check is skipped until the node cache syncs, without waiting for it
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	stopChannel := suite.startNodeCache()
+	defer close(stopChannel)
+
+	functionInstance.Spec.Resources.Requests[v1.ResourceMemory] = resource.MustParse("8Gi")
+
+	// fits on the large node
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	// but not on the nodes of the pool it targets
+	functionInstance.Spec.TargetNodePool = "small"
+	err := suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(ErrSchedulingInfeasible, errors.RootCause(err))
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "pod requires 4 cpu but largest node has 2")
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "pod requires 8Gi memory but largest node has 4Gi")
+
+	// nor on any node
+	functionInstance.Spec.TargetNodePool = ""
+	functionInstance.Spec.Resources.Requests[v1.ResourceMemory] = resource.MustParse("64Gi")
+	err = suite.client.CheckSchedulingFeasibility(context.Background(), &functionInstance)
+	suite.Require().Equal(ErrSchedulingInfeasible, errors.RootCause(err))
+
+	// limited extended resources are requested as much
+	functionInstance.Spec.Resources.Requests[v1.ResourceMemory] = resource.MustParse("8Gi")
+	functionInstance.Spec.Resources.Limits = v1.ResourceList{
+		"nvidia.com/gpu": resource.MustParse("1"),
+	}
+	err = suite.client.CheckSchedulingFeasibility(context.Background(), &functionInstance)
+	suite.Require().Equal(ErrSchedulingInfeasible, errors.RootCause(err))
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "no node provides it")
+
+	// nodes are listed once, and watched from then on
+	nodeLists := 0
+	for _, action := range suite.client.kubeClientSet.(*fake.Clientset).Actions() {
+		if action.Matches("list", "nodes") {
+			nodeLists++
+		}
+	}
+	suite.Require().Equal(1, nodeLists)
+}
+
+func (suite *lazyTestSuite) TestSchedulingFeasibilityPodPlacement() {
+	for _, node := range []*v1.Node{
+		suite.createNode("gpu-node", "16", "64Gi", []v1.Taint{
+			{Key: "gpu", Value: "true", Effect: v1.TaintEffectNoSchedule},
+		}),
+		suite.createNode("medium-node", "8", "32Gi", nil),
+		suite.createNode("small-node", "2", "4Gi", nil),
+	} {
+		node.Labels = map[string]string{"zone": map[string]string{
+			"gpu-node":    "",
+			"medium-node": "a",
+			"small-node":  "b",
+		}[node.Name]}
+		_, err := suite.client.kubeClientSet.CoreV1().Nodes().Create(node)
+		suite.Require().NoError(err)
+	}
+
+	stopChannel := suite.startNodeCache()
+	defer close(stopChannel)
+
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("12"),
+				},
+			},
+		},
+	}
+
+	// the function's pods are placed by what others set on its pod template
+	deploymentInstance, err := suite.client.kubeClientSet.AppsV1().
+		Deployments(functionInstance.Namespace).
+		Create(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      kube.DeploymentNameFromFunctionName(functionInstance.Name),
+				Namespace: functionInstance.Namespace,
+			},
+		})
+	suite.Require().NoError(err)
+
+	updatePodSpec := func(podSpec v1.PodSpec) {
+		deploymentInstance.Spec.Template.Spec = podSpec
+		deploymentInstance, err = suite.client.kubeClientSet.AppsV1().
+			Deployments(functionInstance.Namespace).
+			Update(deploymentInstance)
+		suite.Require().NoError(err)
+	}
+
+	// nodes tainted with what the pods don't tolerate don't count
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Equal(ErrSchedulingInfeasible, errors.RootCause(err))
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "pod requires 12 cpu but largest node has 8")
+
+	// unless tolerated
+	gpuTolerations := []v1.Toleration{
+		{Key: "gpu", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+	}
+	updatePodSpec(v1.PodSpec{Tolerations: gpuTolerations})
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	// nodes the pods' node selector doesn't select don't count
+	functionInstance.Spec.Resources.Requests[v1.ResourceCPU] = resource.MustParse("4")
+	updatePodSpec(v1.PodSpec{
+		Tolerations:  gpuTolerations,
+		NodeSelector: map[string]string{"zone": "b"},
+	})
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Equal(ErrSchedulingInfeasible, errors.RootCause(err))
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "pod requires 4 cpu but largest node has 2")
+
+	// nor do those the pods' required node affinity doesn't match. any of its terms may match
+	requiredNodeAffinity := &v1.NodeSelector{
+		NodeSelectorTerms: []v1.NodeSelectorTerm{
+			{
+				MatchExpressions: []v1.NodeSelectorRequirement{
+					{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"b"}},
+				},
+			},
+		},
+	}
+	updatePodSpec(v1.PodSpec{
+		Affinity: &v1.Affinity{
+			NodeAffinity: &v1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: requiredNodeAffinity},
+		},
+	})
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Equal(ErrSchedulingInfeasible, errors.RootCause(err))
+
+	requiredNodeAffinity.NodeSelectorTerms = append(requiredNodeAffinity.NodeSelectorTerms, v1.NodeSelectorTerm{
+		MatchFields: []v1.NodeSelectorRequirement{
+			{Key: "metadata.name", Operator: v1.NodeSelectorOpIn, Values: []string{"medium-node"}},
+		},
+	})
+	updatePodSpec(v1.PodSpec{
+		Affinity: &v1.Affinity{
+			NodeAffinity: &v1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: requiredNodeAffinity},
+		},
+	})
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestCapacityTiers() {
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.CapacityTiers =
+		map[string]platformconfig.CapacityTier{
+			"small": {
+				NodeSelector: map[string]string{"nuclio.io/capacity-tier": "small"},
+				MaxRequests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("2"),
+				},
+			},
+			"large": {
+				NodeSelector: map[string]string{"nuclio.io/capacity-tier": "large"},
+			},
+		}
+
+	for _, node := range []*v1.Node{
+		suite.createNode("small-node", "4", "4Gi", nil),
+		suite.createNode("large-node", "16", "64Gi", nil),
+	} {
+		node.Labels = map[string]string{"nuclio.io/capacity-tier": strings.TrimSuffix(node.Name, "-node")}
+		_, err := suite.client.kubeClientSet.CoreV1().Nodes().Create(node)
+		suite.Require().NoError(err)
+	}
+
+	stopChannel := suite.startNodeCache()
+	defer close(stopChannel)
+
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			CapacityTier: "small",
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("1"),
+					v1.ResourceMemory: resource.MustParse("2Gi"),
+				},
+			},
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	// compatible requests are scheduled on the tier's nodes
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+	deploymentInstance, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(map[string]string{"nuclio.io/capacity-tier": "small"},
+		deploymentInstance.Spec.Template.Spec.NodeSelector)
+
+	// requests beyond what the tier allows are rejected, even if the tier's nodes could fit them
+	functionInstance.Spec.Resources.Requests[v1.ResourceCPU] = resource.MustParse("3")
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10),
+		"pod requires 3 cpu but capacity tier small allows at most 2")
+
+	// as are requests that only nodes of other tiers can satisfy
+	functionInstance.Spec.Resources.Requests[v1.ResourceCPU] = resource.MustParse("1")
+	functionInstance.Spec.Resources.Requests[v1.ResourceMemory] = resource.MustParse("16Gi")
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(ErrSchedulingInfeasible, errors.RootCause(err))
+	suite.Require().Contains(errors.GetErrorStackString(err, 10),
+		"pod requires 16Gi memory but largest node has 4Gi")
+
+	// a larger tier fits them
+	functionInstance.Spec.CapacityTier = "large"
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	// moving between tiers keeps the labels selected by others (e.g. a webhook)
+	deploymentInstance.Spec.Template.Spec.NodeSelector["kubernetes.io/os"] = "linux"
+	_, err = suite.client.kubeClientSet.AppsV1().Deployments(functionInstance.Namespace).Update(deploymentInstance)
+	suite.Require().NoError(err)
+
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(map[string]string{
+		"nuclio.io/capacity-tier": "large",
+		"kubernetes.io/os":        "linux",
+	}, deploymentInstance.Spec.Template.Spec.NodeSelector)
+
+	functionInstance.Spec.CapacityTier = ""
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(map[string]string{"kubernetes.io/os": "linux"},
+		deploymentInstance.Spec.Template.Spec.NodeSelector)
+
+	functionInstance.Spec.CapacityTier = "huge"
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "Unknown capacity tier: huge")
+}
+
+func (suite *lazyTestSuite) TestExternalSecrets() {
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.ExternalSecrets =
+		platformconfig.ExternalSecrets{
+			SecretStore: "vault",
+		}
+
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			ExternalSecrets: []functionconfig.ExternalSecretReference{
+				{Name: "db", RemoteKey: "prod/db", MountPath: "/etc/secrets/db"},
+			},
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	// rejected while the external secrets operator isn't installed
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+
+	suite.client.dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	fakeDiscovery := suite.client.kubeClientSet.Discovery().(*fakediscovery.FakeDiscovery)
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: externalSecretAPIVersion,
+			APIResources: []metav1.APIResource{
+				{Name: "externalsecrets", Kind: externalSecretKind, Namespaced: true},
+			},
+		},
+	}
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	// an external secret is created per reference, syncing from the configured store
+	err := suite.client.createOrUpdateExternalSecrets(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+
+	externalSecretsClient := suite.client.dynamicClient.
+		Resource(externalSecretResource).
+		Namespace(functionInstance.Namespace)
+	externalSecret, err := externalSecretsClient.Get("nuclio-my-function-db", metav1.GetOptions{})
+	suite.Require().NoError(err)
+
+	secretStoreName, _, _ := unstructured.NestedString(externalSecret.Object, "spec", "secretStoreRef", "name")
+	secretStoreKind, _, _ := unstructured.NestedString(externalSecret.Object, "spec", "secretStoreRef", "kind")
+	targetName, _, _ := unstructured.NestedString(externalSecret.Object, "spec", "target", "name")
+	suite.Require().Equal("vault", secretStoreName)
+	suite.Require().Equal("ClusterSecretStore", secretStoreKind)
+	suite.Require().Equal("nuclio-my-function-db", targetName)
+
+	// and mounted into the function's pods
+	volumes, volumeMounts := suite.client.getFunctionVolumeAndMounts(&functionInstance)
+	suite.Require().Contains(volumes, v1.Volume{
+		Name: "external-secret-db",
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: "nuclio-my-function-db"},
+		},
+	})
+	suite.Require().Contains(volumeMounts, v1.VolumeMount{
+		Name:      "external-secret-db",
+		MountPath: "/etc/secrets/db",
+		ReadOnly:  true,
+	})
+
+	// the sync status is reported per the operator's Ready condition
+	suite.Require().Equal([]functionconfig.ExternalSecretStatus{
+		{Name: "db", Message: "Not synced yet"},
+	}, suite.client.getExternalSecretStatuses(&functionInstance))
+
+	err = unstructured.SetNestedSlice(externalSecret.Object, []interface{}{
+		map[string]interface{}{
+			"type":    "Ready",
+			"status":  "True",
+			"message": "Secret was synced",
+		},
+	}, "status", "conditions")
+	suite.Require().NoError(err)
+	_, err = externalSecretsClient.Update(externalSecret, metav1.UpdateOptions{})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]functionconfig.ExternalSecretStatus{
+		{Name: "db", Synced: true, Message: "Secret was synced"},
+	}, suite.client.getExternalSecretStatuses(&functionInstance))
+
+	// the deployment records that the function has external secrets
+	deploymentInstance, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(`["nuclio-my-function-db"]`, deploymentInstance.Annotations[externalSecretsAnnotation])
+
+	// so external secrets the function no longer references are deleted
+	functionInstance.Spec.ExternalSecrets = nil
+	err = suite.client.createOrUpdateExternalSecrets(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+
+	externalSecrets, err := externalSecretsClient.List(metav1.ListOptions{})
+	suite.Require().NoError(err)
+	suite.Require().Empty(externalSecrets.Items)
+
+	// once the deployment no longer records any, they're no longer listed
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().NotContains(deploymentInstance.Annotations, externalSecretsAnnotation)
+
+	suite.client.dynamicClient.(*dynamicfake.FakeDynamicClient).ClearActions()
+	err = suite.client.createOrUpdateExternalSecrets(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Empty(suite.client.dynamicClient.(*dynamicfake.FakeDynamicClient).Actions())
+
+	// invalid references are rejected
+	functionInstance.Spec.ExternalSecrets = []functionconfig.ExternalSecretReference{
+		{Name: "db", MountPath: "/etc/secrets/db"},
+	}
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+
+	functionInstance.Spec.ExternalSecrets = []functionconfig.ExternalSecretReference{
+		{Name: "db", RemoteKey: "prod/db", MountPath: "/etc/secrets"},
+		{Name: "cache", RemoteKey: "prod/cache", MountPath: "/etc/secrets"},
+	}
+	suite.Require().Error(suite.client.validateFunction(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestRegions() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "func-name"
+
+	// no regions configured anywhere - no annotation
+	annotations := map[string]string{}
+	err := suite.client.populateRegionsAnnotation(&functionInstance, annotations)
+	suite.Require().NoError(err)
+	suite.Require().Empty(annotations)
+
+	// function regions are sorted and deduplicated
+	functionInstance.Spec.Regions = []string{"us-east-1", "eu-west-1", "us-east-1"}
+	err = suite.client.populateRegionsAnnotation(&functionInstance, annotations)
+	suite.Require().NoError(err)
+	suite.Require().Equal("eu-west-1,us-east-1", annotations[platformconfig.DefaultRegionsAnnotation])
+
+	// regions must be allowed
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().IngressConfig.AllowedRegions = []string{
+		"eu-west-1",
+		"us-west-2",
+	}
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+
+	// no function regions - served from all allowed regions
+	functionInstance.Spec.Regions = nil
+	err = suite.client.populateRegionsAnnotation(&functionInstance, annotations)
+	suite.Require().NoError(err)
+	suite.Require().Equal("eu-west-1,us-west-2", annotations[platformconfig.DefaultRegionsAnnotation])
+
+	// invalid region identifier
+	functionInstance.Spec.Regions = []string{"Not A Region"}
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+}
+
+func (suite *lazyTestSuite) TestProvisioningPhases() {
+	createdTime := time.Now().Add(-time.Minute)
+	podMeta := metav1.ObjectMeta{
+		Name:              "my-function-pod",
+		Namespace:         "test-namespace",
+		CreationTimestamp: metav1.NewTime(createdTime),
+		Labels: map[string]string{
+			"nuclio.io/function-name": "my-function",
+		},
+	}
+
+	// a pod that is scheduled but whose container hasn't started yet
+	_, err := suite.client.kubeClientSet.CoreV1().Pods("test-namespace").Create(&v1.Pod{
+		ObjectMeta: podMeta,
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{
+				{
+					Type:               v1.PodScheduled,
+					Status:             v1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(createdTime.Add(10 * time.Second)),
+				},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+
+	phases := suite.client.getProvisioningPhases("test-namespace", "my-function")
+	suite.Require().Len(phases, 2)
+	suite.Require().Equal(functionconfig.ProvisioningPhase{
+		Name:      functionconfig.ProvisioningPhaseScheduling,
+		Duration:  "10s",
+		Completed: true,
+	}, phases[0])
+	suite.Require().Equal(functionconfig.ProvisioningPhaseImagePull, phases[1].Name)
+	suite.Require().False(phases[1].Completed)
+
+	// the pod became ready
+	_, err = suite.client.kubeClientSet.CoreV1().Pods("test-namespace").Update(&v1.Pod{
+		ObjectMeta: podMeta,
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{
+				{
+					Type:               v1.PodScheduled,
+					Status:             v1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(createdTime.Add(10 * time.Second)),
+				},
+				{
+					Type:               v1.PodReady,
+					Status:             v1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(createdTime.Add(40 * time.Second)),
+				},
+			},
+			ContainerStatuses: []v1.ContainerStatus{
+				{
+					State: v1.ContainerState{
+						Running: &v1.ContainerStateRunning{
+							StartedAt: metav1.NewTime(createdTime.Add(30 * time.Second)),
+						},
+					},
+				},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+
+	// with no pull events, the image pull is accounted for up until the container started
+	phases = suite.client.getProvisioningPhases("test-namespace", "my-function")
+	suite.Require().Equal([]functionconfig.ProvisioningPhase{
+		{Name: functionconfig.ProvisioningPhaseScheduling, Duration: "10s", Completed: true},
+		{Name: functionconfig.ProvisioningPhaseImagePull, Duration: "20s", Completed: true},
+		{Name: functionconfig.ProvisioningPhaseContainerStart, Duration: "0s", Completed: true},
+		{Name: functionconfig.ProvisioningPhaseReadiness, Duration: "10s", Completed: true},
+	}, phases)
+}
+
+func (suite *lazyTestSuite) TestProvisioningPhasesOnlyWhileProvisioning() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Namespace = "test-namespace"
+
+	_, err := suite.client.kubeClientSet.AppsV1().Deployments(functionInstance.Namespace).Create(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kube.DeploymentNameFromFunctionName(functionInstance.Name),
+			Namespace: functionInstance.Namespace,
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          1,
+			AvailableReplicas: 1,
+			Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentAvailable, Status: v1.ConditionTrue},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+
+	_, err = suite.client.kubeClientSet.CoreV1().Pods(functionInstance.Namespace).Create(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function-pod",
+			Namespace: functionInstance.Namespace,
+			Labels: map[string]string{
+				"nuclio.io/function-name": functionInstance.Name,
+			},
+		},
+	})
+	suite.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// a function being deployed has its provisioning broken down
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+	result, err := suite.client.WaitAvailable(ctx, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(result.ProvisioningPhases)
+
+	// resyncs of a ready function whose deployment is available don't
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+	result, err = suite.client.WaitAvailable(ctx, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Empty(result.ProvisioningPhases)
+}
+
+func (suite *lazyTestSuite) TestRetryFailedImagePulls() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Namespace = "test-namespace"
+	functionInstance.Spec.ImagePullRetries = 1
+
+	createPodFailingToPull := func(name string, podTemplateHash string, failingFor time.Duration) {
+		_, err := suite.client.kubeClientSet.CoreV1().Pods(functionInstance.Namespace).Create(&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         functionInstance.Namespace,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-failingFor)),
+				Labels: map[string]string{
+					"nuclio.io/function-name":               functionInstance.Name,
+					appsv1.DefaultDeploymentUniqueLabelKey: podTemplateHash,
+				},
+			},
+			Status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{
+					{
+						State: v1.ContainerState{
+							Waiting: &v1.ContainerStateWaiting{
+								Reason:  "ImagePullBackOff",
+								Message: "Back-off pulling image",
+							},
+						},
+					},
+				},
+			},
+		})
+		suite.Require().NoError(err)
+	}
+
+	// pods that only just failed pulling are given the default pull timeout
+	result := WaitAvailableResult{}
+	createPodFailingToPull("first-pod", "first-template", 10*time.Second)
+	err := suite.client.retryFailedImagePulls(&functionInstance, &result)
+	suite.Require().NoError(err)
+	suite.Require().Zero(result.ImagePullAttempts)
+
+	pods, err := suite.client.getFunctionPods(functionInstance.Namespace, functionInstance.Name)
+	suite.Require().NoError(err)
+	suite.Require().Len(pods, 1)
+
+	// past it, the attempt is counted and the pod is deleted to force a fresh pull
+	err = suite.client.kubeClientSet.CoreV1().Pods(functionInstance.Namespace).Delete("first-pod", nil)
+	suite.Require().NoError(err)
+	createPodFailingToPull("second-pod", "first-template", 5*time.Minute)
+	err = suite.client.retryFailedImagePulls(&functionInstance, &result)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1, result.ImagePullAttempts)
+	suite.Require().Equal("first-template", result.ImagePullPodTemplateHash)
+
+	pods, err = suite.client.getFunctionPods(functionInstance.Namespace, functionInstance.Name)
+	suite.Require().NoError(err)
+	suite.Require().Empty(pods)
+
+	// a rollout of another pod template is given retries of its own
+	createPodFailingToPull("third-pod", "second-template", 5*time.Minute)
+	err = suite.client.retryFailedImagePulls(&functionInstance, &result)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1, result.ImagePullAttempts)
+	suite.Require().Equal("second-template", result.ImagePullPodTemplateHash)
+
+	// its replacement fails as well, on a later wait (seeded off the function's status) - out of retries
+	result = WaitAvailableResult{
+		ImagePullAttempts:        result.ImagePullAttempts,
+		ImagePullPodTemplateHash: result.ImagePullPodTemplateHash,
+	}
+	createPodFailingToPull("fourth-pod", "second-template", 5*time.Minute)
+	err = suite.client.retryFailedImagePulls(&functionInstance, &result)
+	suite.Require().Error(err)
+	suite.Require().Equal(2, result.ImagePullAttempts)
+}
+
+func (suite *lazyTestSuite) TestFailOverImage() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Namespace = "test-namespace"
+	functionInstance.Spec.Image = "primary.io/my-function:latest"
+	functionInstance.Spec.FallbackImages = []string{"mirror.io/my-function:latest"}
+
+	suite.Require().NoError(suite.client.validateFallbackImages(&functionInstance))
+
+	deployment, err := suite.client.kubeClientSet.AppsV1().Deployments(functionInstance.Namespace).Create(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kube.DeploymentNameFromFunctionName(functionInstance.Name),
+			Namespace: functionInstance.Namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "nuclio", Image: functionInstance.Spec.Image},
+					},
+				},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+
+	createPodBackingOffPull := func(name string, image string) {
+		_, err := suite.client.kubeClientSet.CoreV1().Pods(functionInstance.Namespace).Create(&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: functionInstance.Namespace,
+				Labels: map[string]string{
+					"nuclio.io/function-name": functionInstance.Name,
+				},
+			},
+			Status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{
+					{
+						Name:  "nuclio",
+						Image: image,
+						State: v1.ContainerState{
+							Waiting: &v1.ContainerStateWaiting{
+								Reason:  "ImagePullBackOff",
+								Message: "Back-off pulling image",
+							},
+						},
+					},
+				},
+			},
+		})
+		suite.Require().NoError(err)
+	}
+
+	// the primary image can't be pulled - fail over to the mirror
+	result := WaitAvailableResult{}
+	createPodBackingOffPull("first-pod", functionInstance.Spec.Image)
+	err = suite.client.failOverImage(&functionInstance, deployment, &result)
+	suite.Require().NoError(err)
+	suite.Require().True(result.ImageFailedOver)
+	suite.Require().Equal("mirror.io/my-function:latest", suite.client.getDeploymentImage(&functionInstance))
+
+	// the function keeps running the mirror image on reconciles
+	now := time.Now()
+	functionInstance.Status.ActiveImage = "mirror.io/my-function:latest"
+	functionInstance.Status.ActiveImageSince = &now
+	suite.Require().Equal("mirror.io/my-function:latest", suite.client.getFunctionImage(&functionInstance))
+
+	// until it's time to roll back, if it rolls back
+	functionInstance.Spec.FallbackImageRollback = true
+	suite.Require().Equal("mirror.io/my-function:latest", suite.client.getFunctionImage(&functionInstance))
+
+	longAgo := now.Add(-time.Hour)
+	functionInstance.Status.ActiveImageSince = &longAgo
+	suite.Require().Equal("primary.io/my-function:latest", suite.client.getFunctionImage(&functionInstance))
+
+	// the mirror can't be pulled either - out of images
+	createPodBackingOffPull("second-pod", "mirror.io/my-function:latest")
+	deployment, err = suite.client.kubeClientSet.AppsV1().
+		Deployments(functionInstance.Namespace).
+		Get(deployment.Name, metav1.GetOptions{})
+	suite.Require().NoError(err)
+	err = suite.client.failOverImage(&functionInstance, deployment, &WaitAvailableResult{})
+	suite.Require().Error(err)
+
+	// fallback images are validated
+	functionInstance.Spec.FallbackImages = []string{"primary.io/my-function:latest"}
+	suite.Require().Error(suite.client.validateFallbackImages(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestRescheduleStuckPods() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Namespace = "test-namespace"
+	functionInstance.Spec.PodStartupRetries = 1
+	functionInstance.Spec.PodStartupTimeoutSeconds = 60
+
+	createPodStuckStarting := func(name string, scheduledAgo time.Duration) {
+		_, err := suite.client.kubeClientSet.CoreV1().Pods(functionInstance.Namespace).Create(&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: functionInstance.Namespace,
+				Labels: map[string]string{
+					"nuclio.io/function-name": functionInstance.Name,
+				},
+			},
+			Spec: v1.PodSpec{
+				NodeName: "wedged-node",
+			},
+			Status: v1.PodStatus{
+				Phase: v1.PodPending,
+				Conditions: []v1.PodCondition{
+					{
+						Type:               v1.PodScheduled,
+						Status:             v1.ConditionTrue,
+						LastTransitionTime: metav1.NewTime(time.Now().Add(-scheduledAgo)),
+					},
+				},
+				ContainerStatuses: []v1.ContainerStatus{
+					{
+						State: v1.ContainerState{
+							Waiting: &v1.ContainerStateWaiting{
+								Reason: "ContainerCreating",
+							},
+						},
+					},
+				},
+			},
+		})
+		suite.Require().NoError(err)
+	}
+
+	// still within the startup timeout - left as is
+	result := WaitAvailableResult{}
+	createPodStuckStarting("first-pod", 30*time.Second)
+	err := suite.client.rescheduleStuckPods(&functionInstance, &result)
+	suite.Require().NoError(err)
+	suite.Require().Zero(result.ForcedReschedules)
+
+	// stuck for too long - pod is deleted to force a reschedule
+	err = suite.client.kubeClientSet.CoreV1().Pods(functionInstance.Namespace).Delete("first-pod", nil)
+	suite.Require().NoError(err)
+	createPodStuckStarting("second-pod", 2*time.Minute)
+	err = suite.client.rescheduleStuckPods(&functionInstance, &result)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1, result.ForcedReschedules)
+
+	pods, err := suite.client.getFunctionPods(functionInstance.Namespace, functionInstance.Name)
+	suite.Require().NoError(err)
+	suite.Require().Empty(pods)
+
+	// replacement is stuck as well - out of retries
+	createPodStuckStarting("third-pod", 2*time.Minute)
+	err = suite.client.rescheduleStuckPods(&functionInstance, &result)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "ContainerCreating")
+	suite.Require().Equal(2, result.ForcedReschedules)
+}
+
+func (suite *lazyTestSuite) TestServiceAlias() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			ServiceAlias: "my-alias",
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	serviceAlias, err := suite.client.createOrUpdateServiceAlias(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal("my-alias", serviceAlias.Name)
+	suite.Require().Equal(v1.ServiceTypeExternalName, serviceAlias.Spec.Type)
+	suite.Require().Equal("nuclio-my-function.test-namespace.svc.cluster.local", serviceAlias.Spec.ExternalName)
+
+	// renaming the alias removes the previous one
+	functionInstance.Spec.ServiceAlias = "my-other-alias"
+	_, err = suite.client.createOrUpdateServiceAlias(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+
+	_, err = suite.client.kubeClientSet.CoreV1().Services("test-namespace").Get("my-alias", metav1.GetOptions{})
+	suite.Require().True(apierrors.IsNotFound(err))
+
+	// an alias can't take over another function's alias
+	otherFunctionInstance := functionInstance
+	otherFunctionInstance.Name = "other-function"
+	otherFunctionLabels := suite.client.getFunctionLabels(&otherFunctionInstance)
+	otherFunctionLabels["nuclio.io/function-name"] = otherFunctionInstance.Name
+
+	_, err = suite.client.createOrUpdateServiceAlias(otherFunctionLabels, &otherFunctionInstance)
+	suite.Require().Error(err)
+
+	// deleting the function's aliases
+	err = suite.client.deleteServiceAliases("test-namespace", functionInstance.Name, "")
+	suite.Require().NoError(err)
+
+	_, err = suite.client.kubeClientSet.CoreV1().Services("test-namespace").Get("my-other-alias", metav1.GetOptions{})
+	suite.Require().True(apierrors.IsNotFound(err))
+}
+
+func (suite *lazyTestSuite) TestTriggerStartOrder() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			Triggers: map[string]functionconfig.Trigger{
+				"stream":  {Kind: "kafka-cluster"},
+				"http":    {Kind: "http"},
+				"cleanup": {Kind: "cron"},
+			},
+			TriggerStartOrder: []string{"http", "stream"},
+		},
+	}
+
+	suite.Require().NoError(suite.client.validateTriggerStartOrder(&functionInstance))
+
+	// triggers not listed start first
+	suite.Require().Equal([]string{"cleanup", "http", "stream"}, functionInstance.Spec.GetTriggerStartOrder())
+
+	// unknown triggers
+	functionInstance.Spec.TriggerStartOrder = []string{"http", "missing"}
+	suite.Require().Error(suite.client.validateTriggerStartOrder(&functionInstance))
+
+	// triggers listed twice
+	functionInstance.Spec.TriggerStartOrder = []string{"http", "stream", "http"}
+	suite.Require().Error(suite.client.validateTriggerStartOrder(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestGetActiveTriggers() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			Triggers: map[string]functionconfig.Trigger{
+				"stream": {Kind: "kafka-cluster"},
+				"http":   {Kind: "http"},
+			},
+			TriggerStartOrder: []string{"http", "stream"},
+		},
+	}
+
+	// the processor reports the http trigger ready, and the stream trigger not yet
+	healthCheckServer := httptest.NewServer(nethttp.HandlerFunc(func(responseWriter nethttp.ResponseWriter,
+		request *nethttp.Request) {
+		suite.Require().Equal("/ready", request.URL.Path)
+		suite.Require().Equal("1", request.URL.Query().Get("full"))
+
+		responseWriter.WriteHeader(nethttp.StatusServiceUnavailable)
+		responseWriter.Write([]byte(`{"processor_readiness": "OK", "trigger_http": "OK", "trigger_stream": "Trigger not ready yet"}`)) // nolint: errcheck
+	}))
+	defer healthCheckServer.Close()
+
+	serverURL, err := url.Parse(healthCheckServer.URL)
+	suite.Require().NoError(err)
+	serverPort, err := strconv.Atoi(serverURL.Port())
+	suite.Require().NoError(err)
+
+	createPod := func(name string, ready v1.ConditionStatus) {
+		_, err := suite.client.kubeClientSet.CoreV1().Pods("test-namespace").Create(&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+				Labels:    map[string]string{"nuclio.io/function-name": "my-function"},
+			},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name: "nuclio",
+						ReadinessProbe: &v1.Probe{
+							Handler: v1.Handler{
+								HTTPGet: &v1.HTTPGetAction{
+									Port: intstr.FromInt(serverPort),
+									Path: "/ready",
+								},
+							},
+						},
+					},
+				},
+			},
+			Status: v1.PodStatus{
+				PodIP: serverURL.Hostname(),
+				Conditions: []v1.PodCondition{
+					{Type: v1.PodReady, Status: ready},
+				},
+			},
+		})
+		suite.Require().NoError(err)
+	}
+
+	// nothing to tell without a ready pod
+	createPod("not-ready-pod", v1.ConditionFalse)
+	_, err = suite.client.getActiveTriggers(context.Background(), &functionInstance)
+	suite.Require().Error(err)
+
+	createPod("ready-pod", v1.ConditionTrue)
+	activeTriggers, err := suite.client.getActiveTriggers(context.Background(), &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"http"}, activeTriggers)
+}
+
+func (suite *lazyTestSuite) TestCleanupFinishedJobs() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			Triggers: map[string]functionconfig.Trigger{
+				"cron": {Kind: "cron"},
+			},
+			JobRetentionCount: 2,
+		},
+	}
+
+	now := time.Now()
+	for jobIndex, jobConditionType := range []batchv1.JobConditionType{
+		batchv1.JobComplete,
+		batchv1.JobFailed,
+		batchv1.JobComplete,
+		"",
+	} {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("job-%d", jobIndex),
+				Namespace: functionInstance.Namespace,
+				Labels: map[string]string{
+					"nuclio.io/function-name":         functionInstance.Name,
+					"nuclio.io/function-cron-job-pod": "true",
+				},
+			},
+		}
+
+		// a job that's still running has no conditions
+		if jobConditionType != "" {
+			job.Status.Conditions = []batchv1.JobCondition{
+				{
+					Type:               jobConditionType,
+					Status:             v1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(now.Add(time.Duration(jobIndex) * time.Minute)),
+				},
+			}
+		}
+
+		_, err := suite.client.kubeClientSet.BatchV1().Jobs(functionInstance.Namespace).Create(job)
+		suite.Require().NoError(err)
+	}
+
+	jobRuns, err := suite.client.cleanupFinishedJobs(&functionInstance)
+	suite.Require().NoError(err)
+
+	// the most recent runs are recorded
+	suite.Require().Len(jobRuns, 2)
+	suite.Require().Equal("job-2", jobRuns[0].Name)
+	suite.Require().True(jobRuns[0].Succeeded)
+	suite.Require().Equal("job-1", jobRuns[1].Name)
+	suite.Require().False(jobRuns[1].Succeeded)
+
+	// the oldest finished job is deleted, the running one is left be
+	jobs, err := suite.client.kubeClientSet.BatchV1().Jobs(functionInstance.Namespace).List(metav1.ListOptions{})
+	suite.Require().NoError(err)
+	suite.Require().Len(jobs.Items, 3)
+	for _, job := range jobs.Items {
+		suite.Require().NotEqual("job-0", job.Name)
+	}
+
+	// functions without cron triggers aren't run to completion
+	functionInstance.Spec.Triggers = nil
+	jobRuns, err = suite.client.cleanupFinishedJobs(&functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Empty(jobRuns)
+}
+
+func (suite *lazyTestSuite) TestImmutableFields() {
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.ImmutableFields = []string{
+		"metadata.labels.nuclio.io/project-name",
+		"spec.runtime",
+		"spec.build.path",
+	}
+
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+			Labels: map[string]string{
+				"nuclio.io/project-name": "my-project",
+			},
+		},
+		Spec: functionconfig.Spec{
+			Runtime: "python:3.7",
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	// nothing applied yet
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	_, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+
+	// mutable fields may change
+	functionInstance.Spec.Handler = "main:other_handler"
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	// immutable ones may not
+	functionInstance.Spec.Runtime = "python:3.8"
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "Field spec.runtime is immutable")
+
+	functionInstance.Spec.Runtime = "python:3.7"
+	functionInstance.Labels["nuclio.io/project-name"] = "other-project"
+	functionInstance.Spec.Build.Path = "/some/path"
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10),
+		"Fields metadata.labels.nuclio.io/project-name, spec.build.path are immutable")
+}
+
+func (suite *lazyTestSuite) TestValidateWarmupRequests() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Spec.WarmupRequests = []functionconfig.WarmupRequest{
+		{Path: "/"},
+		{Method: "post", Path: "/prime", ExpectedStatusCode: 202},
+	}
+	suite.Require().NoError(suite.client.validateWarmupRequests(&functionInstance))
+
+	for _, invalidWarmupRequest := range []functionconfig.WarmupRequest{
+		{Method: "CONNECT", Path: "/"},
+		{Path: "prime"},
+		{Path: "/", ExpectedStatusCode: 1000},
+	} {
+		functionInstance.Spec.WarmupRequests = []functionconfig.WarmupRequest{invalidWarmupRequest}
+		suite.Require().Error(suite.client.validateWarmupRequests(&functionInstance))
+	}
+}
+
+func (suite *lazyTestSuite) TestScaleUpPartialReadiness() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+	functionInstance.Spec.ScaleUpMinReadyFraction = 0.5
+
+	targetReplicas := int32(50)
+	deployment := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &targetReplicas,
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          50,
+			UpdatedReplicas:   50,
+			AvailableReplicas: 24,
+		},
+	}
+
+	// not enough replicas are ready yet
+	suite.Require().Nil(suite.client.getScaleUpPartialReadiness(&functionInstance, deployment))
+
+	// enough are
+	deployment.Status.AvailableReplicas = 25
+	suite.Require().Equal(&functionconfig.PartialReadinessStatus{
+		ReadyReplicas:  25,
+		TargetReplicas: 50,
+	}, suite.client.getScaleUpPartialReadiness(&functionInstance, deployment))
+
+	// a rollout of a new pod template isn't a scale up
+	deployment.Status.UpdatedReplicas = 30
+	suite.Require().Nil(suite.client.getScaleUpPartialReadiness(&functionInstance, deployment))
+	deployment.Status.UpdatedReplicas = 50
+
+	// functions that aren't ready yet must have all of their replicas ready
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForResourceConfiguration
+	suite.Require().Nil(suite.client.getScaleUpPartialReadiness(&functionInstance, deployment))
+	functionInstance.Status.State = functionconfig.FunctionStateReady
+
+	// as must functions that don't settle for some
+	functionInstance.Spec.ScaleUpMinReadyFraction = 0
+	suite.Require().Nil(suite.client.getScaleUpPartialReadiness(&functionInstance, deployment))
+
+	functionInstance.Spec.ScaleUpMinReadyFraction = 1.5
+	err := suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "between 0 and 1")
+}
+
+func (suite *lazyTestSuite) TestAPIVersionMigration() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Namespace = "test-namespace"
+	functionInstance.Spec.MinReplicas = &[]int{1}[0]
+	functionInstance.Spec.MaxReplicas = &[]int{3}[0]
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+
+	// without detected API versions, the legacy ones are used
+	ingresses, err := suite.client.getIngressAccessor()
+	suite.Require().NoError(err)
+	suite.Require().Equal(ingressAPIVersionExtensionsV1beta1, ingresses.apiVersion)
+
+	hpas, err := suite.client.getHPAAccessor()
+	suite.Require().NoError(err)
+	suite.Require().Equal(hpaAPIVersionAutoscalingV2beta1, hpas.apiVersion)
+
+	fakeDiscovery := suite.client.kubeClientSet.Discovery().(*fakediscovery.FakeDiscovery)
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: ingressAPIVersionNetworkingV1beta1,
+			APIResources: []metav1.APIResource{
+				{Name: "ingresses", Kind: "Ingress", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: hpaAPIVersionAutoscalingV2beta2,
+			APIResources: []metav1.APIResource{
+				{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true},
+			},
+		},
+	}
+
+	// the served versions are looked up again once their cached lookup expires
+	hpas, err = suite.client.getHPAAccessor()
+	suite.Require().NoError(err)
+	suite.Require().Equal(hpaAPIVersionAutoscalingV2beta1, hpas.apiVersion)
+	suite.client.clusterLookups = newClusterLookupCache(clusterLookupCacheTTL)
+
+	// an HPA rendered before the upgrade, served in the new version
+	_, err = suite.client.kubeClientSet.AutoscalingV2beta2().
+		HorizontalPodAutoscalers(functionInstance.Namespace).
+		Create(&autosv2beta2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      kube.HPANameFromFunctionName(functionInstance.Name),
+				Namespace: functionInstance.Namespace,
+			},
+		})
+	suite.Require().NoError(err)
+
+	// it's migrated on reconcile
+	_, err = suite.client.createOrUpdateHorizontalPodAutoscaler(functionLabels, &functionInstance)
+	suite.Require().NoError(err)
+
+	hpa, err := suite.client.kubeClientSet.AutoscalingV2beta2().
+		HorizontalPodAutoscalers(functionInstance.Namespace).
+		Get(kube.HPANameFromFunctionName(functionInstance.Name), metav1.GetOptions{})
+	suite.Require().NoError(err)
+	suite.Require().Equal(hpaAPIVersionAutoscalingV2beta2, hpa.Annotations[apiVersionAnnotation])
+	suite.Require().Equal(int32(3), hpa.Spec.MaxReplicas)
+	suite.Require().Len(hpa.Spec.Metrics, 1)
+	suite.Require().Equal(v1.ResourceCPU, hpa.Spec.Metrics[0].Resource.Name)
+	suite.Require().Equal(autosv2beta2.UtilizationMetricType, hpa.Spec.Metrics[0].Resource.Target.Type)
+	suite.Require().Equal(int32(abstract.DefaultTargetCPU), *hpa.Spec.Metrics[0].Resource.Target.AverageUtilization)
+
+	// ingresses convert to and from the new version as is
+	ingresses, err = suite.client.getIngressAccessor()
+	suite.Require().NoError(err)
+	suite.Require().Equal(ingressAPIVersionNetworkingV1beta1, ingresses.apiVersion)
+
+	_, err = ingresses.Create(&extv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kube.IngressNameFromFunctionName(functionInstance.Name),
+			Namespace: functionInstance.Namespace,
+		},
+		Spec: extv1beta1.IngressSpec{
+			Rules: []extv1beta1.IngressRule{
+				{
+					Host: "my-function.example.com",
+					IngressRuleValue: extv1beta1.IngressRuleValue{
+						HTTP: &extv1beta1.HTTPIngressRuleValue{
+							Paths: []extv1beta1.HTTPIngressPath{
+								{
+									Path: "/",
+									Backend: extv1beta1.IngressBackend{
+										ServiceName: "nuclio-my-function",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+
+	networkingIngress, err := suite.client.kubeClientSet.NetworkingV1beta1().
+		Ingresses(functionInstance.Namespace).
+		Get(kube.IngressNameFromFunctionName(functionInstance.Name), metav1.GetOptions{})
+	suite.Require().NoError(err)
+	suite.Require().Equal("my-function.example.com", networkingIngress.Spec.Rules[0].Host)
+	suite.Require().Equal("nuclio-my-function",
+		networkingIngress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName)
+
+	ingress, err := ingresses.Get(functionInstance.Namespace, kube.IngressNameFromFunctionName(functionInstance.Name))
+	suite.Require().NoError(err)
+	suite.Require().Equal(8080, ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort.IntValue())
+}
+
+func (suite *lazyTestSuite) TestKnownGoodReadiness() {
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Namespace = "test-namespace"
+	functionInstance.Spec.Image = "registry.io/my-function:latest"
+	functionInstance.Spec.KnownGoodReadinessTimeoutSeconds = 5
+	functionInstance.Status.State = functionconfig.FunctionStateWaitingForScaleResourcesFromZero
+
+	specChecksum, err := getSpecChecksum(&functionInstance)
+	suite.Require().NoError(err)
+	functionInstance.Status.KnownGood = &functionconfig.KnownGoodStatus{
+		SpecChecksum: specChecksum,
+		ImageID:      "registry.io/my-function@sha256:good",
+	}
+
+	// one of the replicas is available, which wouldn't pass full verification
+	_, err = suite.client.kubeClientSet.AppsV1().Deployments(functionInstance.Namespace).Create(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kube.DeploymentNameFromFunctionName(functionInstance.Name),
+			Namespace: functionInstance.Namespace,
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas:            3,
+			AvailableReplicas:   1,
+			UnavailableReplicas: 2,
+		},
+	})
+	suite.Require().NoError(err)
+
+	pod, err := suite.client.kubeClientSet.CoreV1().Pods(functionInstance.Namespace).Create(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function-pod",
+			Namespace: functionInstance.Namespace,
+			Labels: map[string]string{
+				"nuclio.io/function-name": functionInstance.Name,
+			},
+		},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "nuclio", Ready: true, ImageID: "registry.io/my-function@sha256:good"},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+
+	// it runs the known-good image, so readiness is short-circuited
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := suite.client.WaitAvailable(ctx, &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().True(result.KnownGoodShortCircuited)
+	suite.Require().Equal(functionInstance.Status.KnownGood, result.KnownGood)
+
+	// the tag was pushed over - the image isn't known to be good
+	pod.Status.ContainerStatuses[0].ImageID = "registry.io/my-function@sha256:other"
+	_, err = suite.client.kubeClientSet.CoreV1().Pods(functionInstance.Namespace).Update(pod)
+	suite.Require().NoError(err)
+
+	err = suite.client.waitKnownGoodDeploymentAvailable(ctx, &functionInstance, functionInstance.Status.KnownGood, result)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "rather than the known-good")
+
+	// once verified, it's what's known to be good
+	suite.Require().Equal("registry.io/my-function@sha256:other", suite.client.getKnownGood(&functionInstance).ImageID)
+
+	// changed images and configurations are always verified fully
+	suite.Require().NotNil(suite.client.getMatchingKnownGood(&functionInstance))
+	functionInstance.Spec.Image = "registry.io/my-function:v2"
+	suite.Require().Nil(suite.client.getMatchingKnownGood(&functionInstance))
+}
+
+func (suite *lazyTestSuite) TestIngressConflicts() {
+	createFunctionIngress := func(functionName string, host string, paths ...string) {
+		var httpPaths []extv1beta1.HTTPIngressPath
+		for _, path := range paths {
+			httpPaths = append(httpPaths, extv1beta1.HTTPIngressPath{Path: path})
+		}
+
+		_, err := suite.client.kubeClientSet.ExtensionsV1beta1().Ingresses("test-namespace").Create(&extv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      kube.IngressNameFromFunctionName(functionName),
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"nuclio.io/class":         "function",
+					"nuclio.io/function-name": functionName,
+				},
+			},
+			Spec: extv1beta1.IngressSpec{
+				Rules: []extv1beta1.IngressRule{
+					{
+						Host: host,
+						IngressRuleValue: extv1beta1.IngressRuleValue{
+							HTTP: &extv1beta1.HTTPIngressRuleValue{Paths: httpPaths},
+						},
+					},
+				},
+			},
+		})
+		suite.Require().NoError(err)
+	}
+
+	createFunctionIngress("other-host-function", "other.example.com", "/api")
+	createFunctionIngress("sibling-function", "example.com", "/apis", "/web")
+
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Namespace = "test-namespace"
+	functionInstance.Spec.IngressConflictPolicy = functionconfig.IngressConflictPolicyReject
+	functionInstance.Spec.Triggers = map[string]functionconfig.Trigger{
+		"http": {
+			Kind: "http",
+			Attributes: map[string]interface{}{
+				"ingresses": map[string]interface{}{
+					"api": map[string]interface{}{
+						"host":  "example.com",
+						"paths": []string{"/api"},
+					},
+				},
+			},
+		},
+	}
+
+	// its own ingress, other hosts and paths that merely share a prefix don't conflict
+	createFunctionIngress(functionInstance.Name, "example.com", "/api")
+	suite.Require().NoError(suite.client.CheckIngressConflicts(context.Background(), &functionInstance))
+
+	// overlapping paths do
+	createFunctionIngress("conflicting-function", "example.com", "/api/v1")
+	err := suite.client.CheckIngressConflicts(context.Background(), &functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(ErrIngressConflict, errors.RootCause(err))
+	suite.Require().Equal("Path /api on host example.com overlaps path /api/v1 of function conflicting-function",
+		err.Error())
+
+	for _, pathsOverlap := range []struct {
+		path      string
+		otherPath string
+		overlap   bool
+	}{
+		{"/", "/anything", true},
+		{"/api/", "/api", true},
+		{"/api", "/api/v1/users", true},
+		{"/api", "/apis", false},
+		{"/api/v1", "/api/v2", false},
+	} {
+		suite.Require().Equal(pathsOverlap.overlap,
+			ingressPathsOverlap(pathsOverlap.path, pathsOverlap.otherPath),
+			"%s, %s", pathsOverlap.path, pathsOverlap.otherPath)
+	}
+}
+
+func (suite *lazyTestSuite) TestIngressHostUniqueness() {
+	for _, ingress := range []struct {
+		namespace    string
+		functionName string
+		host         string
+	}{
+		{"test-namespace", "sibling-function", "example.com"},
+		{"other-namespace", "other-host-function", "other.example.com"},
+		{"other-namespace", "my-function", "example.com"},
+	} {
+		_, err := suite.client.kubeClientSet.ExtensionsV1beta1().Ingresses(ingress.namespace).Create(&extv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      kube.IngressNameFromFunctionName(ingress.functionName),
+				Namespace: ingress.namespace,
+				Labels: map[string]string{
+					"nuclio.io/class":         "function",
+					"nuclio.io/function-name": ingress.functionName,
+				},
+			},
+			Spec: extv1beta1.IngressSpec{
+				Rules: []extv1beta1.IngressRule{
+					{
+						Host: ingress.host,
+						IngressRuleValue: extv1beta1.IngressRuleValue{
+							HTTP: &extv1beta1.HTTPIngressRuleValue{
+								Paths: []extv1beta1.HTTPIngressPath{{Path: "/other"}},
+							},
+						},
+					},
+				},
+			},
+		})
+		suite.Require().NoError(err)
+	}
+
+	functionInstance := nuclioio.NuclioFunction{}
+	functionInstance.Name = "my-function"
+	functionInstance.Namespace = "test-namespace"
+	functionInstance.Spec.Triggers = map[string]functionconfig.Trigger{
+		"http": {
+			Kind: "http",
+			Attributes: map[string]interface{}{
+				"ingresses": map[string]interface{}{
+					"api": map[string]interface{}{
+						"host":  "example.com",
+						"paths": []string{"/api"},
+					},
+				},
+			},
+		},
+	}
+
+	// hosts may be shared across namespaces by default
+	suite.Require().NoError(suite.client.CheckIngressConflicts(context.Background(), &functionInstance))
+
+	// unless they must be unique, in which case a function of the same name in another namespace conflicts,
+	// while functions in the same namespace don't
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.UniqueIngressHosts = true
+	err := suite.client.CheckIngressConflicts(context.Background(), &functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Equal(ErrIngressConflict, errors.RootCause(err))
+	suite.Require().Equal("Host example.com is used by function my-function in namespace other-namespace",
+		err.Error())
+
+	// a controller watching a single namespace doesn't look beyond it
+	suite.client.SetWatchedNamespace("test-namespace")
+	suite.Require().NoError(suite.client.CheckIngressConflicts(context.Background(), &functionInstance))
+}
+
+func (suite *lazyTestSuite) TestStableNodePort() {
+	platformConfiguration := suite.client.platformConfigurationProvider.GetPlatformConfiguration()
+	platformConfiguration.Kube.StableNodePortRange = "31000-31002"
+
+	// a service outside nuclio holds the first port
+	_, err := suite.client.kubeClientSet.CoreV1().Services("other-namespace").Create(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-service",
+			Namespace: "other-namespace",
+		},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeNodePort,
+			Ports: []v1.ServicePort{{Name: "http", Port: 80, NodePort: 31000}},
+		},
+	})
+	suite.Require().NoError(err)
+
+	newFunction := func(name string) *nuclioio.NuclioFunction {
+		functionInstance := &nuclioio.NuclioFunction{}
+		functionInstance.Name = name
+		functionInstance.Namespace = "test-namespace"
+		functionInstance.Spec.ServiceType = v1.ServiceTypeNodePort
+		functionInstance.Spec.StableNodePort = true
+		return functionInstance
+	}
+
+	createOrUpdateService := func(functionInstance *nuclioio.NuclioFunction) (int, error) {
+		service, err := suite.client.createOrUpdateService(suite.client.getFunctionLabels(functionInstance),
+			functionInstance)
+		if err != nil {
+			return 0, err
+		}
+
+		return int(service.Spec.Ports[0].NodePort), nil
+	}
+
+	// the first free port is allocated
+	firstFunction := newFunction("first-function")
+	nodePort, err := createOrUpdateService(firstFunction)
+	suite.Require().NoError(err)
+	suite.Require().Equal(31001, nodePort)
+
+	// and kept when the service is recreated
+	err = suite.client.kubeClientSet.CoreV1().
+		Services(firstFunction.Namespace).
+		Delete(kube.ServiceNameFromFunctionName(firstFunction.Name), &metav1.DeleteOptions{})
+	suite.Require().NoError(err)
+
+	nodePort, err = createOrUpdateService(firstFunction)
+	suite.Require().NoError(err)
+	suite.Require().Equal(31001, nodePort)
+
+	// other functions get other ports, until the range is exhausted
+	nodePort, err = createOrUpdateService(newFunction("second-function"))
+	suite.Require().NoError(err)
+	suite.Require().Equal(31002, nodePort)
+
+	_, err = createOrUpdateService(newFunction("third-function"))
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "are in use")
+
+	// no longer requesting a stable node port releases it, in favor of one assigned by kubernetes
+	firstFunction.Status.StableNodePort = 31001
+	firstFunction.Spec.StableNodePort = false
+	nodePort, err = createOrUpdateService(firstFunction)
+	suite.Require().NoError(err)
+	suite.Require().Zero(nodePort)
+
+	nodePort, err = createOrUpdateService(newFunction("third-function"))
+	suite.Require().NoError(err)
+	suite.Require().Equal(31001, nodePort)
+
+	// deleting a function (and with it, its service) releases its port
+	err = suite.client.kubeClientSet.CoreV1().
+		Services("test-namespace").
+		Delete(kube.ServiceNameFromFunctionName("second-function"), &metav1.DeleteOptions{})
+	suite.Require().NoError(err)
+
+	nodePort, err = createOrUpdateService(newFunction("fourth-function"))
+	suite.Require().NoError(err)
+	suite.Require().Equal(31002, nodePort)
+
+	// a controller watching a single namespace allocates off the ports used in it
+	suite.client.SetWatchedNamespace("test-namespace")
+	nodePort, err = createOrUpdateService(newFunction("fifth-function"))
+	suite.Require().NoError(err)
+	suite.Require().Equal(31000, nodePort)
+
+	// stable ports can't be requested along with explicit ones
+	explicitPortFunction := newFunction("explicit-port-function")
+	explicitPortFunction.Spec.Triggers = map[string]functionconfig.Trigger{
+		"http": {
+			Kind:       "http",
+			Attributes: map[string]interface{}{"port": 32000},
+		},
+	}
+	suite.Require().Error(suite.client.validateFunction(explicitPortFunction))
+}
+
+func (suite *lazyTestSuite) TestRuntimeClass() {
+	_, err := suite.client.kubeClientSet.NodeV1beta1().RuntimeClasses().Create(&nodev1beta1.RuntimeClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "gvisor",
+		},
+		Handler: "runsc",
+	})
+	suite.Require().NoError(err)
+
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			RuntimeClassName: "gvisor",
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	// rendered into the pod spec
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+	deploymentInstance, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal("gvisor", *deploymentInstance.Spec.Template.Spec.RuntimeClassName)
+
+	// and removed from it along with the spec's
+	functionInstance.Spec.RuntimeClassName = ""
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Nil(deploymentInstance.Spec.Template.Spec.RuntimeClassName)
+
+	// a missing runtime class is rejected by default
+	functionInstance.Spec.RuntimeClassName = "gviser"
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "Runtime class gviser does not exist")
+
+	// unless the platform only warns of it
+	runtimeClasses := &suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.RuntimeClasses
+	runtimeClasses.MissingPolicy = platformconfig.MissingRuntimeClassPolicyWarn
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+
+	// runtime classes that can't be read fail the validation, rather than passing it unchecked
+	suite.client.kubeClientSet.(*fake.Clientset).PrependReactor("get",
+		"runtimeclasses",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(nodev1beta1.Resource("runtimeclasses"), "gviser", nil)
+		})
+
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "Not permitted to get runtime class gviser")
+}
+
+func (suite *lazyTestSuite) TestDebugSidecar() {
+	suite.client.platformConfigurationProvider.GetPlatformConfiguration().Kube.DebugSidecars = map[string]v1.Container{
+		"shell": {
+			Image:   "busybox:1.36",
+			Command: []string{"sleep", "infinity"},
+		},
+	}
+
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+	}
+	functionLabels := suite.client.getFunctionLabels(&functionInstance)
+	functionLabels["nuclio.io/function-name"] = functionInstance.Name
+
+	deploymentInstance, err := suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Len(deploymentInstance.Spec.Template.Spec.Containers, 1)
+	suite.Require().Empty(suite.client.getDeploymentDebugSidecar(&functionInstance))
+
+	// annotating the function attaches the sidecar on the next reconcile
+	functionInstance.Annotations = map[string]string{
+		"nuclio.io/debug-sidecar": "shell",
+	}
+	suite.Require().NoError(suite.client.validateFunction(&functionInstance))
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Len(deploymentInstance.Spec.Template.Spec.Containers, 2)
+	suite.Require().Equal("nuclio", deploymentInstance.Spec.Template.Spec.Containers[0].Name)
+	suite.Require().Equal("debug-shell", deploymentInstance.Spec.Template.Spec.Containers[1].Name)
+	suite.Require().Equal("busybox:1.36", deploymentInstance.Spec.Template.Spec.Containers[1].Image)
+	suite.Require().True(*deploymentInstance.Spec.Template.Spec.ShareProcessNamespace)
+	suite.Require().Equal("shell", suite.client.getDeploymentDebugSidecar(&functionInstance))
+
+	// containers added by others (e.g. a mesh's proxy) are kept across reconciles
+	deploymentInstance.Spec.Template.Spec.Containers = append(deploymentInstance.Spec.Template.Spec.Containers,
+		v1.Container{Name: "proxy", Image: "envoy"})
+	_, err = suite.client.kubeClientSet.AppsV1().Deployments(functionInstance.Namespace).Update(deploymentInstance)
+	suite.Require().NoError(err)
+
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Len(deploymentInstance.Spec.Template.Spec.Containers, 3)
+	suite.Require().Equal("nuclio", deploymentInstance.Spec.Template.Spec.Containers[0].Name)
+	suite.Require().Equal("proxy", deploymentInstance.Spec.Template.Spec.Containers[1].Name)
+	suite.Require().Equal("debug-shell", deploymentInstance.Spec.Template.Spec.Containers[2].Name)
+
+	// and clearing the annotation detaches it
+	functionInstance.Annotations = nil
+	deploymentInstance, err = suite.client.createOrUpdateDeployment(functionLabels, "", &functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Len(deploymentInstance.Spec.Template.Spec.Containers, 2)
+	suite.Require().Equal("proxy", deploymentInstance.Spec.Template.Spec.Containers[1].Name)
+	suite.Require().Nil(deploymentInstance.Spec.Template.Spec.ShareProcessNamespace)
+	suite.Require().Empty(suite.client.getDeploymentDebugSidecar(&functionInstance))
+
+	// sidecars that aren't configured are rejected
+	functionInstance.Annotations = map[string]string{
+		"nuclio.io/debug-sidecar": "profiler",
+	}
+	err = suite.client.validateFunction(&functionInstance)
+	suite.Require().Error(err)
+	suite.Require().Contains(errors.GetErrorStackString(err, 10), "Debug sidecar profiler is not configured")
+}
+
+func (suite *lazyTestSuite) TestDeployGenerationLabel() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "my-function",
+			Namespace:  "test-namespace",
+			Generation: 3,
+		},
+		Spec: functionconfig.Spec{
+			MinReplicas: &[]int{1}[0],
+			MaxReplicas: &[]int{3}[0],
+			Triggers: map[string]functionconfig.Trigger{
+				"http": {
+					Kind: "http",
+					Attributes: map[string]interface{}{
+						"ingresses": map[string]interface{}{
+							"api": map[string]interface{}{
+								"host":  "example.com",
+								"paths": []string{"/api"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	requireDeployGeneration := func(generation string) {
+		var resourcesMeta []metav1.ObjectMeta
+
+		configMap, err := suite.client.kubeClientSet.CoreV1().ConfigMaps(functionInstance.Namespace).
+			Get(kube.ConfigMapNameFromFunctionName(functionInstance.Name), metav1.GetOptions{})
+		suite.Require().NoError(err)
+		resourcesMeta = append(resourcesMeta, configMap.ObjectMeta)
+
+		service, err := suite.client.kubeClientSet.CoreV1().Services(functionInstance.Namespace).
+			Get(kube.ServiceNameFromFunctionName(functionInstance.Name), metav1.GetOptions{})
+		suite.Require().NoError(err)
+		resourcesMeta = append(resourcesMeta, service.ObjectMeta)
+
+		deployment, err := suite.client.kubeClientSet.AppsV1().Deployments(functionInstance.Namespace).
+			Get(kube.DeploymentNameFromFunctionName(functionInstance.Name), metav1.GetOptions{})
+		suite.Require().NoError(err)
+		resourcesMeta = append(resourcesMeta, deployment.ObjectMeta)
+
+		hpa, err := suite.client.kubeClientSet.AutoscalingV2beta1().HorizontalPodAutoscalers(functionInstance.Namespace).
+			Get(kube.HPANameFromFunctionName(functionInstance.Name), metav1.GetOptions{})
+		suite.Require().NoError(err)
+		resourcesMeta = append(resourcesMeta, hpa.ObjectMeta)
+
+		ingress, err := suite.client.kubeClientSet.ExtensionsV1beta1().Ingresses(functionInstance.Namespace).
+			Get(kube.IngressNameFromFunctionName(functionInstance.Name), metav1.GetOptions{})
+		suite.Require().NoError(err)
+		resourcesMeta = append(resourcesMeta, ingress.ObjectMeta)
+
+		for _, resourceMeta := range resourcesMeta {
+			suite.Require().Equal(generation, resourceMeta.Labels["nuclio.io/deploy-generation"], resourceMeta.Name)
+		}
+
+		// pods aren't rolled out for it
+		suite.Require().NotContains(deployment.Spec.Template.Labels, "nuclio.io/deploy-generation")
+		suite.Require().NotContains(deployment.Spec.Selector.MatchLabels, "nuclio.io/deploy-generation")
+	}
+
+	_, err := suite.client.CreateOrUpdate(context.Background(), &functionInstance, "")
+	suite.Require().NoError(err)
+	requireDeployGeneration("3")
+
+	// updated along with the function
+	functionInstance.Generation = 4
+	_, err = suite.client.CreateOrUpdate(context.Background(), &functionInstance, "")
+	suite.Require().NoError(err)
+	requireDeployGeneration("4")
+}
+
+func (suite *lazyTestSuite) TestNamespaceFunctionDefaults() {
+	functionInstance := &nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+			Labels: map[string]string{
+				"team": "function-team",
+			},
+		},
+		Spec: functionconfig.Spec{
+			Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("1"),
+				},
+			},
+		},
+	}
+
+	// no defaults - the function is used as is
+	mergedFunction, err := suite.client.MergeNamespaceFunctionDefaults(context.Background(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(functionInstance, mergedFunction)
+
+	_, err = suite.client.kubeClientSet.CoreV1().ConfigMaps("test-namespace").Create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FunctionDefaultsConfigMapName,
+			Namespace: "test-namespace",
+		},
+		Data: map[string]string{
+			FunctionDefaultsConfigMapKey: `
+metadata:
+  labels:
+    team: platform-team
+    cost-center: "1234"
+spec:
+  serviceAccount: restricted
+  eventTimeout: 10s
+  resources:
+    limits:
+      cpu: 500m
+      memory: 512Mi
+`,
+		},
+	})
+	suite.Require().NoError(err)
+
+	mergedFunction, err = suite.client.MergeNamespaceFunctionDefaults(context.Background(), functionInstance)
+	suite.Require().NoError(err)
+
+	// the function wins on conflict
+	suite.Require().Equal(map[string]string{
+		"team":        "function-team",
+		"cost-center": "1234",
+	}, mergedFunction.Labels)
+	suite.Require().Equal("1", mergedFunction.Spec.Resources.Limits.Cpu().String())
+	suite.Require().Equal("512Mi", mergedFunction.Spec.Resources.Limits.Memory().String())
+	suite.Require().Equal("restricted", mergedFunction.Spec.ServiceAccount)
+	suite.Require().Equal("10s", mergedFunction.Spec.EventTimeout)
+	suite.Require().Equal("my-function", mergedFunction.Name)
+
+	// the function itself is left untouched
+	suite.Require().Len(functionInstance.Labels, 1)
+	suite.Require().Len(functionInstance.Spec.Resources.Limits, 1)
+	suite.Require().Empty(functionInstance.Spec.ServiceAccount)
+}
+
+func (suite *lazyTestSuite) TestNamespaceFunctionDefaultsExplicitValues() {
+	zero := 0
+	functionInstance := &nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+		Spec: functionconfig.Spec{
+			MinReplicas: &zero,
+			Triggers: map[string]functionconfig.Trigger{
+				"http": {
+					Kind: "http",
+					Attributes: map[string]interface{}{
+						"port": 8080,
+					},
+				},
+			},
+		},
+	}
+
+	_, err := suite.client.kubeClientSet.CoreV1().ConfigMaps("test-namespace").Create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FunctionDefaultsConfigMapName,
+			Namespace: "test-namespace",
+		},
+		Data: map[string]string{
+			FunctionDefaultsConfigMapKey: `
+spec:
+  disable: true
+  minReplicas: 2
+  targetCPU: 75
+  triggers:
+    cron:
+      kind: cron
+`,
+		},
+	})
+	suite.Require().NoError(err)
+
+	mergedFunction, err := suite.client.MergeNamespaceFunctionDefaults(context.Background(), functionInstance)
+	suite.Require().NoError(err)
+
+	// an explicit 0 (through a pointer), and false / 0 (which can't be told from unset) are kept
+	suite.Require().Equal(0, *mergedFunction.Spec.MinReplicas)
+	suite.Require().False(mergedFunction.Spec.Disable)
+	suite.Require().Zero(mergedFunction.Spec.TargetCPU)
+
+	// maps are merged key by key, keeping the function's values as they are
+	suite.Require().Len(mergedFunction.Spec.Triggers, 2)
+	suite.Require().Equal(8080, mergedFunction.Spec.Triggers["http"].Attributes["port"])
+	suite.Require().Len(functionInstance.Spec.Triggers, 1)
+
+	// unset pointers are taken from the defaults
+	functionInstance.Spec.MinReplicas = nil
+	mergedFunction, err = suite.client.MergeNamespaceFunctionDefaults(context.Background(), functionInstance)
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, *mergedFunction.Spec.MinReplicas)
+}
+
+func (suite *lazyTestSuite) TestNamespaceFunctionDefaultsCache() {
+	suite.client.SetWatchedNamespace("test-namespace")
+
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+
+	suite.client.StartCaches(stopChannel)
+	suite.Require().Eventually(suite.client.functionDefaultsSynced, 5*time.Second, 10*time.Millisecond)
+
+	// once synced, the defaults are read off the cache
+	configMapGets := 0
+	suite.client.kubeClientSet.(*fake.Clientset).PrependReactor("get",
+		"configmaps",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			configMapGets++
+			return false, nil, nil
+		})
+
+	_, err := suite.client.kubeClientSet.CoreV1().ConfigMaps("test-namespace").Create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FunctionDefaultsConfigMapName,
+			Namespace: "test-namespace",
+		},
+		Data: map[string]string{
+			FunctionDefaultsConfigMapKey: `
+spec:
+  serviceAccount: restricted
+`,
+		},
+	})
+	suite.Require().NoError(err)
+
+	functionInstance := &nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: "test-namespace",
+		},
+	}
+
+	suite.Require().Eventually(func() bool {
+		mergedFunction, err := suite.client.MergeNamespaceFunctionDefaults(context.Background(), functionInstance)
+		suite.Require().NoError(err)
+		return mergedFunction.Spec.ServiceAccount == "restricted"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	suite.Require().Zero(configMapGets)
+}
+
+// startNodeCache starts the node cache and waits for it to sync. the returned channel stops it once closed
+func (suite *lazyTestSuite) startNodeCache() chan struct{} {
+	stopChannel := make(chan struct{})
+
+	suite.client.startNodeCache(stopChannel)
+	suite.Require().Eventually(suite.client.nodesSynced, 5*time.Second, 10*time.Millisecond)
+
+	return stopChannel
+}
+
+func (suite *lazyTestSuite) createNode(name string, cpu string, memory string, taints []v1.Taint) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.NodeSpec{
+			Taints: taints,
+		},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			},
+		},
+	}
+}
+
 func (suite *lazyTestSuite) getIngressRuleByHost(rules []extv1beta1.IngressRule, host string) *extv1beta1.IngressRule {
 	for _, rule := range rules {
 		if rule.Host == host {
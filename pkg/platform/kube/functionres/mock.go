@@ -22,6 +22,11 @@ import (
 	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
 
 	"github.com/stretchr/testify/mock"
+	appsv1 "k8s.io/api/apps/v1"
+	autosv2 "k8s.io/api/autoscaling/v2beta1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
 )
 
 type MockedFunctionRes struct {
@@ -43,9 +48,10 @@ func (mfr *MockedFunctionRes) CreateOrUpdate(ctx context.Context, function *nucl
 	return args.Get(0).(Resources), args.Error(1)
 }
 
-func (mfr *MockedFunctionRes) WaitAvailable(ctx context.Context, s string, s2 string) error {
-	args := mfr.Called(ctx, s, s2)
-	return args.Error(0)
+func (mfr *MockedFunctionRes) WaitAvailable(ctx context.Context, function *nuclioio.NuclioFunction) (*WaitAvailableResult, error) {
+	args := mfr.Called(ctx, function)
+	waitAvailableResult, _ := args.Get(0).(*WaitAvailableResult)
+	return waitAvailableResult, args.Error(1)
 }
 
 func (mfr *MockedFunctionRes) Delete(ctx context.Context, s string, s2 string) error {
@@ -53,6 +59,83 @@ func (mfr *MockedFunctionRes) Delete(ctx context.Context, s string, s2 string) e
 	return args.Error(0)
 }
 
+func (mfr *MockedFunctionRes) Restart(ctx context.Context, function *nuclioio.NuclioFunction) error {
+	args := mfr.Called(ctx, function)
+	return args.Error(0)
+}
+
+func (mfr *MockedFunctionRes) MergeNamespaceFunctionDefaults(ctx context.Context,
+	function *nuclioio.NuclioFunction) (*nuclioio.NuclioFunction, error) {
+	args := mfr.Called(ctx, function)
+
+	// without a merged function to return, the namespace is taken to have no defaults
+	mergedFunction, _ := args.Get(0).(*nuclioio.NuclioFunction)
+	if mergedFunction == nil {
+		mergedFunction = function
+	}
+
+	return mergedFunction, args.Error(1)
+}
+
+func (mfr *MockedFunctionRes) CheckSchedulingFeasibility(ctx context.Context,
+	function *nuclioio.NuclioFunction) error {
+	args := mfr.Called(ctx, function)
+	return args.Error(0)
+}
+
+func (mfr *MockedFunctionRes) CheckIngressConflicts(ctx context.Context,
+	function *nuclioio.NuclioFunction) error {
+	args := mfr.Called(ctx, function)
+	return args.Error(0)
+}
+
 func (mfr *MockedFunctionRes) SetPlatformConfigurationProvider(provider PlatformConfigurationProvider) {
 	mfr.Called(provider)
 }
+
+func (mfr *MockedFunctionRes) SetWatchedNamespace(namespace string) {
+	mfr.Called(namespace)
+}
+
+func (mfr *MockedFunctionRes) StartCaches(stopChannel <-chan struct{}) {
+	mfr.Called(stopChannel)
+}
+
+type MockedResources struct {
+	mock.Mock
+}
+
+func (mr *MockedResources) Deployment() (*appsv1.Deployment, error) {
+	args := mr.Called()
+	return args.Get(0).(*appsv1.Deployment), args.Error(1)
+}
+
+func (mr *MockedResources) ConfigMap() (*v1.ConfigMap, error) {
+	args := mr.Called()
+	return args.Get(0).(*v1.ConfigMap), args.Error(1)
+}
+
+func (mr *MockedResources) Service() (*v1.Service, error) {
+	args := mr.Called()
+	return args.Get(0).(*v1.Service), args.Error(1)
+}
+
+func (mr *MockedResources) ServiceAlias() (*v1.Service, error) {
+	args := mr.Called()
+	return args.Get(0).(*v1.Service), args.Error(1)
+}
+
+func (mr *MockedResources) HorizontalPodAutoscaler() (*autosv2.HorizontalPodAutoscaler, error) {
+	args := mr.Called()
+	return args.Get(0).(*autosv2.HorizontalPodAutoscaler), args.Error(1)
+}
+
+func (mr *MockedResources) Ingress() (*extv1beta1.Ingress, error) {
+	args := mr.Called()
+	return args.Get(0).(*extv1beta1.Ingress), args.Error(1)
+}
+
+func (mr *MockedResources) CronJobs() ([]*batchv1beta1.CronJob, error) {
+	args := mr.Called()
+	return args.Get(0).([]*batchv1beta1.CronJob), args.Error(1)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/ghodss/yaml"
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+)
+
+func (lc *lazyClient) MergeNamespaceFunctionDefaults(ctx context.Context,
+	function *nuclioio.NuclioFunction) (*nuclioio.NuclioFunction, error) {
+	defaultsConfigMap, err := lc.getFunctionDefaultsConfigMap(function.Namespace)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return function, nil
+		}
+
+		return nil, errors.Wrap(err, "Failed to get function defaults configmap")
+	}
+
+	functionDefaults := functionconfig.Config{}
+	if err := yaml.Unmarshal([]byte(defaultsConfigMap.Data[FunctionDefaultsConfigMapKey]),
+		&functionDefaults); err != nil {
+		return nil, errors.Wrap(err, "Failed to unmarshal function defaults")
+	}
+
+	// the defaults only fill in what the function leaves unset (merging maps key by key), so that whatever the
+	// function sets wins. merging replaces the function's maps rather than adding to them, so the copy's spec can
+	// share them (the spec's deep copy is shallow) without the function being modified
+	mergedFunction := function.DeepCopy()
+	mergedFunction.Labels = getMergedStringMap(mergedFunction.Labels, functionDefaults.Meta.Labels)
+	mergedFunction.Annotations = getMergedStringMap(mergedFunction.Annotations, functionDefaults.Meta.Annotations)
+	mergeDefaultValue(reflect.ValueOf(&mergedFunction.Spec).Elem(), reflect.ValueOf(functionDefaults.Spec))
+
+	lc.logger.DebugWith("Merged namespace function defaults",
+		"functionName", function.Name,
+		"namespace", function.Namespace)
+
+	return mergedFunction, nil
+}
+
+// getFunctionDefaultsConfigMap returns the namespace's function defaults configmap off the cache, or off the API
+// server until the cache has synced
+func (lc *lazyClient) getFunctionDefaultsConfigMap(namespace string) (*v1.ConfigMap, error) {
+	lc.cachesLock.Lock()
+	functionDefaultsLister := lc.functionDefaultsLister
+	functionDefaultsSynced := lc.functionDefaultsSynced
+	lc.cachesLock.Unlock()
+
+	if functionDefaultsLister != nil && functionDefaultsSynced() {
+		return functionDefaultsLister.ConfigMaps(namespace).Get(FunctionDefaultsConfigMapName)
+	}
+
+	return lc.kubeClientSet.CoreV1().
+		ConfigMaps(namespace).
+		Get(FunctionDefaultsConfigMapName, metav1.GetOptions{})
+}
+
+// startFunctionDefaultsCache starts caching the function defaults configmaps of the watched namespace(s)
+func (lc *lazyClient) startFunctionDefaultsCache(stopChannel <-chan struct{}) {
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(lc.kubeClientSet,
+		0,
+		informers.WithNamespace(lc.watchedNamespace),
+		informers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
+			listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name",
+				FunctionDefaultsConfigMapName).String()
+		}))
+	configMapInformer := informerFactory.Core().V1().ConfigMaps()
+
+	lc.cachesLock.Lock()
+	lc.functionDefaultsLister = configMapInformer.Lister()
+	lc.functionDefaultsSynced = configMapInformer.Informer().HasSynced
+	lc.cachesLock.Unlock()
+
+	informerFactory.Start(stopChannel)
+}
+
+// mergeDefaultValue fills what value leaves unset with defaultValue. structs are merged field by field and maps
+// key by key (into a new map). pointers, slices, interfaces and strings are taken from the defaults only if unset
+// (a set pointer is kept as is, even if what it points to is zero). bools and numbers are kept as they are, as an
+// explicit false or 0 can't be told apart from an unset one
+func mergeDefaultValue(value reflect.Value, defaultValue reflect.Value) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for fieldIndex := 0; fieldIndex < value.NumField(); fieldIndex++ {
+			if value.Field(fieldIndex).CanSet() {
+				mergeDefaultValue(value.Field(fieldIndex), defaultValue.Field(fieldIndex))
+			}
+		}
+
+	case reflect.Map:
+		if defaultValue.Len() == 0 {
+			return
+		}
+
+		mergedMap := reflect.MakeMap(value.Type())
+		for _, key := range defaultValue.MapKeys() {
+			mergedMap.SetMapIndex(key, defaultValue.MapIndex(key))
+		}
+
+		for _, key := range value.MapKeys() {
+			mergedMap.SetMapIndex(key, value.MapIndex(key))
+		}
+
+		value.Set(mergedMap)
+
+	case reflect.Ptr, reflect.Slice, reflect.Interface:
+		if value.IsNil() {
+			value.Set(defaultValue)
+		}
+
+	case reflect.String:
+		if value.Len() == 0 {
+			value.Set(defaultValue)
+		}
+	}
+}
+
+// returns the values with the defaults added for keys they don't have
+func getMergedStringMap(values map[string]string, defaultValues map[string]string) map[string]string {
+	for key, defaultValue := range defaultValues {
+		if _, found := values[key]; found {
+			continue
+		}
+
+		if values == nil {
+			values = map[string]string{}
+		}
+
+		values[key] = defaultValue
+	}
+
+	return values
+}
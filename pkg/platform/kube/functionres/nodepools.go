@@ -0,0 +1,88 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"reflect"
+
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+)
+
+// getNodePoolTolerations returns tolerations for the taints of the node pool the function targets, if any
+func (lc *lazyClient) getNodePoolTolerations(function *nuclioio.NuclioFunction) ([]v1.Toleration, error) {
+	if function.Spec.TargetNodePool == "" {
+		return nil, nil
+	}
+
+	nodePool, nodePoolFound :=
+		lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.NodePools[function.Spec.TargetNodePool]
+	if !nodePoolFound {
+		return nil, errors.Errorf("Unknown node pool: %s", function.Spec.TargetNodePool)
+	}
+
+	var tolerations []v1.Toleration
+	for _, taint := range nodePool.Taints {
+		toleration := v1.Toleration{
+			Key:      taint.Key,
+			Operator: v1.TolerationOpEqual,
+			Value:    taint.Value,
+			Effect:   taint.Effect,
+		}
+
+		// taints without a value are tolerated whatever their value
+		if taint.Value == "" {
+			toleration.Operator = v1.TolerationOpExists
+		}
+
+		tolerations = append(tolerations, toleration)
+	}
+
+	return tolerations, nil
+}
+
+// returns the pod's tolerations with those applied previously for the function's node pool replaced by the
+// current ones, leaving tolerations set by others as is
+func getMergedTolerations(tolerations []v1.Toleration,
+	appliedTolerations []v1.Toleration,
+	nodePoolTolerations []v1.Toleration) []v1.Toleration {
+
+	containsToleration := func(tolerations []v1.Toleration, toleration v1.Toleration) bool {
+		for _, candidateToleration := range tolerations {
+			if reflect.DeepEqual(candidateToleration, toleration) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var mergedTolerations []v1.Toleration
+	for _, toleration := range tolerations {
+		if !containsToleration(appliedTolerations, toleration) {
+			mergedTolerations = append(mergedTolerations, toleration)
+		}
+	}
+
+	for _, toleration := range nodePoolTolerations {
+		if !containsToleration(mergedTolerations, toleration) {
+			mergedTolerations = append(mergedTolerations, toleration)
+		}
+	}
+
+	return mergedTolerations
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// returns the function's stable node port - as recorded in its status, or allocated to it from the platform's stable
+// node port range. must be called with the allocation lock held
+func (lc *lazyClient) getStableNodePort(function *nuclioio.NuclioFunction) (int, error) {
+	if function.Status.StableNodePort != 0 {
+		return function.Status.StableNodePort, nil
+	}
+
+	stableNodePortRange := lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.StableNodePortRange
+	firstPort, lastPort, err := parseStableNodePortRange(stableNodePortRange)
+	if err != nil {
+		return 0, errors.Wrap(err, "Invalid stable node port range")
+	}
+
+	usedNodePorts, ownNodePort, err := lc.getUsedNodePorts(function)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to get used node ports")
+	}
+
+	// a function whose service already has a port in range (e.g. allocated before its status recorded it) keeps it
+	if ownNodePort >= firstPort && ownNodePort <= lastPort {
+		return ownNodePort, nil
+	}
+
+	for port := firstPort; port <= lastPort; port++ {
+		if !usedNodePorts[port] {
+			lc.logger.InfoWith("Allocated stable node port",
+				"functionName", function.Name,
+				"namespace", function.Namespace,
+				"nodePort", port)
+
+			return port, nil
+		}
+	}
+
+	return 0, errors.Errorf("All node ports in the stable node port range %s are in use", stableNodePortRange)
+}
+
+// returns the node ports used by other functions (as recorded in their status) and by services in the watched
+// namespaces, along with the node port of the function's own service, if any
+func (lc *lazyClient) getUsedNodePorts(function *nuclioio.NuclioFunction) (map[int]bool, int, error) {
+	usedNodePorts := map[int]bool{}
+	ownNodePort := 0
+
+	services, err := lc.kubeClientSet.CoreV1().Services(lc.watchedNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "Failed to list services")
+	}
+
+	for _, service := range services.Items {
+		for _, servicePort := range service.Spec.Ports {
+			if servicePort.NodePort == 0 {
+				continue
+			}
+
+			if service.Namespace == function.Namespace &&
+				service.Name == kube.ServiceNameFromFunctionName(function.Name) &&
+				servicePort.Name == ContainerHTTPPortName {
+				ownNodePort = int(servicePort.NodePort)
+				continue
+			}
+
+			usedNodePorts[int(servicePort.NodePort)] = true
+		}
+	}
+
+	// functions whose services are being recreated still hold their ports
+	if lc.nuclioClientSet != nil {
+		functions, err := lc.nuclioClientSet.NuclioV1beta1().NuclioFunctions(lc.watchedNamespace).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "Failed to list functions")
+		}
+
+		for _, otherFunction := range functions.Items {
+			if otherFunction.Status.StableNodePort != 0 &&
+				(otherFunction.Namespace != function.Namespace || otherFunction.Name != function.Name) {
+				usedNodePorts[otherFunction.Status.StableNodePort] = true
+			}
+		}
+	}
+
+	return usedNodePorts, ownNodePort, nil
+}
+
+// parses a stable node port range of the form "<first>-<last>"
+func parseStableNodePortRange(stableNodePortRange string) (int, int, error) {
+	if stableNodePortRange == "" {
+		return 0, 0, errors.New("No stable node port range is configured")
+	}
+
+	rangeParts := strings.SplitN(stableNodePortRange, "-", 2)
+	if len(rangeParts) != 2 {
+		return 0, 0, errors.Errorf("Expected <first>-<last>, got: %s", stableNodePortRange)
+	}
+
+	firstPort, err := strconv.Atoi(strings.TrimSpace(rangeParts[0]))
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "Failed to parse first port")
+	}
+
+	lastPort, err := strconv.Atoi(strings.TrimSpace(rangeParts[1]))
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "Failed to parse last port")
+	}
+
+	if firstPort <= 0 || firstPort > lastPort {
+		return 0, 0, errors.Errorf("Invalid range: %s", stableNodePortRange)
+	}
+
+	return firstPort, lastPort, nil
+}
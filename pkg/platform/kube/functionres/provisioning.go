@@ -0,0 +1,139 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getProvisioningPhases breaks down the time it took the function's most recent pod to become ready, by reading
+// the pod's conditions, container states and image pull events. a phase that did not complete yet is measured
+// up until now. this is best effort - on failure, no phases are returned
+func (lc *lazyClient) getProvisioningPhases(namespace string, name string) []functionconfig.ProvisioningPhase {
+	pod, err := lc.getMostRecentFunctionPod(namespace, name)
+	if err != nil || pod == nil {
+		lc.logger.DebugWith("Failed to get function pod, skipping provisioning phases",
+			"namespace", namespace,
+			"functionName", name,
+			"err", err)
+		return nil
+	}
+
+	now := time.Now()
+	var phases []functionconfig.ProvisioningPhase
+
+	// each phase starts when the previous phase ended
+	phaseStart := pod.CreationTimestamp.Time
+	addPhase := func(phaseName string, phaseEnd time.Time) bool {
+		completed := !phaseEnd.IsZero()
+		if !completed {
+			phaseEnd = now
+		}
+
+		phases = append(phases, functionconfig.ProvisioningPhase{
+			Name:      phaseName,
+			Duration:  phaseEnd.Sub(phaseStart).Round(time.Second).String(),
+			Completed: completed,
+		})
+
+		phaseStart = phaseEnd
+		return completed
+	}
+
+	var scheduledTime, readyTime, containerStartedTime time.Time
+	for _, podCondition := range pod.Status.Conditions {
+		if podCondition.Status != v1.ConditionTrue {
+			continue
+		}
+
+		switch podCondition.Type {
+		case v1.PodScheduled:
+			scheduledTime = podCondition.LastTransitionTime.Time
+		case v1.PodReady:
+			readyTime = podCondition.LastTransitionTime.Time
+		}
+	}
+
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Running != nil {
+			containerStartedTime = containerStatus.State.Running.StartedAt.Time
+		}
+	}
+
+	if !addPhase(functionconfig.ProvisioningPhaseScheduling, scheduledTime) {
+		return phases
+	}
+
+	if !addPhase(functionconfig.ProvisioningPhaseImagePull, lc.getPodImagePulledTime(pod, containerStartedTime)) {
+		return phases
+	}
+
+	if !addPhase(functionconfig.ProvisioningPhaseContainerStart, containerStartedTime) {
+		return phases
+	}
+
+	addPhase(functionconfig.ProvisioningPhaseReadiness, readyTime)
+
+	return phases
+}
+
+func (lc *lazyClient) getMostRecentFunctionPod(namespace string, name string) (*v1.Pod, error) {
+	pods, err := lc.getFunctionPods(namespace, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get function pods")
+	}
+
+	var mostRecentPod *v1.Pod
+	for podIndex, pod := range pods {
+		if mostRecentPod == nil || mostRecentPod.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			mostRecentPod = &pods[podIndex]
+		}
+	}
+
+	return mostRecentPod, nil
+}
+
+// returns when the pod finished pulling its image, according to the pod's events. if the events are not
+// available (e.g. already expired) yet the container has started, the pull is assumed to have ended on start
+func (lc *lazyClient) getPodImagePulledTime(pod *v1.Pod, containerStartedTime time.Time) time.Time {
+	events, err := lc.kubeClientSet.CoreV1().Events(pod.Namespace).List(metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s", pod.Name),
+	})
+	if err != nil {
+		return containerStartedTime
+	}
+
+	var imagePulledTime time.Time
+	for _, event := range events.Items {
+		if event.InvolvedObject.Name == pod.Name && event.Reason == "Pulled" &&
+			event.LastTimestamp.Time.After(imagePulledTime) {
+			imagePulledTime = event.LastTimestamp.Time
+		}
+	}
+
+	if imagePulledTime.IsZero() {
+		return containerStartedTime
+	}
+
+	return imagePulledTime
+}
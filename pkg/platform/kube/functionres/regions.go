@@ -0,0 +1,78 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nuclio/nuclio/pkg/common"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// returns the regions the function is served from. if the function doesn't specify any, it is served from all
+// the allowed regions
+func (lc *lazyClient) resolveRegions(function *nuclioio.NuclioFunction) ([]string, error) {
+	allowedRegions := lc.platformConfigurationProvider.GetPlatformConfiguration().IngressConfig.AllowedRegions
+	if len(function.Spec.Regions) == 0 {
+		return allowedRegions, nil
+	}
+
+	var regions []string
+	for _, region := range function.Spec.Regions {
+		if errorMessages := validation.IsDNS1123Label(region); len(errorMessages) != 0 {
+			return nil, errors.Errorf("Invalid region %s: %s", region, strings.Join(errorMessages, ", "))
+		}
+
+		if len(allowedRegions) != 0 && !common.StringSliceContainsString(allowedRegions, region) {
+			return nil, errors.Errorf("Region %s is not allowed (allowed regions: %s)",
+				region,
+				strings.Join(allowedRegions, ", "))
+		}
+
+		if !common.StringSliceContainsString(regions, region) {
+			regions = append(regions, region)
+		}
+	}
+
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// sets the annotation through which the global load balancer routes the function to its serving regions, or
+// removes it when the function isn't bound to any region
+func (lc *lazyClient) populateRegionsAnnotation(function *nuclioio.NuclioFunction, annotations map[string]string) error {
+	regionsAnnotation := lc.platformConfigurationProvider.GetPlatformConfiguration().IngressConfig.RegionsAnnotation
+	if regionsAnnotation == "" {
+		return nil
+	}
+
+	regions, err := lc.resolveRegions(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve regions")
+	}
+
+	if len(regions) == 0 {
+		delete(annotations, regionsAnnotation)
+		return nil
+	}
+
+	annotations[regionsAnnotation] = strings.Join(regions, ",")
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/nuclio/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validateRuntimeClass fails functions whose runtime class doesn't exist (unless the platform only warns of it),
+// as their pods would otherwise never be created
+func (lc *lazyClient) validateRuntimeClass(function *nuclioio.NuclioFunction) error {
+	if function.Spec.RuntimeClassName == "" {
+		return nil
+	}
+
+	_, err := lc.kubeClientSet.NodeV1beta1().RuntimeClasses().Get(function.Spec.RuntimeClassName,
+		metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+
+	// the controller isn't permitted to read runtime classes, so it can't tell whether this one exists. deploying
+	// regardless would defeat the missing runtime class policy
+	if apierrors.IsForbidden(err) {
+		return errors.Wrapf(err,
+			"Not permitted to get runtime class %s (the controller requires get on node.k8s.io runtimeclasses)",
+			function.Spec.RuntimeClassName)
+	}
+
+	if !apierrors.IsNotFound(err) {
+
+		// best effort, the pods' creation will tell
+		lc.logger.WarnWith("Failed to get runtime class, skipping its validation",
+			"functionName", function.Name,
+			"runtimeClassName", function.Spec.RuntimeClassName,
+			"err", err)
+		return nil
+	}
+
+	runtimeClasses := lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.RuntimeClasses
+	switch runtimeClasses.MissingPolicy {
+	case "", platformconfig.MissingRuntimeClassPolicyReject:
+		return errors.Errorf("Runtime class %s does not exist", function.Spec.RuntimeClassName)
+	case platformconfig.MissingRuntimeClassPolicyWarn:
+		lc.logger.WarnWith("Function runtime class does not exist",
+			"functionName", function.Name,
+			"runtimeClassName", function.Spec.RuntimeClassName)
+		return nil
+	default:
+		return errors.Errorf("Unknown missing runtime class policy: %s", runtimeClasses.MissingPolicy)
+	}
+}
+
+// returns the runtime class the function's pods run under, if any
+func (lc *lazyClient) getRuntimeClassName(function *nuclioio.NuclioFunction) *string {
+	if function.Spec.RuntimeClassName == "" {
+		return nil
+	}
+
+	runtimeClassName := function.Spec.RuntimeClassName
+	return &runtimeClassName
+}
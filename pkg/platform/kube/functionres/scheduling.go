@@ -0,0 +1,329 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/informers"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+func (lc *lazyClient) CheckSchedulingFeasibility(ctx context.Context, function *nuclioio.NuclioFunction) error {
+	return lc.validateSchedulingFeasibility(function)
+}
+
+// validateSchedulingFeasibility fails functions whose pods request more of a resource than the largest node
+// they may be scheduled on can allocate, as these would otherwise stay pending until timing out. the nodes are
+// those of the node pool and capacity tier the function is assigned to (if any), narrowed down by the node
+// selector, required node affinity and tolerations its pods carry
+func (lc *lazyClient) validateSchedulingFeasibility(function *nuclioio.NuclioFunction) error {
+	requiredResources := lc.getRequiredResources(function)
+	if len(requiredResources) == 0 {
+		return nil
+	}
+
+	// the check is skipped until the node cache syncs, rather than hold the reconcile up
+	nodeLister, err := lc.getNodeLister()
+	if err != nil {
+		lc.logger.DebugWith("Node cache not available, skipping scheduling feasibility check",
+			"functionName", function.Name,
+			"err", err)
+		return nil
+	}
+
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+
+		// best effort, the pods' scheduling will tell
+		lc.logger.WarnWith("Failed to list nodes, skipping scheduling feasibility check",
+			"functionName", function.Name,
+			"err", err)
+		return nil
+	}
+
+	var nodePoolTaints []v1.Taint
+	if function.Spec.TargetNodePool != "" {
+		nodePoolTaints =
+			lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.NodePools[function.Spec.TargetNodePool].Taints
+	}
+
+	podPlacement, err := lc.getPodPlacement(function)
+	if err != nil {
+		lc.logger.WarnWith("Failed to get function pod placement, skipping scheduling feasibility check",
+			"functionName", function.Name,
+			"err", err)
+		return nil
+	}
+
+	// the largest allocatable amount of each resource across the nodes the function may be scheduled on
+	largestAllocatable := v1.ResourceList{}
+	candidateNodes := 0
+
+	for _, node := range nodes {
+		if node.Spec.Unschedulable ||
+			!lc.nodeHasTaints(node, nodePoolTaints) ||
+			!podPlacement.matches(node) {
+			continue
+		}
+
+		candidateNodes++
+
+		for resourceName := range requiredResources {
+			allocatable, found := node.Status.Allocatable[resourceName]
+			if !found {
+				continue
+			}
+
+			if largest, found := largestAllocatable[resourceName]; !found || allocatable.Cmp(largest) > 0 {
+				largestAllocatable[resourceName] = allocatable
+			}
+		}
+	}
+
+	// nothing to compare against (e.g. the node pool's nodes were scaled down by an autoscaler)
+	if candidateNodes == 0 {
+		return nil
+	}
+
+	var infeasibilityMessages []string
+	for resourceName, required := range requiredResources {
+		largest, found := largestAllocatable[resourceName]
+		if !found || largest.IsZero() {
+			infeasibilityMessages = append(infeasibilityMessages,
+				fmt.Sprintf("pod requires %s %s but no node provides it - remove it from the function's resources "+
+					"or add nodes that provide it",
+					required.String(),
+					resourceName))
+			continue
+		}
+
+		if required.Cmp(largest) > 0 {
+			infeasibilityMessages = append(infeasibilityMessages,
+				fmt.Sprintf("pod requires %s %s but largest node has %s - reduce the function's %s request to "+
+					"at most %s, or add nodes with at least %s allocatable",
+					required.String(),
+					resourceName,
+					largest.String(),
+					resourceName,
+					largest.String(),
+					required.String()))
+		}
+	}
+
+	if len(infeasibilityMessages) == 0 {
+		return nil
+	}
+
+	// keep the message stable across resyncs
+	sort.Strings(infeasibilityMessages)
+
+	return errors.Wrap(ErrSchedulingInfeasible, strings.Join(infeasibilityMessages, "; "))
+}
+
+// getNodeLister returns a lister of the cluster's nodes, once the cache it lists off has synced
+func (lc *lazyClient) getNodeLister() (corev1listers.NodeLister, error) {
+	lc.cachesLock.Lock()
+	nodeLister := lc.nodeLister
+	nodesSynced := lc.nodesSynced
+	lc.cachesLock.Unlock()
+
+	if nodeLister == nil {
+		return nil, errors.New("Node cache was not started")
+	}
+
+	if !nodesSynced() {
+		return nil, errors.New("Node cache has not synced yet")
+	}
+
+	return nodeLister, nil
+}
+
+// startNodeCache starts caching the cluster's nodes
+func (lc *lazyClient) startNodeCache(stopChannel <-chan struct{}) {
+	informerFactory := informers.NewSharedInformerFactory(lc.kubeClientSet, 0)
+	nodeInformer := informerFactory.Core().V1().Nodes()
+
+	lc.cachesLock.Lock()
+	lc.nodeLister = nodeInformer.Lister()
+	lc.nodesSynced = nodeInformer.Informer().HasSynced
+	lc.cachesLock.Unlock()
+
+	informerFactory.Start(stopChannel)
+}
+
+// podPlacement holds what the function's pods are constrained to be scheduled by
+type podPlacement struct {
+	nodeSelector         labels.Selector
+	requiredNodeAffinity *v1.NodeSelector
+	tolerations          []v1.Toleration
+}
+
+// getPodPlacement returns what the function's pods will be placed by - what its pod template carries (as set by
+// others), along with the tolerations of its node pool and the node selector of its capacity tier
+func (lc *lazyClient) getPodPlacement(function *nuclioio.NuclioFunction) (*podPlacement, error) {
+	nodePoolTolerations, err := lc.getNodePoolTolerations(function)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get node pool tolerations")
+	}
+
+	capacityTierNodeSelector := lc.getCapacityTierNodeSelector(function)
+
+	deployment, err := lc.kubeClientSet.AppsV1().
+		Deployments(function.Namespace).
+		Get(kube.DeploymentNameFromFunctionName(function.Name), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, errors.Wrap(err, "Failed to get function deployment")
+		}
+
+		// not deployed yet, so placed only by what it's assigned to
+		return &podPlacement{
+			nodeSelector: labels.SelectorFromSet(capacityTierNodeSelector),
+			tolerations:  nodePoolTolerations,
+		}, nil
+	}
+
+	var appliedTolerations []v1.Toleration
+	lc.decodeAppliedDeploymentAnnotation(deployment, nodePoolTolerationsAnnotation, &appliedTolerations)
+	var appliedCapacityTierNodeSelector map[string]string
+	lc.decodeAppliedDeploymentAnnotation(deployment,
+		capacityTierNodeSelectorAnnotation,
+		&appliedCapacityTierNodeSelector)
+
+	podSpec := deployment.Spec.Template.Spec
+	placement := &podPlacement{
+		nodeSelector: labels.SelectorFromSet(getMergedNodeSelector(podSpec.NodeSelector,
+			appliedCapacityTierNodeSelector,
+			capacityTierNodeSelector)),
+		tolerations: getMergedTolerations(podSpec.Tolerations, appliedTolerations, nodePoolTolerations),
+	}
+
+	if podSpec.Affinity != nil && podSpec.Affinity.NodeAffinity != nil {
+		placement.requiredNodeAffinity = podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	}
+
+	return placement, nil
+}
+
+// matches returns whether pods placed so may be scheduled on the node
+func (pp *podPlacement) matches(node *v1.Node) bool {
+	if !pp.nodeSelector.Matches(labels.Set(node.Labels)) {
+		return false
+	}
+
+	if pp.requiredNodeAffinity != nil && !nodeSelectorTermsMatch(node, pp.requiredNodeAffinity.NodeSelectorTerms) {
+		return false
+	}
+
+	// nodes tainted with what the pods don't tolerate don't take them
+	for taintIndex, taint := range node.Spec.Taints {
+		if taint.Effect != v1.TaintEffectNoSchedule && taint.Effect != v1.TaintEffectNoExecute {
+			continue
+		}
+
+		tolerated := false
+		for _, toleration := range pp.tolerations {
+			if toleration.ToleratesTaint(&node.Spec.Taints[taintIndex]) {
+				tolerated = true
+				break
+			}
+		}
+
+		if !tolerated {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nodeSelectorTermsMatch returns whether the node matches any of the terms, as the scheduler matches node
+// affinity - a term matches if all of its requirements are met, and empty terms match no node
+func nodeSelectorTermsMatch(node *v1.Node, nodeSelectorTerms []v1.NodeSelectorTerm) bool {
+	for _, nodeSelectorTerm := range nodeSelectorTerms {
+		if len(nodeSelectorTerm.MatchExpressions) == 0 && len(nodeSelectorTerm.MatchFields) == 0 {
+			continue
+		}
+
+		if nodeSelectorRequirementsMatch(nodeSelectorTerm.MatchExpressions, labels.Set(node.Labels)) &&
+			nodeSelectorRequirementsMatch(nodeSelectorTerm.MatchFields, labels.Set{"metadata.name": node.Name}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func nodeSelectorRequirementsMatch(nodeSelectorRequirements []v1.NodeSelectorRequirement, set labels.Set) bool {
+	operators := map[v1.NodeSelectorOperator]selection.Operator{
+		v1.NodeSelectorOpIn:           selection.In,
+		v1.NodeSelectorOpNotIn:        selection.NotIn,
+		v1.NodeSelectorOpExists:       selection.Exists,
+		v1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+		v1.NodeSelectorOpGt:           selection.GreaterThan,
+		v1.NodeSelectorOpLt:           selection.LessThan,
+	}
+
+	for _, nodeSelectorRequirement := range nodeSelectorRequirements {
+		operator, found := operators[nodeSelectorRequirement.Operator]
+		if !found {
+			return false
+		}
+
+		requirement, err := labels.NewRequirement(nodeSelectorRequirement.Key, operator, nodeSelectorRequirement.Values)
+		if err != nil || !requirement.Matches(set) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nodeHasTaints returns whether the node carries all of the given taints. taints without a value (or effect)
+// match any
+func (lc *lazyClient) nodeHasTaints(node *v1.Node, taints []v1.Taint) bool {
+	for _, taint := range taints {
+		found := false
+
+		for _, nodeTaint := range node.Spec.Taints {
+			if nodeTaint.Key == taint.Key &&
+				(taint.Effect == "" || nodeTaint.Effect == taint.Effect) &&
+				(taint.Value == "" || nodeTaint.Value == taint.Value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
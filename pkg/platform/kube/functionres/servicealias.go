@@ -0,0 +1,132 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"github.com/nuclio/nuclio/pkg/platform/kube"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// creates an ExternalName service, named by the function's service alias, that points at the function's service
+func (lc *lazyClient) createOrUpdateServiceAlias(functionLabels labels.Set,
+	function *nuclioio.NuclioFunction) (*v1.Service, error) {
+
+	// first, remove aliases that the function no longer uses (e.g. the alias was renamed or removed)
+	if err := lc.deleteServiceAliases(function.Namespace, function.Name, function.Spec.ServiceAlias); err != nil {
+		return nil, errors.Wrap(err, "Failed to delete removed service aliases")
+	}
+
+	if function.Spec.ServiceAlias == "" {
+		return nil, nil
+	}
+
+	serviceAliasLabels := lc.withDeployGenerationLabel(labels.Merge(functionLabels, labels.Set{
+		"nuclio.io/component": "service-alias",
+	}), function)
+	functionServiceHost, _ := kube.GetDomainNameInvokeURL(kube.ServiceNameFromFunctionName(function.Name),
+		function.Namespace)
+
+	getServiceAlias := func() (interface{}, error) {
+		return lc.kubeClientSet.CoreV1().
+			Services(function.Namespace).
+			Get(function.Spec.ServiceAlias, metav1.GetOptions{})
+	}
+
+	serviceAliasIsDeleting := func(resource interface{}) bool {
+		return (resource).(*v1.Service).ObjectMeta.DeletionTimestamp != nil
+	}
+
+	createServiceAlias := func() (interface{}, error) {
+		return lc.kubeClientSet.CoreV1().Services(function.Namespace).Create(&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      function.Spec.ServiceAlias,
+				Namespace: function.Namespace,
+				Labels:    serviceAliasLabels,
+			},
+			Spec: v1.ServiceSpec{
+				Type:         v1.ServiceTypeExternalName,
+				ExternalName: functionServiceHost,
+			},
+		})
+	}
+
+	updateServiceAlias := func(resource interface{}) (interface{}, error) {
+		serviceAlias := resource.(*v1.Service)
+
+		// never take over a service that isn't this function's alias
+		if serviceAlias.Labels["nuclio.io/function-name"] != function.Name ||
+			serviceAlias.Labels["nuclio.io/component"] != "service-alias" {
+			return nil, errors.Errorf("Service alias %s collides with an existing service (function: %s)",
+				function.Spec.ServiceAlias,
+				serviceAlias.Labels["nuclio.io/function-name"])
+		}
+
+		serviceAlias.Labels = serviceAliasLabels
+		serviceAlias.Spec.Type = v1.ServiceTypeExternalName
+		serviceAlias.Spec.ExternalName = functionServiceHost
+
+		return lc.kubeClientSet.CoreV1().Services(function.Namespace).Update(serviceAlias)
+	}
+
+	resource, err := lc.createOrUpdateResource("serviceAlias",
+		getServiceAlias,
+		serviceAliasIsDeleting,
+		createServiceAlias,
+		updateServiceAlias)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource.(*v1.Service), err
+}
+
+// deletes the function's service aliases, other than the one named by aliasToKeep (if given)
+func (lc *lazyClient) deleteServiceAliases(namespace string, functionName string, aliasToKeep string) error {
+	serviceAliases, err := lc.kubeClientSet.CoreV1().Services(namespace).List(metav1.ListOptions{
+		LabelSelector: labels.Set{
+			"nuclio.io/function-name": functionName,
+			"nuclio.io/component":     "service-alias",
+		}.String(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list service aliases")
+	}
+
+	for _, serviceAlias := range serviceAliases.Items {
+		if serviceAlias.Name == aliasToKeep {
+			continue
+		}
+
+		err := lc.kubeClientSet.CoreV1().Services(namespace).Delete(serviceAlias.Name, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "Failed to delete service alias %s", serviceAlias.Name)
+		}
+
+		lc.logger.DebugWith("Deleted service alias",
+			"namespace", namespace,
+			"functionName", functionName,
+			"serviceAliasName", serviceAlias.Name)
+	}
+
+	return nil
+}
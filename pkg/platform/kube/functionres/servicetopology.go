@@ -0,0 +1,76 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	kubeversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+func (lc *lazyClient) populateServiceTopologyAnnotations(function *nuclioio.NuclioFunction,
+	annotations map[string]string) error {
+	serviceTopologyAnnotations, err := lc.getServiceTopologyAnnotations(function)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get service topology annotations")
+	}
+
+	// start over, in case the topology was unset or the cluster was upgraded
+	delete(annotations, serviceTopologyModeAnnotation)
+	delete(annotations, serviceTopologyAwareHintsAnnotation)
+
+	for annotation, annotationValue := range serviceTopologyAnnotations {
+		annotations[annotation] = annotationValue
+	}
+
+	return nil
+}
+
+// returns the service annotations that render the function's service topology, which depend on what the
+// cluster's version supports
+func (lc *lazyClient) getServiceTopologyAnnotations(function *nuclioio.NuclioFunction) (map[string]string, error) {
+	switch function.Spec.ServiceTopology {
+	case "":
+		return nil, nil
+	case functionconfig.ServiceTopologyPreferSameZone:
+	default:
+		return nil, errors.Errorf("Unknown service topology: %s", function.Spec.ServiceTopology)
+	}
+
+	serverVersionInfo, err := lc.kubeClientSet.Discovery().ServerVersion()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get cluster version")
+	}
+
+	serverVersion, err := kubeversion.ParseGeneric(serverVersionInfo.GitVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse cluster version %s", serverVersionInfo.GitVersion)
+	}
+
+	switch {
+	case serverVersion.AtLeast(kubeversion.MustParseGeneric(serviceTopologyModeMinimumVersion)):
+		return map[string]string{serviceTopologyModeAnnotation: "Auto"}, nil
+	case serverVersion.AtLeast(kubeversion.MustParseGeneric(serviceTopologyAwareHintsMinimumVersion)):
+		return map[string]string{serviceTopologyAwareHintsAnnotation: "auto"}, nil
+	default:
+		return nil, errors.Errorf("Service topology %s requires Kubernetes %s or later (cluster version: %s)",
+			function.Spec.ServiceTopology,
+			serviceTopologyAwareHintsMinimumVersion,
+			serverVersionInfo.GitVersion)
+	}
+}
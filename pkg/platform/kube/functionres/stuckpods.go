@@ -0,0 +1,119 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"time"
+
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// deletes function pods that have been scheduled but stuck starting for longer than the startup timeout (e.g. on a
+// wedged volume mount), so that their replacements are rescheduled. once the configured retries are exhausted,
+// an error is returned
+func (lc *lazyClient) rescheduleStuckPods(function *nuclioio.NuclioFunction, result *WaitAvailableResult) error {
+	if function.Spec.PodStartupRetries == 0 {
+		return nil
+	}
+
+	pods, err := lc.getFunctionPods(function.Namespace, function.Name)
+	if err != nil {
+		lc.logger.DebugWith("Failed to get function pods, skipping stuck pods check",
+			"functionName", function.Name,
+			"err", err)
+		return nil
+	}
+
+	podStartupTimeout := time.Duration(function.Spec.PodStartupTimeoutSeconds) * time.Second
+	if podStartupTimeout == 0 {
+		podStartupTimeout = defaultPodStartupTimeout
+	}
+
+	// sandboxed runtimes start pods slower
+	if function.Spec.RuntimeClassName != "" {
+		runtimeClasses := lc.platformConfigurationProvider.GetPlatformConfiguration().Kube.RuntimeClasses
+		podStartupTimeout = time.Duration(float64(podStartupTimeout) * runtimeClasses.GetStartupTimeoutFactor())
+	}
+
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodPending || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		// failed pulls are retried separately
+		if failedPulling, _ := lc.podFailedPullingImage(&pod); failedPulling {
+			continue
+		}
+
+		// pods that weren't scheduled wouldn't start elsewhere either
+		scheduled := false
+		var scheduledTime time.Time
+		for _, podCondition := range pod.Status.Conditions {
+			if podCondition.Type == v1.PodScheduled && podCondition.Status == v1.ConditionTrue {
+				scheduled = true
+				scheduledTime = podCondition.LastTransitionTime.Time
+			}
+		}
+
+		if !scheduled || time.Since(scheduledTime) < podStartupTimeout {
+			continue
+		}
+
+		stuckReason := lc.getPodStuckReason(&pod)
+
+		result.ForcedReschedules++
+		if result.ForcedReschedules > function.Spec.PodStartupRetries {
+			return errors.Errorf("Pod %s stuck starting on node %s for over %s (%s), after %d forced reschedules",
+				pod.Name,
+				pod.Spec.NodeName,
+				podStartupTimeout,
+				stuckReason,
+				function.Spec.PodStartupRetries)
+		}
+
+		lc.logger.InfoWith("Pod stuck starting, deleting it to force a reschedule",
+			"functionName", function.Name,
+			"podName", pod.Name,
+			"nodeName", pod.Spec.NodeName,
+			"reason", stuckReason,
+			"forcedReschedules", result.ForcedReschedules,
+			"podStartupRetries", function.Spec.PodStartupRetries)
+
+		if err := lc.kubeClientSet.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{}); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "Failed to delete pod %s", pod.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// returns why the pod's containers are waiting (e.g. ContainerCreating), for reporting
+func (lc *lazyClient) getPodStuckReason(pod *v1.Pod) string {
+	for _, containerStatus := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason != "" {
+			return containerStatus.State.Waiting.Reason
+		}
+	}
+
+	return string(v1.PodPending)
+}
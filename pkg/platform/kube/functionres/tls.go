@@ -0,0 +1,73 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/errors"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// resolves the function's TLS mode, defaulting to terminating at the ingress
+func (lc *lazyClient) resolveTLSMode(function *nuclioio.NuclioFunction) functionconfig.TLSMode {
+	if function.Spec.TLSMode == "" {
+		return functionconfig.TLSModeTerminate
+	}
+
+	return function.Spec.TLSMode
+}
+
+func (lc *lazyClient) validateTLS(function *nuclioio.NuclioFunction) error {
+	switch lc.resolveTLSMode(function) {
+	case functionconfig.TLSModeTerminate:
+		return nil
+	case functionconfig.TLSModePassthrough, functionconfig.TLSModeReencrypt:
+	default:
+		return errors.Errorf("Unknown TLS mode: %s", function.Spec.TLSMode)
+	}
+
+	if function.Spec.TLSSecret == "" {
+		return errors.Errorf("TLS mode %s requires a TLS secret", function.Spec.TLSMode)
+	}
+
+	// the function can't start serving without the certificate and key
+	tlsSecret, err := lc.kubeClientSet.CoreV1().
+		Secrets(function.Namespace).
+		Get(function.Spec.TLSSecret, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get TLS secret %s", function.Spec.TLSSecret)
+	}
+
+	for _, requiredKey := range []string{v1.TLSCertKey, v1.TLSPrivateKeyKey} {
+		if len(tlsSecret.Data[requiredKey]) == 0 {
+			return errors.Errorf("TLS secret %s is missing %s", function.Spec.TLSSecret, requiredKey)
+		}
+	}
+
+	// connections are passed through by SNI, so there must be a host to route by
+	if function.Spec.TLSMode == functionconfig.TLSModePassthrough {
+		for _, ingress := range functionconfig.GetIngressesFromTriggers(function.Spec.Triggers) {
+			if ingress.Host == "" {
+				return errors.New("TLS passthrough requires all ingresses to have a host")
+			}
+		}
+	}
+
+	return nil
+}
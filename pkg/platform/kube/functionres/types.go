@@ -3,9 +3,11 @@ package functionres
 import (
 	"context"
 
+	"github.com/nuclio/nuclio/pkg/functionconfig"
 	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
 	"github.com/nuclio/nuclio/pkg/platformconfig"
 
+	"github.com/nuclio/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	autosv2 "k8s.io/api/autoscaling/v2beta1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
@@ -13,6 +15,14 @@ import (
 	extv1beta1 "k8s.io/api/extensions/v1beta1"
 )
 
+// ErrSchedulingInfeasible is the root cause of errors caused by a function's pods requesting more resources
+// than any node can provide
+var ErrSchedulingInfeasible = errors.New("Function pods can't be scheduled on any node")
+
+// ErrIngressConflict is the root cause of errors caused by a function's ingresses claiming paths that overlap
+// those of another function on the same host
+var ErrIngressConflict = errors.New("Function ingresses conflict with another function's")
+
 type PlatformConfigurationProvider interface {
 
 	// GetPlatformConfiguration returns a platform configuration
@@ -33,14 +43,39 @@ type Client interface {
 	// CreateOrUpdate creates or updates existing resources
 	CreateOrUpdate(context.Context, *nuclioio.NuclioFunction, string) (Resources, error)
 
-	// WaitAvailable waits until the resources are ready
-	WaitAvailable(context.Context, string, string) error
+	// WaitAvailable waits until the resources are ready, returning what was observed while waiting. the result
+	// may be nil if the wait failed before anything was observed
+	WaitAvailable(context.Context, *nuclioio.NuclioFunction) (*WaitAvailableResult, error)
 
 	// Delete deletes resources
 	Delete(context.Context, string, string) error
 
+	// Restart performs a rolling restart of the function's pods
+	Restart(context.Context, *nuclioio.NuclioFunction) error
+
+	// MergeNamespaceFunctionDefaults returns a copy of the function, with the defaults configured for the
+	// function's namespace filling in whatever it leaves unset. if the namespace has no defaults, the function is
+	// returned as is. the other methods expect the merged function
+	MergeNamespaceFunctionDefaults(context.Context, *nuclioio.NuclioFunction) (*nuclioio.NuclioFunction, error)
+
+	// CheckSchedulingFeasibility returns ErrSchedulingInfeasible (as the root cause) if the function's pods
+	// request more resources than any node can provide
+	CheckSchedulingFeasibility(context.Context, *nuclioio.NuclioFunction) error
+
+	// CheckIngressConflicts returns ErrIngressConflict (as the root cause) if the function's ingresses claim paths
+	// that overlap those of other functions in the namespace, on the same host
+	CheckIngressConflicts(context.Context, *nuclioio.NuclioFunction) error
+
 	// SetPlatformConfigurationProvider sets the provider of the platform configuration for any future access
 	SetPlatformConfigurationProvider(PlatformConfigurationProvider)
+
+	// SetWatchedNamespace sets the namespace the controller watches functions in (empty for all namespaces),
+	// scoping lookups across functions to it
+	SetWatchedNamespace(string)
+
+	// StartCaches starts the caches the client reads cluster state off (e.g. namespace function defaults), scoped
+	// to the watched namespace, until the channel is closed. until they sync, the API server is read directly
+	StartCaches(<-chan struct{})
 }
 
 // Resources holds the resources a functionres holds
@@ -55,6 +90,9 @@ type Resources interface {
 	// Service returns the service
 	Service() (*v1.Service, error)
 
+	// ServiceAlias returns the service through which the function is reachable by its alias
+	ServiceAlias() (*v1.Service, error)
+
 	// HorizontalPodAutoscaler returns the hpa
 	HorizontalPodAutoscaler() (*autosv2.HorizontalPodAutoscaler, error)
 
@@ -64,3 +102,55 @@ type Resources interface {
 	// CronJob returns the cron job
 	CronJobs() ([]*batchv1beta1.CronJob, error)
 }
+
+// WaitAvailableResult holds what was observed while waiting for the resources to become available. It is
+// returned whether or not the resources became available
+type WaitAvailableResult struct {
+
+	// where the time was spent while bringing up the function's most recent pod
+	ProvisioningPhases []functionconfig.ProvisioningPhase
+
+	// number of failed image pulls observed (each retried pull is preceded by a failed one), and the pod template
+	// of the rollout they were observed in
+	ImagePullAttempts        int
+	ImagePullPodTemplateHash string
+
+	// number of pods stuck starting that were deleted, forcing a reschedule
+	ForcedReschedules int
+
+	// number of available replicas once the deployment became available
+	AvailableReplicas int
+
+	// the outcomes of the most recent runs of the function's cron jobs
+	JobRuns []functionconfig.JobRunStatus
+
+	// the image the function's deployment runs, for functions with fallback images, and whether the deployment
+	// failed over to it
+	ActiveImage     string
+	ImageFailedOver bool
+
+	// set if the deployment could not scale up due to the namespace's resource quota. if the function is
+	// nonetheless serving from the replicas that the quota allows, the wait succeeds
+	ScaleUpBlockedMessage string
+
+	// set if a scale up of a ready function succeeded with only some of its target replicas ready
+	PartialReadiness *functionconfig.PartialReadinessStatus
+
+	// set once the resources are available, for functions that short-circuit readiness with a known-good image
+	// and configuration. KnownGoodShortCircuited is set if readiness was short-circuited
+	KnownGood               *functionconfig.KnownGoodStatus
+	KnownGoodShortCircuited bool
+
+	// the debug sidecar attached to the function's pods, if any
+	DebugSidecar string
+
+	// if the resources did not become available, the category of the failure
+	UnhealthyCategory functionconfig.UnhealthyCategory
+
+	// whether each of the function's external secrets was synced from the store
+	ExternalSecrets []functionconfig.ExternalSecretStatus
+
+	// for functions that order their triggers' start, once the resources are available - the triggers a ready pod
+	// reports active, in start order
+	ActiveTriggers []string
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"strings"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+)
+
+// ClassifyUnhealthy returns the category of the failure that keeps the function's deployment from being
+// available, given the deployment and its pods. when pods fail for different reasons, the one that would
+// surface first when provisioning a pod (quota, scheduling, image, crash, probe) wins
+func ClassifyUnhealthy(deployment *appsv1.Deployment, pods []v1.Pod) functionconfig.UnhealthyCategory {
+	if deployment != nil {
+		for _, deploymentCondition := range deployment.Status.Conditions {
+			if deploymentCondition.Type == appsv1.DeploymentReplicaFailure &&
+				deploymentCondition.Status == v1.ConditionTrue &&
+				strings.Contains(deploymentCondition.Message, "exceeded quota") {
+				return functionconfig.UnhealthyCategoryQuota
+			}
+		}
+	}
+
+	categoryPrecedence := []functionconfig.UnhealthyCategory{
+		functionconfig.UnhealthyCategoryScheduling,
+		functionconfig.UnhealthyCategoryImage,
+		functionconfig.UnhealthyCategoryCrash,
+		functionconfig.UnhealthyCategoryProbe,
+	}
+
+	podCategories := map[functionconfig.UnhealthyCategory]bool{}
+	for podIndex := range pods {
+		if category := classifyUnhealthyPod(&pods[podIndex]); category != "" {
+			podCategories[category] = true
+		}
+	}
+
+	for _, category := range categoryPrecedence {
+		if podCategories[category] {
+			return category
+		}
+	}
+
+	return functionconfig.UnhealthyCategoryUnknown
+}
+
+// returns the category of the pod's failure, or an empty category if the pod isn't failing
+func classifyUnhealthyPod(pod *v1.Pod) functionconfig.UnhealthyCategory {
+	if pod.DeletionTimestamp != nil {
+		return ""
+	}
+
+	for _, podCondition := range pod.Status.Conditions {
+		if podCondition.Type == v1.PodScheduled &&
+			podCondition.Status == v1.ConditionFalse &&
+			podCondition.Reason == v1.PodReasonUnschedulable {
+			return functionconfig.UnhealthyCategoryScheduling
+		}
+	}
+
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Waiting != nil {
+			switch containerStatus.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+				return functionconfig.UnhealthyCategoryImage
+			case "CrashLoopBackOff":
+				return functionconfig.UnhealthyCategoryCrash
+			}
+		}
+
+		if containerStatus.State.Terminated != nil && containerStatus.State.Terminated.ExitCode != 0 {
+			return functionconfig.UnhealthyCategoryCrash
+		}
+
+		if containerStatus.State.Running != nil && !containerStatus.Ready {
+
+			// restarted after crashing, and not ready yet
+			if containerStatus.LastTerminationState.Terminated != nil &&
+				containerStatus.LastTerminationState.Terminated.ExitCode != 0 {
+				return functionconfig.UnhealthyCategoryCrash
+			}
+
+			// running, but not passing its readiness probe
+			return functionconfig.UnhealthyCategoryProbe
+		}
+	}
+
+	return ""
+}
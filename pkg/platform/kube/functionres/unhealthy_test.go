@@ -0,0 +1,158 @@
+// +build test_unit
+
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package functionres
+
+import (
+	"testing"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+
+	"github.com/stretchr/testify/suite"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+)
+
+type unhealthyTestSuite struct {
+	suite.Suite
+}
+
+func (suite *unhealthyTestSuite) TestClassifyUnhealthy() {
+	quotaExceededDeployment := &appsv1.Deployment{
+		Status: appsv1.DeploymentStatus{
+			Conditions: []appsv1.DeploymentCondition{
+				{
+					Type:    appsv1.DeploymentReplicaFailure,
+					Status:  v1.ConditionTrue,
+					Reason:  "FailedCreate",
+					Message: "pods \"f-1\" is forbidden: exceeded quota: compute-resources",
+				},
+			},
+		},
+	}
+
+	unschedulablePod := v1.Pod{
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{
+				{
+					Type:   v1.PodScheduled,
+					Status: v1.ConditionFalse,
+					Reason: v1.PodReasonUnschedulable,
+				},
+			},
+		},
+	}
+
+	for _, testCase := range []struct {
+		name             string
+		deployment       *appsv1.Deployment
+		pods             []v1.Pod
+		expectedCategory functionconfig.UnhealthyCategory
+	}{
+		{
+			name:             "quota",
+			deployment:       quotaExceededDeployment,
+			pods:             []v1.Pod{unschedulablePod},
+			expectedCategory: functionconfig.UnhealthyCategoryQuota,
+		},
+		{
+			name:             "scheduling",
+			deployment:       &appsv1.Deployment{},
+			pods:             []v1.Pod{unschedulablePod},
+			expectedCategory: functionconfig.UnhealthyCategoryScheduling,
+		},
+		{
+			name: "image",
+			pods: []v1.Pod{
+				suite.podWithContainerStatus(v1.ContainerStatus{
+					State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+				}),
+			},
+			expectedCategory: functionconfig.UnhealthyCategoryImage,
+		},
+		{
+			name: "crashLoop",
+			pods: []v1.Pod{
+				suite.podWithContainerStatus(v1.ContainerStatus{
+					State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				}),
+			},
+			expectedCategory: functionconfig.UnhealthyCategoryCrash,
+		},
+		{
+			name: "restartedAfterCrash",
+			pods: []v1.Pod{
+				suite.podWithContainerStatus(v1.ContainerStatus{
+					State:                v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+					LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 137}},
+				}),
+			},
+			expectedCategory: functionconfig.UnhealthyCategoryCrash,
+		},
+		{
+			name: "probe",
+			pods: []v1.Pod{
+				suite.podWithContainerStatus(v1.ContainerStatus{
+					State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				}),
+			},
+			expectedCategory: functionconfig.UnhealthyCategoryProbe,
+		},
+		{
+			name: "imageTakesPrecedenceOverProbe",
+			pods: []v1.Pod{
+				suite.podWithContainerStatus(v1.ContainerStatus{
+					State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				}),
+				suite.podWithContainerStatus(v1.ContainerStatus{
+					State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ErrImagePull"}},
+				}),
+			},
+			expectedCategory: functionconfig.UnhealthyCategoryImage,
+		},
+		{
+			name: "healthyPods",
+			pods: []v1.Pod{
+				suite.podWithContainerStatus(v1.ContainerStatus{
+					State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+					Ready: true,
+				}),
+			},
+			expectedCategory: functionconfig.UnhealthyCategoryUnknown,
+		},
+		{
+			name:             "nothing",
+			expectedCategory: functionconfig.UnhealthyCategoryUnknown,
+		},
+	} {
+		suite.Run(testCase.name, func() {
+			suite.Require().Equal(testCase.expectedCategory, ClassifyUnhealthy(testCase.deployment, testCase.pods))
+		})
+	}
+}
+
+func (suite *unhealthyTestSuite) podWithContainerStatus(containerStatus v1.ContainerStatus) v1.Pod {
+	return v1.Pod{
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{containerStatus},
+		},
+	}
+}
+
+func TestUnhealthyTestSuite(t *testing.T) {
+	suite.Run(t, new(unhealthyTestSuite))
+}
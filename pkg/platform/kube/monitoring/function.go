@@ -1,6 +1,7 @@
 package monitoring
 
 import (
+	"fmt"
 	"runtime/debug"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/nuclio/nuclio/pkg/platform/kube"
 	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
 	nuclioioclient "github.com/nuclio/nuclio/pkg/platform/kube/client/clientset/versioned"
+	"github.com/nuclio/nuclio/pkg/platform/kube/functionres"
 
 	"github.com/nuclio/errors"
 	"github.com/nuclio/logger"
@@ -31,6 +33,9 @@ type FunctionMonitor struct {
 	interval                   time.Duration
 	stopChan                   chan struct{}
 	lastProvisioningTimestamps map[string]time.Time
+
+	// the state each function was last observed in, to count those becoming unhealthy once, whoever marked them
+	lastObservedStates map[string]functionconfig.FunctionState
 }
 
 func NewFunctionMonitor(parentLogger logger.Logger,
@@ -108,13 +113,38 @@ func (fm *FunctionMonitor) checkFunctionStatuses() error {
 	}
 
 	var errGroup errgroup.Group
-	for _, function := range functions.Items {
-		function := function
+	for functionIndex := range functions.Items {
+		function := &functions.Items[functionIndex]
 		errGroup.Go(func() error {
-			return fm.updateFunctionStatus(&function)
+			return fm.updateFunctionStatus(function)
 		})
 	}
-	return errGroup.Wait()
+	err = errGroup.Wait()
+
+	// functions are counted as unhealthy here, whether the controller or the monitor marked them so
+	fm.recordUnhealthyFunctions(functions.Items)
+
+	return err
+}
+
+// recordUnhealthyFunctions counts the functions that became unhealthy since last observed. those already unhealthy
+// when first observed (e.g. as the controller restarts) aren't counted
+func (fm *FunctionMonitor) recordUnhealthyFunctions(functions []nuclioio.NuclioFunction) {
+	lastObservedStates := map[string]functionconfig.FunctionState{}
+
+	for _, function := range functions {
+		functionKey := function.Namespace + "/" + function.Name
+		if fm.lastObservedStates != nil &&
+			function.Status.State == functionconfig.FunctionStateUnhealthy &&
+			fm.lastObservedStates[functionKey] != functionconfig.FunctionStateUnhealthy {
+			RecordFunctionUnhealthy(function.Status.UnhealthyCategory)
+		}
+
+		lastObservedStates[functionKey] = function.Status.State
+	}
+
+	// deleted functions are forgotten
+	fm.lastObservedStates = lastObservedStates
 }
 
 func (fm *FunctionMonitor) updateFunctionStatus(function *nuclioio.NuclioFunction) error {
@@ -144,10 +174,12 @@ func (fm *FunctionMonitor) updateFunctionStatus(function *nuclioio.NuclioFunctio
 	if functionIsAvailable && function.Status.State == functionconfig.FunctionStateUnhealthy {
 		function.Status.State = functionconfig.FunctionStateReady
 		function.Status.Message = ""
+		function.Status.UnhealthyCategory = ""
 		stateChanged = true
 	} else if !functionIsAvailable && function.Status.State == functionconfig.FunctionStateReady {
 		function.Status.State = functionconfig.FunctionStateUnhealthy
 		function.Status.Message = string(common.FunctionStateMessageUnhealthy)
+		function.Status.UnhealthyCategory = fm.getUnhealthyCategory(function, functionDeployment)
 		stateChanged = true
 	}
 
@@ -175,6 +207,21 @@ func (fm *FunctionMonitor) updateFunctionStatus(function *nuclioio.NuclioFunctio
 	return nil
 }
 
+func (fm *FunctionMonitor) getUnhealthyCategory(function *nuclioio.NuclioFunction,
+	functionDeployment *appsv1.Deployment) functionconfig.UnhealthyCategory {
+	pods, err := fm.kubeClientSet.CoreV1().Pods(function.Namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("nuclio.io/function-name=%s,!nuclio.io/function-cron-job-pod", function.Name),
+	})
+	if err != nil {
+		fm.logger.WarnWith("Failed to list function pods, classifying without them",
+			"functionName", function.Name,
+			"functionNamespace", function.Namespace)
+		return functionres.ClassifyUnhealthy(functionDeployment, nil)
+	}
+
+	return functionres.ClassifyUnhealthy(functionDeployment, pods.Items)
+}
+
 func (fm *FunctionMonitor) isAvailable(deployment *appsv1.Deployment) bool {
 
 	// require at least one replica
@@ -0,0 +1,102 @@
+// +build test_unit
+
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+
+	"github.com/nuclio/logger"
+	nucliozap "github.com/nuclio/zap"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type FunctionMonitorTestSuite struct {
+	suite.Suite
+	logger          logger.Logger
+	functionMonitor *FunctionMonitor
+}
+
+func (suite *FunctionMonitorTestSuite) SetupTest() {
+	var err error
+
+	suite.logger, err = nucliozap.NewNuclioZapTest("test")
+	suite.Require().NoError(err)
+
+	suite.functionMonitor, err = NewFunctionMonitor(suite.logger, "test-namespace", nil, nil, time.Minute)
+	suite.Require().NoError(err)
+}
+
+func (suite *FunctionMonitorTestSuite) TestRecordUnhealthyFunctionsOnce() {
+	category := functionconfig.UnhealthyCategoryImage
+	unhealthyCount := func() float64 {
+		return testutil.ToFloat64(functionUnhealthyTotal.WithLabelValues(string(category)))
+	}
+
+	initialCount := unhealthyCount()
+
+	// functions already unhealthy when first observed aren't counted
+	suite.functionMonitor.recordUnhealthyFunctions([]nuclioio.NuclioFunction{
+		suite.createFunction("already-unhealthy", functionconfig.FunctionStateUnhealthy, category),
+		suite.createFunction("my-function", functionconfig.FunctionStateReady, ""),
+	})
+	suite.Require().Equal(initialCount, unhealthyCount())
+
+	// becoming unhealthy is counted once, however many times it's observed
+	for observation := 0; observation < 3; observation++ {
+		suite.functionMonitor.recordUnhealthyFunctions([]nuclioio.NuclioFunction{
+			suite.createFunction("already-unhealthy", functionconfig.FunctionStateUnhealthy, category),
+			suite.createFunction("my-function", functionconfig.FunctionStateUnhealthy, category),
+		})
+	}
+	suite.Require().Equal(initialCount+1, unhealthyCount())
+
+	// recovering and becoming unhealthy again is counted again
+	suite.functionMonitor.recordUnhealthyFunctions([]nuclioio.NuclioFunction{
+		suite.createFunction("my-function", functionconfig.FunctionStateReady, ""),
+	})
+	suite.functionMonitor.recordUnhealthyFunctions([]nuclioio.NuclioFunction{
+		suite.createFunction("my-function", functionconfig.FunctionStateUnhealthy, category),
+	})
+	suite.Require().Equal(initialCount+2, unhealthyCount())
+}
+
+func (suite *FunctionMonitorTestSuite) createFunction(name string,
+	state functionconfig.FunctionState,
+	category functionconfig.UnhealthyCategory) nuclioio.NuclioFunction {
+	return nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test-namespace",
+		},
+		Status: functionconfig.Status{
+			State:             state,
+			UnhealthyCategory: category,
+		},
+	}
+}
+
+func TestFunctionMonitorTestSuite(t *testing.T) {
+	suite.Run(t, new(FunctionMonitorTestSuite))
+}
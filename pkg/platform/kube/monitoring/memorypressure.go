@@ -0,0 +1,251 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+	nuclioioclient "github.com/nuclio/nuclio/pkg/platform/kube/client/clientset/versioned"
+	"github.com/nuclio/nuclio/pkg/platform/kube/functionres"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// MemoryPressureMonitor restarts functions that opted in to it, when the memory usage of any of their pods stays
+// above a fraction of the memory limit for a sustained period. this replaces pods that leak memory before they're
+// OOMKilled
+type MemoryPressureMonitor struct {
+	logger            logger.Logger
+	namespace         string
+	nuclioClientSet   nuclioioclient.Interface
+	metricsClientSet  metricsclient.Interface
+	functionresClient functionres.Client
+	interval          time.Duration
+	stopChan          chan struct{}
+
+	// when functions were first found above their usage threshold, by namespace/name
+	memoryPressureSince map[string]time.Time
+}
+
+func NewMemoryPressureMonitor(parentLogger logger.Logger,
+	namespace string,
+	nuclioClientSet nuclioioclient.Interface,
+	metricsClientSet metricsclient.Interface,
+	functionresClient functionres.Client,
+	interval time.Duration) (*MemoryPressureMonitor, error) {
+
+	newMemoryPressureMonitor := &MemoryPressureMonitor{
+		logger:              parentLogger.GetChild("memory_pressure_monitor"),
+		namespace:           namespace,
+		nuclioClientSet:     nuclioClientSet,
+		metricsClientSet:    metricsClientSet,
+		functionresClient:   functionresClient,
+		interval:            interval,
+		memoryPressureSince: map[string]time.Time{},
+	}
+
+	newMemoryPressureMonitor.logger.DebugWith("Created memory pressure monitor",
+		"namespace", namespace,
+		"interval", interval)
+
+	return newMemoryPressureMonitor, nil
+}
+
+func (mpm *MemoryPressureMonitor) Start() error {
+	mpm.logger.InfoWith("Starting",
+		"namespace", mpm.namespace)
+
+	// create stop channel
+	mpm.stopChan = make(chan struct{}, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				callStack := debug.Stack()
+				mpm.logger.ErrorWith("Panic caught while monitoring memory pressure",
+					"err", err,
+					"stack", string(callStack))
+			}
+		}()
+		for {
+			select {
+			case <-time.After(mpm.interval):
+				if err := mpm.checkFunctionsMemoryPressure(context.Background()); err != nil {
+					mpm.logger.WarnWith("Failed to check functions memory pressure",
+						"namespace", mpm.namespace,
+						"err", errors.Cause(err))
+				}
+
+			case <-mpm.stopChan:
+				mpm.logger.DebugWith("Stopped memory pressure monitoring",
+					"namespace", mpm.namespace)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (mpm *MemoryPressureMonitor) Stop() {
+	mpm.logger.InfoWith("Stopping memory pressure monitoring", "namespace", mpm.namespace)
+
+	// post to channel
+	if mpm.stopChan != nil {
+		mpm.stopChan <- struct{}{}
+	}
+}
+
+func (mpm *MemoryPressureMonitor) checkFunctionsMemoryPressure(ctx context.Context) error {
+	functions, err := mpm.nuclioClientSet.NuclioV1beta1().NuclioFunctions(mpm.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list functions")
+	}
+
+	memoryPressureSince := map[string]time.Time{}
+
+	for _, function := range functions.Items {
+		function := function
+		if function.Spec.MemoryPressureRestart == nil ||
+			function.Status.State != functionconfig.FunctionStateReady {
+			continue
+		}
+
+		functionKey := function.Namespace + "/" + function.Name
+
+		underMemoryPressure, err := mpm.isUnderMemoryPressure(&function)
+		if err != nil {
+			mpm.logger.WarnWith("Failed to check function memory pressure, skipping",
+				"functionName", function.Name,
+				"functionNamespace", function.Namespace,
+				"err", errors.Cause(err))
+
+			// neither confirmed nor refuted, keep tracking as is
+			if since, found := mpm.memoryPressureSince[functionKey]; found {
+				memoryPressureSince[functionKey] = since
+			}
+			continue
+		}
+
+		if !underMemoryPressure {
+			continue
+		}
+
+		since, found := mpm.memoryPressureSince[functionKey]
+		if !found {
+			since = time.Now()
+		}
+
+		// validated during reconcile
+		sustainedPeriod, _ := function.Spec.MemoryPressureRestart.GetSustainedPeriod()
+		if time.Since(since) < sustainedPeriod {
+			memoryPressureSince[functionKey] = since
+			continue
+		}
+
+		if err := mpm.restartFunction(ctx, &function, since); err != nil {
+			mpm.logger.WarnWith("Failed to restart function under memory pressure",
+				"functionName", function.Name,
+				"functionNamespace", function.Namespace,
+				"err", errors.Cause(err))
+
+			// retry next pass
+			memoryPressureSince[functionKey] = since
+		}
+	}
+
+	// functions that are no longer under memory pressure (or were restarted) start over
+	mpm.memoryPressureSince = memoryPressureSince
+
+	return nil
+}
+
+// returns whether the memory usage of any of the function's pods is above the function's usage threshold
+func (mpm *MemoryPressureMonitor) isUnderMemoryPressure(function *nuclioio.NuclioFunction) (bool, error) {
+	memoryLimit := function.Spec.Resources.Limits.Memory()
+	if memoryLimit.IsZero() {
+		return false, nil
+	}
+
+	// validated during reconcile
+	usageThreshold, _ := function.Spec.MemoryPressureRestart.GetUsageThreshold()
+	thresholdBytes := int64(float64(memoryLimit.Value()) * usageThreshold)
+
+	podMetricsList, err := mpm.metricsClientSet.MetricsV1beta1().
+		PodMetricses(function.Namespace).
+		List(metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("nuclio.io/function-name=%s,!nuclio.io/function-cron-job-pod", function.Name),
+		})
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to list function pod metrics")
+	}
+
+	for _, podMetrics := range podMetricsList.Items {
+		for _, containerMetrics := range podMetrics.Containers {
+
+			// the limit applies to the function's container
+			if containerMetrics.Name != "nuclio" {
+				continue
+			}
+
+			if containerMetrics.Usage.Memory().Value() > thresholdBytes {
+				mpm.logger.DebugWith("Function pod is above memory usage threshold",
+					"functionName", function.Name,
+					"podName", podMetrics.Name,
+					"memoryUsage", containerMetrics.Usage.Memory().String(),
+					"thresholdBytes", thresholdBytes)
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (mpm *MemoryPressureMonitor) restartFunction(ctx context.Context,
+	function *nuclioio.NuclioFunction,
+	memoryPressureSince time.Time) error {
+
+	reason := fmt.Sprintf("Memory usage above %s of the memory limit since %s",
+		mpm.formatUsageThreshold(function),
+		memoryPressureSince.Format(time.RFC3339))
+
+	mpm.logger.InfoWith("Restarting function under memory pressure",
+		"functionName", function.Name,
+		"functionNamespace", function.Namespace,
+		"reason", reason)
+
+	if err := mpm.functionresClient.Restart(ctx, function); err != nil {
+		return errors.Wrap(err, "Failed to restart function")
+	}
+
+	functionMemoryPressureRestartsTotal.Inc()
+
+	function.Status.LastRestart = &functionconfig.RestartStatus{
+		Time:   time.Now(),
+		Reason: reason,
+	}
+
+	if _, err := mpm.nuclioClientSet.
+		NuclioV1beta1().
+		NuclioFunctions(function.Namespace).
+		Update(function); err != nil {
+		mpm.logger.WarnWith("Failed to record function restart",
+			"functionName", function.Name,
+			"functionNamespace", function.Namespace,
+			"err", err)
+	}
+
+	return nil
+}
+
+func (mpm *MemoryPressureMonitor) formatUsageThreshold(function *nuclioio.NuclioFunction) string {
+	usageThreshold, _ := function.Spec.MemoryPressureRestart.GetUsageThreshold()
+	return fmt.Sprintf("%.0f%%", usageThreshold*100)
+}
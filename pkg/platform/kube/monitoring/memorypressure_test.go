@@ -0,0 +1,213 @@
+// +build test_unit
+
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+	"github.com/nuclio/nuclio/pkg/platform/kube/client/clientset/mocks"
+	"github.com/nuclio/nuclio/pkg/platform/kube/functionres"
+
+	"github.com/nuclio/logger"
+	nucliozap "github.com/nuclio/zap"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+type MemoryPressureTestSuite struct {
+	suite.Suite
+	logger                      logger.Logger
+	namespace                   string
+	nuclioFunctionInterfaceMock *mocks.NuclioFunctionInterface
+	functionresClientMock       *functionres.MockedFunctionRes
+	memoryPressureMonitor       *MemoryPressureMonitor
+
+	// the memory usage of the function's pod, as reported by the metrics API
+	memoryUsage string
+}
+
+func (suite *MemoryPressureTestSuite) SetupTest() {
+	var err error
+
+	suite.logger, err = nucliozap.NewNuclioZapTest("test")
+	suite.Require().NoError(err)
+
+	suite.namespace = "test-namespace"
+
+	nuclioioInterfaceMock := &mocks.Interface{}
+	nuclioioV1beta1InterfaceMock := &mocks.NuclioV1beta1Interface{}
+	suite.nuclioFunctionInterfaceMock = &mocks.NuclioFunctionInterface{}
+	nuclioioInterfaceMock.On("NuclioV1beta1").Return(nuclioioV1beta1InterfaceMock)
+	nuclioioV1beta1InterfaceMock.On("NuclioFunctions", suite.namespace).Return(suite.nuclioFunctionInterfaceMock)
+
+	metricsClientSet := metricsfake.NewSimpleClientset()
+	metricsClientSet.PrependReactor("list", "pods",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &metricsv1beta1.PodMetricsList{
+				Items: []metricsv1beta1.PodMetrics{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "my-function-pod",
+							Namespace: suite.namespace,
+							Labels: map[string]string{
+								"nuclio.io/function-name": "my-function",
+							},
+						},
+						Containers: []metricsv1beta1.ContainerMetrics{
+							{
+								Name: "nuclio",
+								Usage: v1.ResourceList{
+									v1.ResourceMemory: resource.MustParse(suite.memoryUsage),
+								},
+							},
+						},
+					},
+				},
+			}, nil
+		})
+
+	suite.functionresClientMock = &functionres.MockedFunctionRes{}
+
+	suite.memoryPressureMonitor, err = NewMemoryPressureMonitor(suite.logger,
+		suite.namespace,
+		nuclioioInterfaceMock,
+		metricsClientSet,
+		suite.functionresClientMock,
+		time.Minute)
+	suite.Require().NoError(err)
+}
+
+func (suite *MemoryPressureTestSuite) TestRestartOnSustainedMemoryPressure() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: suite.namespace,
+		},
+		Spec: functionconfig.Spec{
+			Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					v1.ResourceMemory: resource.MustParse("100Mi"),
+				},
+			},
+			MemoryPressureRestart: &functionconfig.MemoryPressureRestartSpec{
+				UsageThreshold:  0.9,
+				SustainedPeriod: "200ms",
+			},
+		},
+		Status: functionconfig.Status{
+			State: functionconfig.FunctionStateReady,
+		},
+	}
+
+	suite.nuclioFunctionInterfaceMock.
+		On("List", metav1.ListOptions{}).
+		Return(&nuclioio.NuclioFunctionList{Items: []nuclioio.NuclioFunction{functionInstance}}, nil)
+
+	var restartedFunction *nuclioio.NuclioFunction
+	suite.functionresClientMock.
+		On("Restart", mock.Anything, mock.Anything).
+		Return(nil).
+		Once()
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", mock.Anything).
+		Run(func(args mock.Arguments) {
+			restartedFunction = args.Get(0).(*nuclioio.NuclioFunction)
+		}).
+		Return(nil, nil).
+		Once()
+
+	// above the threshold, but not for long enough
+	suite.memoryUsage = "95Mi"
+	suite.checkFunctionsMemoryPressure()
+	suite.Require().Contains(suite.memoryPressureMonitor.memoryPressureSince, "test-namespace/my-function")
+
+	// dropping under the threshold starts over
+	suite.memoryUsage = "50Mi"
+	suite.checkFunctionsMemoryPressure()
+	suite.Require().Empty(suite.memoryPressureMonitor.memoryPressureSince)
+
+	suite.memoryUsage = "95Mi"
+	suite.checkFunctionsMemoryPressure()
+	time.Sleep(100 * time.Millisecond)
+	suite.checkFunctionsMemoryPressure()
+	suite.functionresClientMock.AssertNotCalled(suite.T(), "Restart", mock.Anything, mock.Anything)
+
+	// sustained past the period - the function is restarted, with the reason recorded in its status
+	time.Sleep(200 * time.Millisecond)
+	suite.checkFunctionsMemoryPressure()
+	suite.functionresClientMock.AssertNumberOfCalls(suite.T(), "Restart", 1)
+	suite.Require().NotNil(restartedFunction)
+	suite.Require().NotNil(restartedFunction.Status.LastRestart)
+	suite.Require().Contains(restartedFunction.Status.LastRestart.Reason, "Memory usage above 90% of the memory limit")
+	suite.Require().Empty(suite.memoryPressureMonitor.memoryPressureSince)
+
+	// the restarted function is given the full period again
+	suite.checkFunctionsMemoryPressure()
+	suite.checkFunctionsMemoryPressure()
+	suite.functionresClientMock.AssertNumberOfCalls(suite.T(), "Restart", 1)
+	suite.nuclioFunctionInterfaceMock.AssertNumberOfCalls(suite.T(), "Update", 1)
+}
+
+func (suite *MemoryPressureTestSuite) TestIgnoreFunctionsNotOptedIn() {
+	functionInstance := nuclioio.NuclioFunction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-function",
+			Namespace: suite.namespace,
+		},
+		Spec: functionconfig.Spec{
+			Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					v1.ResourceMemory: resource.MustParse("100Mi"),
+				},
+			},
+		},
+		Status: functionconfig.Status{
+			State: functionconfig.FunctionStateReady,
+		},
+	}
+
+	suite.nuclioFunctionInterfaceMock.
+		On("List", metav1.ListOptions{}).
+		Return(&nuclioio.NuclioFunctionList{Items: []nuclioio.NuclioFunction{functionInstance}}, nil)
+
+	suite.memoryUsage = "99Mi"
+	suite.checkFunctionsMemoryPressure()
+	suite.Require().Empty(suite.memoryPressureMonitor.memoryPressureSince)
+	suite.functionresClientMock.AssertNotCalled(suite.T(), "Restart", mock.Anything, mock.Anything)
+}
+
+func (suite *MemoryPressureTestSuite) checkFunctionsMemoryPressure() {
+	err := suite.memoryPressureMonitor.checkFunctionsMemoryPressure(context.Background())
+	suite.Require().NoError(err)
+}
+
+func TestMemoryPressureTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryPressureTestSuite))
+}
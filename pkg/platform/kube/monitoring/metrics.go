@@ -0,0 +1,33 @@
+package monitoring
+
+import (
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// counts functions becoming unhealthy, by the category of the failure
+var functionUnhealthyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "nuclio_controller_function_unhealthy_total",
+	Help: "Total number of times functions became unhealthy, by failure category",
+}, []string{"category"})
+
+// counts functions restarted due to sustained memory pressure
+var functionMemoryPressureRestartsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "nuclio_controller_function_memory_pressure_restarts_total",
+	Help: "Total number of times functions were restarted due to sustained memory pressure",
+})
+
+func init() {
+	prometheus.MustRegister(functionUnhealthyTotal)
+	prometheus.MustRegister(functionMemoryPressureRestartsTotal)
+}
+
+// RecordFunctionUnhealthy counts a function becoming unhealthy
+func RecordFunctionUnhealthy(category functionconfig.UnhealthyCategory) {
+	if category == "" {
+		category = functionconfig.UnhealthyCategoryUnknown
+	}
+
+	functionUnhealthyTotal.WithLabelValues(string(category)).Inc()
+}
@@ -132,6 +132,10 @@ func (mw *MultiWorker) Stop() chan struct{} {
 	return nil
 }
 
+func (mw *MultiWorker) HasSynced() bool {
+	return mw.informer.HasSynced()
+}
+
 func (mw *MultiWorker) processItems() {
 	for {
 
@@ -150,7 +154,14 @@ func (mw *MultiWorker) processItems() {
 
 		// try to process the item
 		err := mw.processItem(itemKey)
-		if err != nil {
+		if errors.RootCause(err) == ErrCacheNotSynced {
+			mw.logger.DebugWith("Cache not synced yet, requeueing", "itemKey", itemKey)
+
+			// not a failure - retry later, from a clean slate
+			mw.queue.Forget(itemKey)
+			mw.queue.AddAfter(itemKey, cacheNotSyncedRequeueDelay)
+
+		} else if err != nil {
 			mw.logger.WarnWith("Failed to process item", "itemKey", itemKey, "err", errors.Cause(err))
 
 			// do we have any more retries?
@@ -24,4 +24,7 @@ type Operator interface {
 
 	// Stop stops the operator, returning a completion channel
 	Stop() chan struct{}
+
+	// HasSynced returns whether the operator's cache has synced with the API since it was started
+	HasSynced() bool
 }
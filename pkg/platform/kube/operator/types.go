@@ -18,10 +18,19 @@ package operator
 
 import (
 	"context"
+	"time"
 
+	"github.com/nuclio/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// ErrCacheNotSynced is returned by change handlers that refuse to act on an object until the caches they rely on
+// have synced. the object is requeued, without counting towards its processing retries
+var ErrCacheNotSynced = errors.New("Cache not synced yet")
+
+// how long to wait before processing an object again, after it was refused since caches haven't synced
+const cacheNotSyncedRequeueDelay = time.Second
+
 // ChangeHandler handles changes to object
 type ChangeHandler interface {
 
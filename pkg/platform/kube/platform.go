@@ -96,7 +96,7 @@ func NewPlatform(parentLogger logger.Logger,
 	}
 
 	// create updater
-	newPlatform.updater, err = newUpdater(newPlatform.Logger, newPlatform.consumer, newPlatform)
+	newPlatform.updater, err = newUpdater(newPlatform.Logger, newPlatform.consumer, newPlatform, platformConfiguration)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to create an updater")
 	}
@@ -1055,7 +1055,8 @@ func (p *Platform) setScaleToZeroSpec(functionSpec *functionconfig.Spec) error {
 
 	if p.Config.ScaleToZero.Mode == platformconfig.EnabledScaleToZeroMode {
 		functionSpec.ScaleToZero = &functionconfig.ScaleToZeroSpec{
-			ScaleResources: p.Config.ScaleToZero.ScaleResources,
+			ScaleResources:               p.Config.ScaleToZero.ScaleResources,
+			ScaleDownStabilizationWindow: p.Config.ScaleToZero.ScaleDownStabilizationWindow,
 		}
 	}
 
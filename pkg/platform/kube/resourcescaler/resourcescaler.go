@@ -2,6 +2,7 @@ package resourcescaler
 
 import (
 	"os"
+	"sync"
 	"time"
 
 	"github.com/nuclio/nuclio/pkg/functionconfig"
@@ -27,6 +28,20 @@ type NuclioResourceScaler struct {
 	kubeconfigPath        string
 	namespace             string
 	platformConfiguration *platformconfig.Config
+
+	// the latest scale event of each function (by namespace/name), being processed or processed
+	scaleEventsLock sync.Mutex
+	scaleEvents     map[string]*scaleEventRecord
+}
+
+type scaleEventRecord struct {
+	event scaler_types.ScaleEvent
+
+	// closed once processed, after which the rest is set
+	done                chan struct{}
+	err                 error
+	processedTime       time.Time
+	deduplicationWindow time.Duration
 }
 
 // New is called when plugin loaded on scaler, so it's considered "dead code" for the linter
@@ -70,6 +85,7 @@ func New(kubeconfigPath string, namespace string) (scaler_types.ResourceScaler,
 		kubeconfigPath:        kubeconfigPath,
 		namespace:             namespace,
 		platformConfiguration: platformConfiguration,
+		scaleEvents:           map[string]*scaleEventRecord{},
 	}, nil
 }
 
@@ -101,6 +117,16 @@ func (n *NuclioResourceScaler) GetResources() ([]scaler_types.Resource, error) {
 				continue
 			}
 
+			// don't scale down functions that were scaled up too recently
+			if function.Status.ScaleToZero != nil &&
+				function.Status.ScaleToZero.ScaleDownStabilizedUntil != nil &&
+				time.Now().Before(*function.Status.ScaleToZero.ScaleDownStabilizedUntil) {
+				n.logger.DebugWith("Function scale down is stabilizing. Continuing",
+					"functionName", function.Name,
+					"stabilizedUntil", function.Status.ScaleToZero.ScaleDownStabilizedUntil)
+				continue
+			}
+
 			scaleResources, err := n.parseScaleResources(function)
 			if err != nil {
 				n.logger.WarnWith("Failed to parse scale resources. Continuing", "functionName", function.Name)
@@ -172,6 +198,7 @@ func (n *NuclioResourceScaler) ResolveServiceName(resource scaler_types.Resource
 
 func (n *NuclioResourceScaler) parseScaleResources(function nuclioio.NuclioFunction) ([]scaler_types.ScaleResource, error) {
 	var scaleResources []scaler_types.ScaleResource
+
 	for _, scaleResource := range function.Spec.ScaleToZero.ScaleResources {
 		windowSize, err := time.ParseDuration(scaleResource.WindowSize)
 		if err != nil {
@@ -202,10 +229,13 @@ func (n *NuclioResourceScaler) scaleFunctionsToZero(namespace string, functionNa
 	n.logger.DebugWith("Scaling to zero", "functionNames", functionNames)
 	failedFunctionNames := make([]string, 0)
 	for _, functionName := range functionNames {
-		err := n.updateFunctionStatus(namespace,
-			functionName,
-			functionconfig.FunctionStateWaitingForScaleResourcesToZero,
-			scaler_types.ScaleToZeroStartedScaleEvent)
+		functionName := functionName
+		err := n.processScaleEvent(namespace, functionName, scaler_types.ScaleToZeroStartedScaleEvent, func() error {
+			return n.updateFunctionStatus(namespace,
+				functionName,
+				functionconfig.FunctionStateWaitingForScaleResourcesToZero,
+				scaler_types.ScaleToZeroStartedScaleEvent)
+		})
 		if err != nil {
 			failedFunctionNames = append(failedFunctionNames, functionName)
 			n.logger.WarnWith("Failed to update function status to scale to zero", "functionName", functionName)
@@ -223,18 +253,25 @@ func (n *NuclioResourceScaler) scaleFunctionsFromZero(namespace string, function
 	n.logger.DebugWith("Scaling from zero", "functionNames", functionNames)
 	failedFunctionNames := make([]string, 0)
 	for _, functionName := range functionNames {
-		err := n.updateFunctionStatus(namespace,
-			functionName,
-			functionconfig.FunctionStateWaitingForScaleResourcesFromZero,
-			scaler_types.ScaleFromZeroStartedScaleEvent)
+		functionName := functionName
+		err := n.processScaleEvent(namespace, functionName, scaler_types.ScaleFromZeroStartedScaleEvent, func() error {
+			if err := n.updateFunctionStatus(namespace,
+				functionName,
+				functionconfig.FunctionStateWaitingForScaleResourcesFromZero,
+				scaler_types.ScaleFromZeroStartedScaleEvent); err != nil {
+				n.logger.WarnWith("Failed to update function status to scale from zero", "functionName", functionName)
+				return err
+			}
+
+			if err := n.waitFunctionReadiness(namespace, functionName); err != nil {
+				n.logger.WarnWith("Failed waiting for function readiness", "functionName", functionName)
+				return err
+			}
+
+			return nil
+		})
 		if err != nil {
 			failedFunctionNames = append(failedFunctionNames, functionName)
-			n.logger.WarnWith("Failed to update function status to scale from zero", "functionName", functionName)
-			continue
-		}
-		if err := n.waitFunctionReadiness(namespace, functionName); err != nil {
-			failedFunctionNames = append(failedFunctionNames, functionName)
-			n.logger.WarnWith("Failed waiting for function readiness", "functionName", functionName)
 			continue
 		}
 	}
@@ -244,6 +281,72 @@ func (n *NuclioResourceScaler) scaleFunctionsFromZero(namespace string, function
 	return nil
 }
 
+// processes the function's scale event, unless an identical one is already being processed (in which case its
+// result is waited for) or was successfully processed within the function's deduplication window. this spares
+// bursts of identical events from redundant reconciles and conflicting status updates
+func (n *NuclioResourceScaler) processScaleEvent(namespace string,
+	functionName string,
+	scaleEvent scaler_types.ScaleEvent,
+	process func() error) error {
+	functionKey := namespace + "/" + functionName
+
+	n.scaleEventsLock.Lock()
+	if scaleEventRecord, found := n.scaleEvents[functionKey]; found && scaleEventRecord.event == scaleEvent {
+		select {
+		case <-scaleEventRecord.done:
+			if scaleEventRecord.err == nil &&
+				time.Since(scaleEventRecord.processedTime) < scaleEventRecord.deduplicationWindow {
+				n.scaleEventsLock.Unlock()
+				n.logger.DebugWith("Ignoring scale event identical to one recently processed",
+					"functionName", functionName,
+					"scaleEvent", scaleEvent,
+					"processedTime", scaleEventRecord.processedTime)
+				return nil
+			}
+		default:
+			n.scaleEventsLock.Unlock()
+			n.logger.DebugWith("Identical scale event is being processed, waiting for it",
+				"functionName", functionName,
+				"scaleEvent", scaleEvent)
+			<-scaleEventRecord.done
+			return scaleEventRecord.err
+		}
+	}
+
+	scaleEventRecord := &scaleEventRecord{
+		event: scaleEvent,
+		done:  make(chan struct{}),
+	}
+	n.scaleEvents[functionKey] = scaleEventRecord
+	n.scaleEventsLock.Unlock()
+
+	scaleEventRecord.err = process()
+	scaleEventRecord.processedTime = time.Now()
+	if scaleEventRecord.err == nil {
+		scaleEventRecord.deduplicationWindow = n.getScaleEventDeduplicationWindow(namespace, functionName)
+	}
+	close(scaleEventRecord.done)
+
+	return scaleEventRecord.err
+}
+
+func (n *NuclioResourceScaler) getScaleEventDeduplicationWindow(namespace string, functionName string) time.Duration {
+	function, err := n.nuclioClientSet.NuclioV1beta1().NuclioFunctions(namespace).Get(functionName, metav1.GetOptions{})
+	if err != nil {
+		n.logger.WarnWith("Failed getting nuclio function, not deduplicating its scale event",
+			"functionName", functionName,
+			"err", err)
+		return 0
+	}
+
+	scaleEventDeduplicationWindow, err := function.Spec.ScaleToZero.GetScaleEventDeduplicationWindow()
+	if err != nil {
+		return 0
+	}
+
+	return scaleEventDeduplicationWindow
+}
+
 func (n *NuclioResourceScaler) updateFunctionStatus(namespace string,
 	functionName string,
 	functionState functionconfig.FunctionState,
@@ -255,11 +358,17 @@ func (n *NuclioResourceScaler) updateFunctionStatus(namespace string,
 	}
 
 	now := time.Now()
-	function.Status.State = functionState
-	function.Status.ScaleToZero = &functionconfig.ScaleToZeroStatus{
+	functionStatus := function.Status
+	functionStatus.State = functionState
+	functionStatus.ScaleToZero = &functionconfig.ScaleToZeroStatus{
 		LastScaleEvent:     functionScaleEvent,
 		LastScaleEventTime: &now,
 	}
+
+	statusCompaction := n.platformConfiguration.Kube.StatusCompaction
+	functionStatus.RecordStateTransition(&function.Status, now)
+	functionStatus.Compact(statusCompaction.MaxHistoryLength, statusCompaction.MaxStatusSize)
+	function.Status = functionStatus
 	_, err = n.nuclioClientSet.NuclioV1beta1().NuclioFunctions(namespace).Update(function)
 	if err != nil {
 		n.logger.WarnWith("Failed to update function", "functionName", functionName, "err", err)
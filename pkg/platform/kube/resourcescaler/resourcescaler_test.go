@@ -0,0 +1,133 @@
+// +build test_unit
+
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcescaler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+	nuclioio "github.com/nuclio/nuclio/pkg/platform/kube/apis/nuclio.io/v1beta1"
+	"github.com/nuclio/nuclio/pkg/platform/kube/client/clientset/mocks"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/nuclio/logger"
+	"github.com/nuclio/zap"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/v3io/scaler-types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ResourceScalerTestSuite struct {
+	suite.Suite
+	logger                        logger.Logger
+	namespace                     string
+	resourceScaler                *NuclioResourceScaler
+	nuclioFunctionInterfaceMock   *mocks.NuclioFunctionInterface
+	scaleEventDeduplicationWindow string
+}
+
+func (suite *ResourceScalerTestSuite) SetupTest() {
+	var err error
+
+	suite.namespace = "default"
+	suite.scaleEventDeduplicationWindow = ""
+	suite.logger, err = nucliozap.NewNuclioZapTest("test")
+	suite.Require().NoError(err)
+
+	nuclioioInterfaceMock := &mocks.Interface{}
+	nuclioioV1beta1InterfaceMock := &mocks.NuclioV1beta1Interface{}
+	suite.nuclioFunctionInterfaceMock = &mocks.NuclioFunctionInterface{}
+
+	nuclioioInterfaceMock.
+		On("NuclioV1beta1").
+		Return(nuclioioV1beta1InterfaceMock)
+
+	nuclioioV1beta1InterfaceMock.
+		On("NuclioFunctions", suite.namespace).
+		Return(suite.nuclioFunctionInterfaceMock)
+
+	// the function is ready as soon as it's asked for
+	suite.nuclioFunctionInterfaceMock.
+		On("Get", "func-name", mock.Anything).
+		Return(func(name string, options metav1.GetOptions) *nuclioio.NuclioFunction {
+			function := &nuclioio.NuclioFunction{}
+			function.Name = name
+			function.Spec.ScaleToZero = &functionconfig.ScaleToZeroSpec{
+				ScaleEventDeduplicationWindow: suite.scaleEventDeduplicationWindow,
+			}
+			function.Status.State = functionconfig.FunctionStateReady
+			return function
+		}, nil)
+
+	// slow enough for scale events to pile up
+	suite.nuclioFunctionInterfaceMock.
+		On("Update", mock.Anything).
+		Run(func(args mock.Arguments) {
+			time.Sleep(100 * time.Millisecond)
+		}).
+		Return(nil, nil)
+
+	suite.resourceScaler = &NuclioResourceScaler{
+		logger:                suite.logger,
+		nuclioClientSet:       nuclioioInterfaceMock,
+		namespace:             suite.namespace,
+		scaleEvents:           map[string]*scaleEventRecord{},
+		platformConfiguration: &platformconfig.Config{},
+	}
+}
+
+func (suite *ResourceScalerTestSuite) TestScaleEventDeduplication() {
+	resources := []scaler_types.Resource{{Name: "func-name"}}
+
+	// a burst of scale from zero events is processed once
+	waitGroup := sync.WaitGroup{}
+	for eventIndex := 0; eventIndex < 5; eventIndex++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			suite.Require().NoError(suite.resourceScaler.SetScale(resources, 1))
+		}()
+	}
+	waitGroup.Wait()
+	suite.nuclioFunctionInterfaceMock.AssertNumberOfCalls(suite.T(), "Update", 1)
+
+	// as are identical events shortly after
+	suite.Require().NoError(suite.resourceScaler.SetScale(resources, 1))
+	suite.nuclioFunctionInterfaceMock.AssertNumberOfCalls(suite.T(), "Update", 1)
+
+	// other events are processed
+	suite.Require().NoError(suite.resourceScaler.SetScale(resources, 0))
+	suite.nuclioFunctionInterfaceMock.AssertNumberOfCalls(suite.T(), "Update", 2)
+
+	suite.Require().NoError(suite.resourceScaler.SetScale(resources, 1))
+	suite.nuclioFunctionInterfaceMock.AssertNumberOfCalls(suite.T(), "Update", 3)
+
+	// unless deduplication is disabled, identical events are all processed
+	suite.scaleEventDeduplicationWindow = "0"
+	suite.Require().NoError(suite.resourceScaler.SetScale(resources, 0))
+	suite.Require().NoError(suite.resourceScaler.SetScale(resources, 0))
+	suite.nuclioFunctionInterfaceMock.AssertNumberOfCalls(suite.T(), "Update", 5)
+}
+
+func TestResourceScalerTestSuite(t *testing.T) {
+	suite.Run(t, new(ResourceScalerTestSuite))
+}
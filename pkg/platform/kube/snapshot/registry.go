@@ -0,0 +1,52 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+	"github.com/nuclio/nuclio/pkg/registry"
+
+	"github.com/nuclio/logger"
+)
+
+// Creator creates a snapshot sink
+type Creator interface {
+
+	// Create creates a snapshot sink
+	Create(logger.Logger, *platformconfig.FunctionSnapshot) (Sink, error)
+}
+
+type Registry struct {
+	registry.Registry
+}
+
+// global singleton
+var RegistrySingleton = Registry{
+	Registry: *registry.NewRegistry("snapshot"),
+}
+
+// NewSink creates a new snapshot sink by its kind
+func (r *Registry) NewSink(parentLogger logger.Logger,
+	functionSnapshotConfiguration *platformconfig.FunctionSnapshot) (Sink, error) {
+
+	registree, err := r.Get(functionSnapshotConfiguration.Kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return registree.(Creator).Create(parentLogger, functionSnapshotConfiguration)
+}
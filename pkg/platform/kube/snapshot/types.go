@@ -0,0 +1,52 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/functionconfig"
+)
+
+// Sink archives the state of functions before they're deleted
+type Sink interface {
+
+	// Snapshot archives the function's state
+	Snapshot(context.Context, *FunctionSnapshot) error
+}
+
+// FunctionSnapshot holds the final state of a function, as observed right before its resources were deleted
+type FunctionSnapshot struct {
+	Namespace   string            `json:"namespace"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// the function's spec and status, as last recorded
+	Spec   functionconfig.Spec   `json:"spec"`
+	Status functionconfig.Status `json:"status"`
+
+	// the replicas of the function's deployment, if it was deployed
+	Replicas          int32 `json:"replicas"`
+	AvailableReplicas int32 `json:"availableReplicas"`
+
+	// recent logs of the function's pods, by pod name
+	Logs map[string]string `json:"logs,omitempty"`
+
+	Time time.Time `json:"time"`
+}
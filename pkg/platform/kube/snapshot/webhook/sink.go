@@ -0,0 +1,83 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nuclio/nuclio/pkg/platform/kube/snapshot"
+
+	"github.com/nuclio/errors"
+	"github.com/nuclio/logger"
+)
+
+// posts snapshots, as JSON, to an archiving service
+type sink struct {
+	logger        logger.Logger
+	configuration *Configuration
+	httpClient    *http.Client
+}
+
+func newSink(parentLogger logger.Logger, configuration *Configuration) (*sink, error) {
+	return &sink{
+		logger:        parentLogger.GetChild("webhook"),
+		configuration: configuration,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+func (s *sink) Snapshot(ctx context.Context, functionSnapshot *snapshot.FunctionSnapshot) error {
+	body, err := json.Marshal(functionSnapshot)
+	if err != nil {
+		return errors.Wrap(err, "Failed to marshal snapshot")
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.configuration.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "Failed to create request")
+	}
+
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	for headerName, headerValue := range s.configuration.Headers {
+		request.Header.Set(headerName, headerValue)
+	}
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "Failed to send request")
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.New(fmt.Sprintf("Got unexpected status code: %d", response.StatusCode))
+	}
+
+	s.logger.DebugWith("Posted function snapshot",
+		"namespace", functionSnapshot.Namespace,
+		"name", functionSnapshot.Name)
+
+	return nil
+}
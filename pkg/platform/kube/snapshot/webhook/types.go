@@ -0,0 +1,48 @@
+/*
+Copyright 2017 The Nuclio Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"github.com/nuclio/nuclio/pkg/platformconfig"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuclio/errors"
+)
+
+type Configuration struct {
+	platformconfig.FunctionSnapshot
+
+	// headers to send with each snapshot (e.g. for authentication)
+	Headers map[string]string
+}
+
+func NewConfiguration(functionSnapshotConfiguration *platformconfig.FunctionSnapshot) (*Configuration, error) {
+	newConfiguration := Configuration{
+		FunctionSnapshot: *functionSnapshotConfiguration,
+	}
+
+	// parse attributes
+	if err := mapstructure.Decode(newConfiguration.Attributes, &newConfiguration); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode attributes")
+	}
+
+	if newConfiguration.URL == "" {
+		return nil, errors.New("Webhook snapshot sink requires a URL")
+	}
+
+	return &newConfiguration, nil
+}
@@ -54,6 +54,7 @@ import (
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	kubeapierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -504,8 +505,14 @@ func (suite *KubeTestSuite) createController() *controller.Controller {
 	nuclioClientSet, err := nuclioioclient.NewForConfig(restConfig)
 	suite.Require().NoError(err)
 
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	suite.Require().NoError(err)
+
 	// create a client for function deployments
-	functionresClient, err := functionres.NewLazyClient(suite.Logger, suite.KubeClientSet, nuclioClientSet)
+	functionresClient, err := functionres.NewLazyClient(suite.Logger,
+		suite.KubeClientSet,
+		nuclioClientSet,
+		dynamicClient)
 	suite.Require().NoError(err)
 
 	// create ingress manager
@@ -524,6 +531,8 @@ func (suite *KubeTestSuite) createController() *controller.Controller {
 		"",
 		suite.KubeClientSet,
 		nuclioClientSet,
+		nil,
+		dynamicClient,
 		functionresClient,
 		apigatewayresClient,
 		time.Second*5,  // resync interval
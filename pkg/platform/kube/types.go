@@ -61,6 +61,10 @@ func ServiceNameFromFunctionName(functionName string) string {
 	return fmt.Sprintf("nuclio-%s", functionName)
 }
 
+func ExternalSecretNameFromFunctionName(functionName string, secretName string) string {
+	return fmt.Sprintf("nuclio-%s-%s", functionName, secretName)
+}
+
 func CronJobName() string {
 	return fmt.Sprintf("nuclio-cron-job-%s", xid.New().String())
 }
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/nuclio/nuclio/pkg/platform"
+	"github.com/nuclio/nuclio/pkg/platformconfig"
 
 	"github.com/nuclio/errors"
 	"github.com/nuclio/logger"
@@ -28,16 +29,21 @@ import (
 )
 
 type updater struct {
-	logger   logger.Logger
-	platform platform.Platform
-	consumer *consumer
+	logger                logger.Logger
+	platform              platform.Platform
+	consumer              *consumer
+	platformConfiguration *platformconfig.Config
 }
 
-func newUpdater(parentLogger logger.Logger, consumer *consumer, platform platform.Platform) (*updater, error) {
+func newUpdater(parentLogger logger.Logger,
+	consumer *consumer,
+	platform platform.Platform,
+	platformConfiguration *platformconfig.Config) (*updater, error) {
 	newupdater := &updater{
-		logger:   parentLogger.GetChild("updater"),
-		platform: platform,
-		consumer: consumer,
+		logger:                parentLogger.GetChild("updater"),
+		platform:              platform,
+		consumer:              consumer,
+		platformConfiguration: platformConfiguration,
 	}
 
 	return newupdater, nil
@@ -66,7 +72,11 @@ func (u *updater) update(updateFunctionOptions *platform.UpdateFunctionOptions)
 
 	// update it with status if passed
 	if updateFunctionOptions.FunctionStatus != nil {
-		function.Status = *updateFunctionOptions.FunctionStatus
+		functionStatus := *updateFunctionOptions.FunctionStatus
+		statusCompaction := u.platformConfiguration.Kube.StatusCompaction
+		functionStatus.RecordStateTransition(&function.Status, time.Now())
+		functionStatus.Compact(statusCompaction.MaxHistoryLength, statusCompaction.MaxStatusSize)
+		function.Status = functionStatus
 	}
 
 	// update annotations
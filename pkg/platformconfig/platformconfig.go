@@ -38,6 +38,9 @@ type Config struct {
 	IngressConfig            IngressConfig                `json:"ingressConfig,omitempty"`
 	Kube                     PlatformKubeConfig           `json:"kube,omitempty"`
 	ImageRegistryOverrides   ImageRegistryOverridesConfig `json:"imageRegistryOverrides,omitempty"`
+	ServiceDiscovery         ServiceDiscovery             `json:"serviceDiscovery,omitempty"`
+	FunctionStateWebhook     FunctionStateWebhook         `json:"functionStateWebhook,omitempty"`
+	FunctionSnapshot         FunctionSnapshot             `json:"functionSnapshot,omitempty"`
 
 	ContainerBuilderConfiguration *containerimagebuilderpusher.ContainerBuilderConfiguration `json:"containerBuilderConfiguration,omitempty"`
 }
@@ -71,6 +74,10 @@ func NewPlatformConfig(configurationPath string) (*Config, error) {
 		config.Kube.DefaultServiceType = DefaultServiceType
 	}
 
+	if config.IngressConfig.RegionsAnnotation == "" {
+		config.IngressConfig.RegionsAnnotation = DefaultRegionsAnnotation
+	}
+
 	return config, nil
 }
 
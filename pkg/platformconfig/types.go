@@ -69,6 +69,9 @@ type ScaleToZero struct {
 	ResourceReadinessTimeout string                         `json:"resourceReadinessTimeout,omitempty"`
 	ScaleResources           []functionconfig.ScaleResource `json:"scaleResources,omitempty"`
 	InactivityWindowPresets  []string                       `json:"inactivityWindowPresets,omitempty"`
+
+	// default scale down stabilization window of functions that don't set one
+	ScaleDownStabilizationWindow string `json:"scaleDownStabilizationWindow,omitempty"`
 }
 
 type ScaleToZeroMode string
@@ -104,6 +107,143 @@ type PlatformKubeConfig struct {
 
 	// TODO: Move IngressConfig here
 	DefaultServiceType corev1.ServiceType `json:"defaultServiceType,omitempty"`
+
+	// node pools functions may target by name, mapped to their configuration
+	NodePools map[string]NodePool `json:"nodePools,omitempty"`
+
+	// node capacity tiers (e.g. small, medium, large) functions may be assigned to by name, mapped to their
+	// configuration
+	CapacityTiers map[string]CapacityTier `json:"capacityTiers,omitempty"`
+
+	OrphanedResourcesCleanup OrphanedResourcesCleanup `json:"orphanedResourcesCleanup,omitempty"`
+
+	// labels applied to the resources of all functions, derived from each function's spec
+	DerivedLabels []DerivedLabel `json:"derivedLabels,omitempty"`
+
+	// the store from which functions' external secrets are synced
+	ExternalSecrets ExternalSecrets `json:"externalSecrets,omitempty"`
+
+	CRDMigration CRDMigration `json:"crdMigration,omitempty"`
+
+	// fields of functions that can't change once the function is created, by path - "metadata.labels.<key>",
+	// "metadata.annotations.<key>" or "spec.<field>[.<field>...]" (e.g. "spec.runtime")
+	ImmutableFields []string `json:"immutableFields,omitempty"`
+
+	// the range of node ports, as "<first>-<last>" (e.g. "31000-31999"), from which functions requesting a stable
+	// node port are allocated one. must be within the cluster's node port range
+	StableNodePortRange string `json:"stableNodePortRange,omitempty"`
+
+	// handling of functions that run under a runtime class (e.g. a sandboxed runtime such as gVisor or Kata)
+	RuntimeClasses RuntimeClasses `json:"runtimeClasses,omitempty"`
+
+	StatusCompaction StatusCompaction `json:"statusCompaction,omitempty"`
+
+	// reject functions whose ingress hosts are used by functions in other namespaces. when false, only the
+	// function's own ingress conflict policy applies, within its namespace
+	UniqueIngressHosts bool `json:"uniqueIngressHosts,omitempty"`
+
+	// containers (e.g. a shell or a profiler) that may be attached to a function's pods by annotating the function
+	// with nuclio.io/debug-sidecar: <name>, by name
+	DebugSidecars map[string]corev1.Container `json:"debugSidecars,omitempty"`
+}
+
+// caps on the history that functions' statuses accumulate, enforced whenever a function's status is written. the
+// oldest entries are dropped and counted in the status' omitted history
+type StatusCompaction struct {
+
+	// the maximal number of entries in each of the status' history lists (transitions, logs, replica
+	// observations). default: 0 (each list's own cap)
+	MaxHistoryLength int `json:"maxHistoryLength,omitempty"`
+
+	// the maximal size of the status, in bytes, as JSON. default: 0 (unlimited)
+	MaxStatusSize int `json:"maxStatusSize,omitempty"`
+}
+
+// what to do when a function references a runtime class that doesn't exist
+type MissingRuntimeClassPolicy string
+
+const (
+	MissingRuntimeClassPolicyWarn   MissingRuntimeClassPolicy = "warn"
+	MissingRuntimeClassPolicyReject MissingRuntimeClassPolicy = "reject"
+)
+
+type RuntimeClasses struct {
+
+	// default: reject, so that a typo doesn't silently fall back to the default runtime
+	MissingPolicy MissingRuntimeClassPolicy `json:"missingPolicy,omitempty"`
+
+	// sandboxed runtimes start pods slower, so the readiness and pod startup timeouts of functions with a runtime
+	// class are multiplied by this factor. default: 2
+	StartupTimeoutFactor float64 `json:"startupTimeoutFactor,omitempty"`
+}
+
+// returns the factor by which the startup timeouts of functions with a runtime class are extended
+func (rc *RuntimeClasses) GetStartupTimeoutFactor() float64 {
+	if rc.StartupTimeoutFactor <= 0 {
+		return 2
+	}
+
+	return rc.StartupTimeoutFactor
+}
+
+// while the NuclioFunction CRD is migrated to a new version, function reconciles are paused until the CRD serves
+// and stores only that version
+type CRDMigration struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// the version being migrated to (e.g. v1)
+	TargetVersion string `json:"targetVersion,omitempty"`
+
+	// how often the CRD is checked for the migration's completion. default: 30s
+	CheckInterval string `json:"checkInterval,omitempty"`
+}
+
+// functions' external secrets are synced by the external secrets operator, through ExternalSecret resources
+// referencing this store
+type ExternalSecrets struct {
+
+	// the name of the secret store. a ClusterSecretStore, unless SecretStoreKind is SecretStore (in which case it
+	// must exist in each namespace that has functions with external secrets)
+	SecretStore     string `json:"secretStore,omitempty"`
+	SecretStoreKind string `json:"secretStoreKind,omitempty"`
+
+	// how often secrets are synced from the store. default: 1h
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+}
+
+// a label whose value is rendered from a function's spec
+type DerivedLabel struct {
+	Name string `json:"name,omitempty"`
+
+	// a go template, executed against the function's spec (e.g. "{{ .Runtime }}"). when it renders empty, the
+	// label isn't set
+	Template string `json:"template,omitempty"`
+}
+
+// periodic cleanup of function resources whose function no longer exists (e.g. its delete event was missed)
+type OrphanedResourcesCleanup struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	Interval string `json:"interval,omitempty"`
+
+	// number of consecutive passes in which a function must be found missing before its resources are deleted,
+	// so that resources aren't deleted due to transient staleness
+	RequiredConfirmations int `json:"requiredConfirmations,omitempty"`
+}
+
+type CapacityTier struct {
+
+	// selects the tier's nodes, on which the pods of functions assigned to the tier are scheduled
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// the most of each resource a function assigned to the tier may request. resources not listed are limited
+	// only by the tier's nodes' allocatable resources
+	MaxRequests corev1.ResourceList `json:"maxRequests,omitempty"`
+}
+
+type NodePool struct {
+
+	// the taints of the pool's nodes, which functions targeting the pool tolerate
+	Taints []corev1.Taint `json:"taints,omitempty"`
 }
 
 type ImageRegistryOverridesConfig struct {
@@ -121,8 +261,52 @@ type IngressConfig struct {
 	IguazioSignInURL           string   `json:"iguazioSignInURL,omitempty"`
 	AllowedAuthenticationModes []string `json:"allowedAuthenticationModes,omitempty"`
 	Oauth2ProxyURL             string   `json:"oauth2ProxyURL,omitempty"`
+
+	// default max request body size for functions that do not specify one (e.g. "10Mi")
+	MaxRequestBodySize string `json:"maxRequestBodySize,omitempty"`
+
+	// regions functions may be served from, and the annotation through which the global load balancer
+	// is told which of them serve a given function
+	AllowedRegions    []string `json:"allowedRegions,omitempty"`
+	RegionsAnnotation string   `json:"regionsAnnotation,omitempty"`
+
+	// URL of the external auth service that verifies the tokens of functions requiring OIDC authentication
+	OIDCAuthURL string `json:"oidcAuthURL,omitempty"`
 }
 
+// external service discovery registry in which ready functions are registered
+type ServiceDiscovery struct {
+	Kind       string                 `json:"kind,omitempty"`
+	URL        string                 `json:"url,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// webhook that is notified when functions become ready, fail or become unhealthy, and when they're deleted
+type FunctionStateWebhook struct {
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// how long (e.g. "10m") to keep retrying a failed delivery. default: 5m
+	RetryDuration string `json:"retryDuration,omitempty"`
+}
+
+// sink to which the state of functions is archived before they're deleted
+type FunctionSnapshot struct {
+	Kind       string                 `json:"kind,omitempty"`
+	URL        string                 `json:"url,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// whether a failed snapshot blocks the function's deletion. default: proceed
+	FailurePolicy SnapshotFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+type SnapshotFailurePolicy string
+
+const (
+	SnapshotFailurePolicyBlock   SnapshotFailurePolicy = "block"
+	SnapshotFailurePolicyProceed SnapshotFailurePolicy = "proceed"
+)
+
 type CronTriggerCreationMode string
 
 const (
@@ -130,4 +314,6 @@ const (
 	KubeCronTriggerCreationMode      CronTriggerCreationMode = "kube"
 
 	DefaultServiceType = corev1.ServiceTypeClusterIP
+
+	DefaultRegionsAnnotation = "nuclio.io/regions"
 )
@@ -20,6 +20,7 @@ import (
 	"github.com/nuclio/nuclio/pkg/common/healthcheck"
 	"github.com/nuclio/nuclio/pkg/common/status"
 	"github.com/nuclio/nuclio/pkg/platformconfig"
+	"github.com/nuclio/nuclio/pkg/processor/trigger"
 
 	"github.com/nuclio/errors"
 	"github.com/nuclio/logger"
@@ -42,6 +43,21 @@ func NewProcessorServer(logger logger.Logger,
 	return newServer, nil
 }
 
+// AddTriggerReadinessChecks registers a readiness check per trigger, passing once the trigger is ready
+func (s *ProcessorServer) AddTriggerReadinessChecks(triggers []trigger.Trigger) {
+	for _, triggerInstance := range triggers {
+		triggerInstance := triggerInstance
+
+		s.Handler.AddReadinessCheck(healthcheck.GetTriggerReadinessCheckName(triggerInstance.GetID()), func() error {
+			if !triggerInstance.IsReady() {
+				return errors.New("Trigger not ready yet")
+			}
+
+			return nil
+		})
+	}
+}
+
 func (s *ProcessorServer) Start() error {
 
 	// if we're disabled, simply log and do nothing
@@ -19,6 +19,7 @@ package http
 import (
 	"bufio"
 	"encoding/json"
+	"net"
 	nethttp "net/http"
 	"os"
 	"strconv"
@@ -103,7 +104,8 @@ func (h *http) Start(checkpoint functionconfig.Checkpoint) error {
 		"listenAddress", h.configuration.URL,
 		"readBufferSize", h.configuration.ReadBufferSize,
 		"maxRequestBodySize", h.configuration.MaxRequestBodySize,
-		"cors", h.configuration.CORS)
+		"cors", h.configuration.CORS,
+		"tls", h.configuration.tlsEnabled())
 
 	h.server = &fasthttp.Server{
 		Handler:            h.onRequestFromFastHTTP(),
@@ -113,13 +115,30 @@ func (h *http) Start(checkpoint functionconfig.Checkpoint) error {
 		MaxRequestBodySize: h.configuration.MaxRequestBodySize,
 	}
 
-	// start listening
-	go h.server.ListenAndServe(h.configuration.URL) // nolint: errcheck
+	// bind before returning, so that once started the trigger accepts connections
+	listener, err := net.Listen("tcp4", h.configuration.URL)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to listen on %s", h.configuration.URL)
+	}
+
+	// start serving
+	if h.configuration.tlsEnabled() {
+		go h.server.ServeTLS(listener, // nolint: errcheck
+			h.configuration.TLSCertFile,
+			h.configuration.TLSKeyFile)
+	} else {
+		go h.server.Serve(listener) // nolint: errcheck
+	}
 
 	h.status = status.Ready
 	return nil
 }
 
+// IsReady returns whether the trigger is listening and its workers are ready
+func (h *http) IsReady() bool {
+	return h.status == status.Ready && h.AbstractTrigger.IsReady()
+}
+
 func (h *http) Stop(force bool) (functionconfig.Checkpoint, error) {
 	h.Logger.Debug("Shutting down")
 
@@ -38,6 +38,10 @@ type Configuration struct {
 	// https://github.com/valyala/fasthttp/issues/667#issuecomment-540965683
 	MaxRequestBodySize int
 	CORS               *cors.CORS
+
+	// when set, requests are served over TLS with the given certificate and key
+	TLSCertFile string
+	TLSKeyFile  string
 }
 
 func NewConfiguration(id string,
@@ -65,6 +69,10 @@ func NewConfiguration(id string,
 		newConfiguration.MaxRequestBodySize = DefaultMaxRequestBodySize
 	}
 
+	if (newConfiguration.TLSCertFile == "") != (newConfiguration.TLSKeyFile == "") {
+		return nil, errors.New("TLS requires both a certificate file and a key file")
+	}
+
 	if newConfiguration.CORS != nil && newConfiguration.CORS.Enabled {
 		newConfiguration.CORS = createCORSConfiguration(newConfiguration.CORS)
 	}
@@ -108,3 +116,7 @@ func createCORSConfiguration(corsConfiguration *cors.CORS) *cors.CORS {
 func (c *Configuration) corsEnabled() bool {
 	return c.CORS != nil && c.CORS.Enabled
 }
+
+func (c *Configuration) tlsEnabled() bool {
+	return c.TLSCertFile != ""
+}
@@ -22,6 +22,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/nuclio/nuclio/pkg/common/status"
 	"github.com/nuclio/nuclio/pkg/functionconfig"
 	"github.com/nuclio/nuclio/pkg/processor/worker"
 
@@ -75,6 +76,9 @@ type Trigger interface {
 
 	// TimeoutWorker times out a worker
 	TimeoutWorker(worker *worker.Worker) error
+
+	// IsReady returns whether the trigger is ready to handle events (e.g. its listener is bound)
+	IsReady() bool
 }
 
 // AbstractTrigger implements common trigger operations
@@ -208,6 +212,18 @@ func (at *AbstractTrigger) GetWorkers() []*worker.Worker {
 	return at.WorkerAllocator.GetWorkers()
 }
 
+// IsReady returns whether the trigger's workers are ready. triggers that need more than that to handle events
+// (e.g. a bound listener) override it
+func (at *AbstractTrigger) IsReady() bool {
+	for _, workerInstance := range at.WorkerAllocator.GetWorkers() {
+		if workerInstance.GetStatus() != status.Ready {
+			return false
+		}
+	}
+
+	return true
+}
+
 // GetStatistics returns trigger statistics
 func (at *AbstractTrigger) GetStatistics() *Statistics {
 